@@ -0,0 +1,38 @@
+package api_key
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// APIKey API key issued to an user, only SHA-256 hash of the key is saved
+type APIKey struct {
+	gorm.Model
+	UserID     string `gorm:"index"`
+	Name       string
+	HashedKey  string `gorm:"unique_index"`
+	Scopes     string `gorm:"type:text"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// GetScopes get key's scopes
+func (key APIKey) GetScopes() []string {
+	return strings.Fields(key.Scopes)
+}
+
+// IsActive check key is not revoked nor expired
+func (key APIKey) IsActive() bool {
+	return key.RevokedAt == nil && (key.ExpiresAt == nil || key.ExpiresAt.After(time.Now()))
+}
+
+// Hash hash key, use it to find key with `hashed_key`
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
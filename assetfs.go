@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/qor/assetfs"
+)
+
+// EmbeddedAssetFS assetfs finds assets from registered paths first, then from embedded file system, so views on disk could still overwrite embedded ones
+type EmbeddedAssetFS struct {
+	assetfs.Interface
+	Embedded fs.FS
+}
+
+// NewEmbeddedAssetFS wrap assetFS with embedded file system as fallback
+func NewEmbeddedAssetFS(assetFS assetfs.Interface, embedded fs.FS) *EmbeddedAssetFS {
+	return &EmbeddedAssetFS{Interface: assetFS, Embedded: embedded}
+}
+
+// Asset get content with name from registered paths, or embedded file system if not found
+func (embeddedFS *EmbeddedAssetFS) Asset(name string) ([]byte, error) {
+	content, err := embeddedFS.Interface.Asset(name)
+	if err == nil {
+		return content, nil
+	}
+
+	if content, embeddedErr := fs.ReadFile(embeddedFS.Embedded, strings.TrimPrefix(path.Clean("/"+name), "/")); embeddedErr == nil {
+		return content, nil
+	}
+	return content, err
+}
+
+// Glob list matched files from registered paths and embedded file system
+func (embeddedFS *EmbeddedAssetFS) Glob(pattern string) (matches []string, err error) {
+	matches, err = embeddedFS.Interface.Glob(pattern)
+
+	if results, embeddedErr := fs.Glob(embeddedFS.Embedded, strings.TrimPrefix(pattern, "/")); embeddedErr == nil {
+		for _, result := range results {
+			matches = append(matches, "/"+result)
+		}
+	}
+	return matches, err
+}
@@ -1,12 +1,21 @@
 package auth
 
 import (
+	"crypto/rand"
 	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/jinzhu/gorm"
 	"github.com/qor/auth/auth_identity"
 	"github.com/qor/auth/claims"
+	"github.com/qor/auth/i18n"
+	"github.com/qor/auth/views"
 	"github.com/qor/mailer"
 	"github.com/qor/mailer/logger"
 	"github.com/qor/render"
@@ -14,18 +23,30 @@ import (
 	"gopkg.in/square/go-jose.v2"
 )
 
-// Auth auth struct
+// Auth auth struct, it is safe for concurrent use after initialized,
+// registering providers, hooks, event handlers at runtime is guarded with locks, but Config shouldn't be changed after New
 type Auth struct {
 	*Config
 	// Embed SessionStorer to match Authority's AuthInterface
 	SessionStorerInterface
-	providers []Provider
+
+	providersMutex sync.RWMutex
+	providers      []Provider
+
+	eventsMutex   sync.RWMutex
+	eventHandlers map[string][]EventHandler
+	hooksMutex    sync.RWMutex
+	hooks         map[HookPoint][]Hook
+
+	trustedProxies []*net.IPNet
 }
 
 // Config auth config
 type Config struct {
 	// Default Database, which will be used in Auth when do CRUD, you can change a request's DB isntance by setting request Context's value, refer https://github.com/qor/auth/blob/master/utils.go#L32
 	DB *gorm.DB
+	// ReplicaDB read replica database, if configured, read-only lookups of identities, users, sessions and roles will use it, writes still go to DB
+	ReplicaDB *gorm.DB
 	// AuthIdentityModel a model used to save auth info, like email/password, OAuth token, linked user's ID, https://github.com/qor/auth/blob/master/auth_identity/auth_identity.go is the default implemention
 	AuthIdentityModel interface{}
 	// UserModel should be point of user struct's instance, it could be nil, then Auth will assume there is no user linked to auth info, and will return current auth info when get current user
@@ -34,17 +55,68 @@ type Config struct {
 	URLPrefix string
 	// ViewPaths prepend views paths for auth
 	ViewPaths []string
+	// FuncMap extra template functions available in all auth views
+	FuncMap template.FuncMap
+	// ViewData per-request data available in all auth views with func `view_data`, like CSRF token, branding, feature flags
+	ViewData func(context *Context) map[string]interface{}
+	// Branding product name, logo, primary color, support link used in default views and emails
+	Branding *Branding
+	// Theme name of registered theme, which overwrites default views, refer RegisterTheme
+	Theme string
+	// TemplateOverrides overwrite individual templates without copying the whole set, key is view name like `auth/login`, value is template's content
+	TemplateOverrides map[string]string
 
 	// Auth is using [Render](https://github.com/qor/render) to render pages, you could configure it with your project's Render if you have advanced usage like [BindataFS](https://github.com/qor/bindatafs)
 	Render *render.Render
+	// I18n translate user-facing strings, like flash messages, errors, views and emails, locale is detected from cookie and Accept-Language header, default translations are English
+	I18n *i18n.I18n
 	// Auth is using [Mailer](https://github.com/qor/mailer) to send email, by default, it will print email into console, you need to configure it to send real one
 	Mailer *mailer.Mailer
 	// UserStorer is an interface that defined how to get/save user, Auth provides a default one based on AuthIdentityModel, UserModel's definition
 	UserStorer UserStorerInterface
+	// IdentityStore is an interface that defined how to find/save auth identities, Auth provides a default one saves them into database with gorm
+	IdentityStore IdentityStoreInterface
 	// SessionStorer is an interface that defined how to encode/validate/save/destroy session data and flash messages between requests, Auth provides a default method do the job, to use the default value, don't forgot to mount SessionManager's middleware into your router to save session data correctly. refer [session](https://github.com/qor/session) for more details
 	SessionStorer SessionStorerInterface
 	// Redirector redirect user to a new page after registered, logged, confirmed...
 	Redirector RedirectorInterface
+	// RoleStorer is an interface that defined how to get/save user's roles, Auth provides a default one saves roles into database with model user_role.UserRole
+	RoleStorer RoleStorerInterface
+	// TrackSessions save issued sessions' metadata like device, IP address into database with model auth_session.AuthSession
+	TrackSessions bool
+	// GeoIPResolver resolve request's location, used to enrich events and sessions with country, city
+	GeoIPResolver GeoIPResolverInterface
+	// TablePrefix prefix auth's tables' names, like `iam_`, tables are renamed with gorm's global DefaultTableNameHandler, so configure it before migrating or querying them
+	TablePrefix string
+	// TableSchema put auth's tables into the schema, like `auth`, the schema needs to be created before running migrations
+	TableSchema string
+	// TableNames override tables' names, key is the default table name, like `auth_identities`
+	TableNames map[string]string
+	// Random random source used to generate tokens, like session IDs, device IDs, invitation tokens, default value is crypto/rand.Reader, inject a deterministic one in tests to assert on generated values
+	Random io.Reader
+	// Clock current time used to issue, expire sessions, tokens, default value is time.Now, inject a fake one in tests to assert on expiry behaviour
+	Clock func() time.Time
+	// IPRules allow/deny rules of client IP evaluated before serving auth routes, like `&auth.IPRules{Allow: []string{"10.8.0.0/16"}}`
+	IPRules *IPRules
+	// ProviderIPRules IP rules for provider's routes, key is provider's name, evaluated after IPRules
+	ProviderIPRules map[string]*IPRules
+	// RoleIPRules IP rules for users with the role, key is role name, users with the role couldn't login from other IPs, like admins could only login from VPN
+	RoleIPRules map[string]*IPRules
+	// TrustedProxies IP ranges of proxies, client IP is read from X-Forwarded-For, X-Real-Ip headers only if requests come from them, otherwise remote address is used
+	TrustedProxies []string
+	// ReturnToHosts hosts users could be redirected to with `return_to` after logged in besides current site, like `app.example.com`, `*.example.com`
+	ReturnToHosts []string
+	// SecurityHeaders security headers sent with responses of auth routes, like CSP, X-Frame-Options, default value is DefaultSecurityHeaders, set it to `&auth.SecurityHeaders{}` to not send them
+	SecurityHeaders *SecurityHeaders
+	// CookiePolicy strict cookie mode, auth's cookies are named with `__Host-` prefix, and are always Secure, HttpOnly, SameSite, insecure combinations are refused, refer CookiePolicy
+	CookiePolicy *CookiePolicy
+	// CSRF validate CSRF tokens of POST, PUT, PATCH, DELETE requests to auth routes, put `{{csrf_field}}` into forms, JSON clients get token from `{Auth Prefix}/csrf_token` and send it with header `X-CSRF-Token`
+	CSRF *CSRFConfig
+	// Bootstrap grant admin role to the first registered user or users with configured emails, so fresh deployments aren't locked out of their own admin features
+	Bootstrap *BootstrapConfig
+
+	// Failures defined how to respond failures for each error code, like `unauthorized`, `locked`, `state_expired`, `*` for all other errors, refer FailureResponse, ErrorCode
+	Failures map[string]*FailureResponse
 
 	// LoginHandler defined behaviour when request `{Auth Prefix}/login`, default behaviour defined in http://godoc.org/github.com/qor/auth#pkg-variables
 	LoginHandler func(*Context, func(*Context) (*claims.Claims, error))
@@ -56,12 +128,25 @@ type Config struct {
 	DeregisterHandler func(*Context)
 }
 
-// New initialize Auth
+// New initialize Auth, panics if config is invalid, use NewWithError to handle the error
 func New(config *Config) *Auth {
+	auth, err := NewWithError(config)
+	if err != nil {
+		panic(err)
+	}
+	return auth
+}
+
+// NewWithError initialize Auth, returns a *ConfigError lists all problems if config is invalid, refer Config.Validate
+func NewWithError(config *Config) (*Auth, error) {
 	if config == nil {
 		config = &Config{}
 	}
 
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
 	if config.URLPrefix == "" {
 		config.URLPrefix = "/auth/"
 	} else {
@@ -76,28 +161,70 @@ func New(config *Config) *Auth {
 		config.Render = render.New(nil)
 	}
 
+	if config.I18n == nil {
+		config.I18n = i18n.New(nil)
+	}
+
 	if config.Mailer == nil {
 		config.Mailer = mailer.New(&mailer.Config{
 			Sender: logger.New(&logger.Config{}),
 		})
 	}
 
+	if config.Random == nil {
+		config.Random = rand.Reader
+	}
+
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+
 	if config.UserStorer == nil {
 		config.UserStorer = &UserStorer{}
 	}
 
+	if config.IdentityStore == nil {
+		config.IdentityStore = &IdentityStore{}
+	}
+
+	if config.RoleStorer == nil {
+		config.RoleStorer = &RoleStorer{}
+	}
+
+	config.registerTableNameHandler()
+
+	if config.Bootstrap != nil && config.Bootstrap.AdminRole == "" {
+		config.Bootstrap.AdminRole = "admin"
+	}
+
+	if config.SecurityHeaders == nil {
+		headers := DefaultSecurityHeaders
+		config.SecurityHeaders = &headers
+	}
+
+	if config.CSRF != nil {
+		if config.CSRF.CookieName == "" {
+			config.CSRF.CookieName = "_auth_csrf"
+		}
+
+		if config.CSRF.FieldName == "" {
+			config.CSRF.FieldName = "csrf_token"
+		}
+
+		if config.CSRF.HeaderName == "" {
+			config.CSRF.HeaderName = "X-CSRF-Token"
+		}
+	}
+
 	if config.SessionStorer == nil {
 		config.SessionStorer = &SessionStorer{
 			SessionName:    "_auth_session",
 			SessionManager: manager.SessionManager,
 			SigningMethod:  jose.HS256,
+			Clock:          config.Clock,
 		}
 	}
 
-	if config.Redirector == nil {
-		panic("config.Redirector must be specified")
-	}
-
 	if config.LoginHandler == nil {
 		config.LoginHandler = DefaultLoginHandler
 	}
@@ -118,11 +245,30 @@ func New(config *Config) *Auth {
 		config.Render.RegisterViewPath(viewPath)
 	}
 
-	config.Render.RegisterViewPath("github.com/qor/auth/views")
+	// default views are embedded, views in selected theme, registered view paths overwrite them
+	config.registerViews(views.FS)
+	if mailerViews, err := fs.Sub(views.FS, "mailers"); err == nil {
+		config.Mailer.Config.Render.SetAssetFS(NewEmbeddedAssetFS(config.Mailer.Config.Render.AssetFileSystem, mailerViews))
+	}
 
 	auth := &Auth{Config: config}
 
 	auth.SessionStorerInterface = config.SessionStorer
 
-	return auth
+	// IP rules are validated, parse them once
+	auth.trustedProxies, _ = parseCIDRs(config.TrustedProxies)
+	config.IPRules.parse()
+	for _, rules := range config.ProviderIPRules {
+		rules.parse()
+	}
+	for _, rules := range config.RoleIPRules {
+		rules.parse()
+	}
+
+	if len(config.RoleIPRules) > 0 {
+		auth.RegisterHook(BeforeLogin, auth.checkRoleIPRules)
+		auth.RegisterHook(AfterRegister, auth.checkRoleIPRules)
+	}
+
+	return auth, nil
 }
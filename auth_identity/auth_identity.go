@@ -15,11 +15,12 @@ type AuthIdentity struct {
 
 // Basic basic information about auth identity
 type Basic struct {
-	Provider          string // phone, email, wechat, github...
-	UID               string `gorm:"column:uid"`
+	Provider          string `gorm:"unique_index:uix_auth_identities_provider_uid"` // phone, email, wechat, github...
+	UID               string `gorm:"column:uid;unique_index:uix_auth_identities_provider_uid"`
 	EncryptedPassword string
 	UserID            string
 	ConfirmedAt       *time.Time
+	Token             string `gorm:"type:text"` // OAuth token, saved by OAuth providers
 }
 
 // ToClaims convert to auth Claims
@@ -0,0 +1,27 @@
+package auth_session
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// AuthSession auth session model, saves metadata of issued sessions, like device, IP address
+type AuthSession struct {
+	gorm.Model
+	SessionID    string `gorm:"unique_index"`
+	UserID       string `gorm:"index"`
+	Provider     string
+	DeviceID     string `gorm:"index"`
+	IPAddress    string
+	UserAgent    string
+	Country      string
+	City         string
+	LastActiveAt *time.Time
+	RevokedAt    *time.Time
+}
+
+// IsRevoked check session is revoked or not
+func (session AuthSession) IsRevoked() bool {
+	return session.RevokedAt != nil
+}
@@ -0,0 +1,166 @@
+package authority
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/qor/auth"
+	"github.com/qor/auth/claims"
+)
+
+// ErrPermissionDenied permission denied error
+var ErrPermissionDenied = errors.New("permission denied")
+
+// Config authority config
+type Config struct {
+	Auth *auth.Auth
+	// Hooks decision hooks invoked on every authorization check, to make attribute based decisions like ownership, tenant, time of day
+	Hooks []DecisionHook
+}
+
+// New initialize Authority, panics if config is invalid, use NewWithError to handle the error
+func New(config *Config) *Authority {
+	authority, err := NewWithError(config)
+	if err != nil {
+		panic(err)
+	}
+	return authority
+}
+
+// NewWithError initialize Authority, returns a *auth.ConfigError lists all problems if config is invalid
+func NewWithError(config *Config) (*Authority, error) {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Authority{Config: config, permissions: map[string][]string{}}, nil
+}
+
+// Validate validate config
+func (config *Config) Validate() error {
+	configErr := &auth.ConfigError{Name: "authority"}
+	if config.Auth == nil {
+		configErr.Add("Auth must be specified")
+	}
+	return configErr.Err()
+}
+
+// Authority authority struct, used to check current user could perform an action or not
+type Authority struct {
+	*Config
+	mutex       sync.RWMutex
+	permissions map[string][]string
+}
+
+// Allow allow roles to perform an action
+func (authority *Authority) Allow(action string, roles ...string) {
+	authority.mutex.Lock()
+	defer authority.mutex.Unlock()
+	authority.permissions[action] = append(authority.permissions[action], roles...)
+}
+
+// RegisterHook register a decision hook
+func (authority *Authority) RegisterHook(hook DecisionHook) {
+	authority.mutex.Lock()
+	defer authority.mutex.Unlock()
+	authority.Hooks = append(authority.Hooks, hook)
+}
+
+// Authorize check current user could perform action on resource, return auth.ErrUnauthorized if not logged in, ErrPermissionDenied if not allowed
+//
+// Decision hooks are invoked in order, any hook denied the request will deny it, otherwise it is allowed if any hook allowed it, or current user has a role allowed to perform the action
+func (authority *Authority) Authorize(req *http.Request, action string, resource interface{}) error {
+	request := authority.newRequest(req, action, resource)
+
+	authority.mutex.RLock()
+	hooks := append([]DecisionHook{}, authority.Hooks...)
+	allowedRoles := authority.permissions[action]
+	authority.mutex.RUnlock()
+
+	var allowed bool
+	for _, hook := range hooks {
+		decision, err := hook.Decide(request)
+		if err != nil {
+			return err
+		}
+
+		switch decision {
+		case Deny:
+			return request.denied()
+		case Allow:
+			allowed = true
+		}
+	}
+
+	if allowed || hasAnyRole(request.Roles, allowedRoles) {
+		return nil
+	}
+	return request.denied()
+}
+
+// Allowed check current user could perform action on resource or not
+func (authority *Authority) Allowed(req *http.Request, action string, resource interface{}) bool {
+	return authority.Authorize(req, action, resource) == nil
+}
+
+// Handler only allow users could perform action to access the handler
+func (authority *Authority) Handler(action string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch authority.Authorize(req, action, nil) {
+		case nil:
+			handler.ServeHTTP(w, req)
+		case auth.ErrUnauthorized:
+			http.Error(w, auth.ErrUnauthorized.Error(), http.StatusUnauthorized)
+		default:
+			http.Error(w, ErrPermissionDenied.Error(), http.StatusForbidden)
+		}
+	})
+}
+
+func (authority *Authority) newRequest(req *http.Request, action string, resource interface{}) *Request {
+	request := &Request{Request: req, Action: action, Resource: resource}
+	if claims, err := authority.Auth.SessionStorer.Get(req); err == nil {
+		request.Claims = claims
+		request.User = authority.Auth.GetCurrentUser(req)
+		request.Roles = authority.Auth.GetCurrentRoles(req)
+	}
+	return request
+}
+
+// Request authorization request, passed to decision hooks
+type Request struct {
+	Request  *http.Request
+	Claims   *claims.Claims
+	User     interface{}
+	Roles    []string
+	Action   string
+	Resource interface{}
+}
+
+// HasRole check current user has any of the roles
+func (request *Request) HasRole(roles ...string) bool {
+	return hasAnyRole(request.Roles, roles)
+}
+
+func (request *Request) denied() error {
+	if request.Claims == nil {
+		return auth.ErrUnauthorized
+	}
+	return ErrPermissionDenied
+}
+
+func hasAnyRole(roles []string, expected []string) bool {
+	for _, role := range roles {
+		for _, r := range expected {
+			if role == r {
+				return true
+			}
+		}
+	}
+	return false
+}
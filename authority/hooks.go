@@ -0,0 +1,98 @@
+package authority
+
+import (
+	"net/http"
+	"time"
+)
+
+// Decision decision made by hook
+type Decision int
+
+const (
+	// Abstain hook doesn't care about the request, leave it to other hooks or roles
+	Abstain Decision = iota
+	// Allow hook allows the request
+	Allow
+	// Deny hook denies the request, will override other decisions
+	Deny
+)
+
+// DecisionHook decision hook interface, invoked on every authorization check
+type DecisionHook interface {
+	Decide(request *Request) (Decision, error)
+}
+
+// DecisionHookFunc adapter to use ordinary functions as decision hook
+type DecisionHookFunc func(request *Request) (Decision, error)
+
+// Decide call hook function
+func (fc DecisionHookFunc) Decide(request *Request) (Decision, error) {
+	return fc(request)
+}
+
+// OwnerHook allow current user to perform actions on resources owned by itself, owner should return resource's owner id, or blank if it is not supported
+func OwnerHook(owner func(resource interface{}) string, actions ...string) DecisionHook {
+	return DecisionHookFunc(func(request *Request) (Decision, error) {
+		if request.Claims == nil || request.Resource == nil || !includeAction(actions, request.Action) {
+			return Abstain, nil
+		}
+
+		if ownerID := owner(request.Resource); ownerID != "" && ownerID == request.Claims.GetUserID() {
+			return Allow, nil
+		}
+		return Abstain, nil
+	})
+}
+
+// MembershipFunc get user's current role in the organization, should return an error if the user isn't a member of the organization, like organization provider's `MemberRole`
+type MembershipFunc func(req *http.Request, organizationID string, userID string) (role string, err error)
+
+// TenantHook deny access resources belongs to other organizations than current one, tenant should return resource's organization id, or blank if it is not supported
+// current organization and role saved in claims are rechecked with membership, so members removed or demoted are denied before their sessions expire
+func TenantHook(tenant func(resource interface{}) string, membership MembershipFunc) DecisionHook {
+	return DecisionHookFunc(func(request *Request) (Decision, error) {
+		if request.Resource == nil {
+			return Abstain, nil
+		}
+
+		if organizationID := tenant(request.Resource); organizationID != "" {
+			if request.Claims == nil || request.Claims.OrganizationID != organizationID || membership == nil {
+				return Deny, nil
+			}
+
+			role, err := membership(request.Request, organizationID, request.Claims.GetUserID())
+			if err != nil || role != request.Claims.OrganizationRole {
+				return Deny, nil
+			}
+		}
+		return Abstain, nil
+	})
+}
+
+// TimeWindowHook deny actions out of time window, hours are in range [0, 24) of the location, like `TimeWindowHook(9, 18, time.Local, "delete")`
+func TimeWindowHook(startHour, endHour int, location *time.Location, actions ...string) DecisionHook {
+	return DecisionHookFunc(func(request *Request) (Decision, error) {
+		if !includeAction(actions, request.Action) {
+			return Abstain, nil
+		}
+
+		if hour := time.Now().In(location).Hour(); hour < startHour || hour >= endHour {
+			return Deny, nil
+		}
+		return Abstain, nil
+	})
+}
+
+// includeAction check action is included in actions, blank actions means all actions
+func includeAction(actions []string, action string) bool {
+	if len(actions) == 0 {
+		return true
+	}
+
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package authtest
+
+import (
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// NewRandom return a deterministic random source, set it as auth.Config's Random, tokens generated with the same seed are the same in every run, NEVER use it in production
+func NewRandom(seed int64) io.Reader {
+	return &lockedRandom{rand: rand.New(rand.NewSource(seed))}
+}
+
+type lockedRandom struct {
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+func (random *lockedRandom) Read(p []byte) (int, error) {
+	random.mutex.Lock()
+	defer random.mutex.Unlock()
+	return random.rand.Read(p)
+}
+
+// NewClock return a fake clock stopped at now, set its Now as auth.Config's Clock, move it with Advance to test expiry behaviour
+//
+//	clock := authtest.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+//	Auth := auth.New(&auth.Config{Clock: clock.Now, Random: authtest.NewRandom(1), ...})
+//	clock.Advance(24 * time.Hour)
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Clock fake clock
+type Clock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// Now get clock's current time
+func (clock *Clock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return clock.now
+}
+
+// Advance move clock forward with duration
+func (clock *Clock) Advance(duration time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.now = clock.now.Add(duration)
+}
+
+// Set set clock's current time
+func (clock *Clock) Set(now time.Time) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.now = now
+}
@@ -0,0 +1,325 @@
+// Package authtest helpers for testing applications and providers using auth
+package authtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// OAuthUser user of fake OAuth server
+type OAuthUser struct {
+	// Subject user's unique ID, returned as `sub`, `id`
+	Subject string
+	// Login user's login name, returned as `login`, `preferred_username`, like GitHub's login
+	Login   string
+	Name    string
+	Email   string
+	Picture string
+	// Claims extra claims returned in ID token and userinfo
+	Claims map[string]interface{}
+}
+
+// OAuthServerConfig fake OAuth server config
+type OAuthServerConfig struct {
+	// ClientID, ClientSecret if set, requests with other clients are rejected
+	ClientID     string
+	ClientSecret string
+	// Users user is chosen with authorize request's `login_hint`, default is the first user
+	Users []OAuthUser
+	// TokenExpiry access token, ID token's expiry, default value is 1 hour
+	TokenExpiry time.Duration
+}
+
+// NewOAuthServer start an in-process fake OAuth2/OIDC server exposing authorize, token, userinfo, jwks and discovery endpoints,
+// authorize approves immediately and redirects back with code, so real OAuth/OIDC provider code could be integration-tested offline, call Close after tests
+//
+//	server := authtest.NewOAuthServer(&authtest.OAuthServerConfig{Users: []authtest.OAuthUser{{Subject: "1", Login: "alice", Email: "alice@example.com"}}})
+//	defer server.Close()
+//
+//	// point your OAuth/OIDC provider's endpoints to the server
+//	oauthConfig := oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: server.AuthorizeURL(), TokenURL: server.TokenURL()}, ...}
+func NewOAuthServer(config *OAuthServerConfig) *OAuthServer {
+	if config == nil {
+		config = &OAuthServerConfig{}
+	}
+
+	if config.TokenExpiry == 0 {
+		config.TokenExpiry = time.Hour
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	server := &OAuthServer{
+		Config: config,
+		key:    key,
+		keyID:  randomString(),
+		codes:  map[string]authorization{},
+		tokens: map[string]OAuthUser{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", server.authorize)
+	mux.HandleFunc("/token", server.token)
+	mux.HandleFunc("/userinfo", server.userinfo)
+	mux.HandleFunc("/jwks", server.jwks)
+	mux.HandleFunc("/.well-known/openid-configuration", server.discovery)
+	server.Server = httptest.NewServer(mux)
+	return server
+}
+
+// OAuthServer in-process fake OAuth2/OIDC server
+type OAuthServer struct {
+	*httptest.Server
+	Config *OAuthServerConfig
+
+	key    *rsa.PrivateKey
+	keyID  string
+	mutex  sync.Mutex
+	codes  map[string]authorization
+	tokens map[string]OAuthUser
+}
+
+type authorization struct {
+	user        OAuthUser
+	clientID    string
+	redirectURI string
+	nonce       string
+}
+
+// AuthorizeURL authorize endpoint
+func (server *OAuthServer) AuthorizeURL() string { return server.URL + "/authorize" }
+
+// TokenURL token endpoint
+func (server *OAuthServer) TokenURL() string { return server.URL + "/token" }
+
+// UserInfoURL userinfo endpoint
+func (server *OAuthServer) UserInfoURL() string { return server.URL + "/userinfo" }
+
+// JWKSURL jwks endpoint
+func (server *OAuthServer) JWKSURL() string { return server.URL + "/jwks" }
+
+// Issuer issuer of ID tokens, discovery document is served at `{Issuer}/.well-known/openid-configuration`
+func (server *OAuthServer) Issuer() string { return server.URL }
+
+// AddUser add users could login
+func (server *OAuthServer) AddUser(users ...OAuthUser) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.Config.Users = append(server.Config.Users, users...)
+}
+
+func (server *OAuthServer) authorize(w http.ResponseWriter, req *http.Request) {
+	var (
+		query       = req.URL.Query()
+		redirectURI = query.Get("redirect_uri")
+	)
+
+	redirectURL, err := url.Parse(redirectURI)
+	if err != nil || redirectURI == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	params := redirectURL.Query()
+	if state := query.Get("state"); state != "" {
+		params.Set("state", state)
+	}
+
+	user, ok := server.findUser(query.Get("login_hint"))
+	switch {
+	case server.Config.ClientID != "" && query.Get("client_id") != server.Config.ClientID:
+		params.Set("error", "unauthorized_client")
+	case !ok:
+		params.Set("error", "access_denied")
+	default:
+		code := randomString()
+		server.mutex.Lock()
+		server.codes[code] = authorization{user: user, clientID: query.Get("client_id"), redirectURI: redirectURI, nonce: query.Get("nonce")}
+		server.mutex.Unlock()
+		params.Set("code", code)
+	}
+
+	redirectURL.RawQuery = params.Encode()
+	http.Redirect(w, req, redirectURL.String(), http.StatusFound)
+}
+
+func (server *OAuthServer) token(w http.ResponseWriter, req *http.Request) {
+	req.ParseForm()
+
+	clientID, clientSecret, ok := req.BasicAuth()
+	if !ok {
+		clientID, clientSecret = req.Form.Get("client_id"), req.Form.Get("client_secret")
+	}
+
+	if server.Config.ClientID != "" && (clientID != server.Config.ClientID || clientSecret != server.Config.ClientSecret) {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+
+	var authz authorization
+	switch req.Form.Get("grant_type") {
+	case "authorization_code":
+		server.mutex.Lock()
+		authz, ok = server.codes[req.Form.Get("code")]
+		delete(server.codes, req.Form.Get("code"))
+		server.mutex.Unlock()
+
+		if !ok || authz.redirectURI != req.Form.Get("redirect_uri") {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+	case "refresh_token":
+		server.mutex.Lock()
+		authz.user, ok = server.tokens[req.Form.Get("refresh_token")]
+		server.mutex.Unlock()
+
+		if !ok {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+		authz.clientID = clientID
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	idToken, err := server.signIDToken(authz)
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+
+	accessToken, refreshToken := randomString(), randomString()
+	server.mutex.Lock()
+	server.tokens[accessToken] = authz.user
+	server.tokens[refreshToken] = authz.user
+	server.mutex.Unlock()
+
+	writeJSON(w, map[string]interface{}{
+		"access_token":  accessToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(server.Config.TokenExpiry.Seconds()),
+		"refresh_token": refreshToken,
+		"id_token":      idToken,
+		"scope":         "openid profile email",
+	})
+}
+
+func (server *OAuthServer) userinfo(w http.ResponseWriter, req *http.Request) {
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = req.URL.Query().Get("access_token")
+	}
+
+	server.mutex.Lock()
+	user, ok := server.tokens[token]
+	server.mutex.Unlock()
+
+	if !ok {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_token")
+		return
+	}
+	writeJSON(w, userClaims(user))
+}
+
+func (server *OAuthServer) jwks(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &server.key.PublicKey, KeyID: server.keyID, Algorithm: string(jose.RS256), Use: "sig"}}})
+}
+
+func (server *OAuthServer) discovery(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"issuer":                                server.Issuer(),
+		"authorization_endpoint":                server.AuthorizeURL(),
+		"token_endpoint":                        server.TokenURL(),
+		"userinfo_endpoint":                     server.UserInfoURL(),
+		"jwks_uri":                              server.JWKSURL(),
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{string(jose.RS256)},
+		"scopes_supported":                      []string{"openid", "profile", "email"},
+		"grant_types_supported":                 []string{"authorization_code", "refresh_token"},
+	})
+}
+
+func (server *OAuthServer) signIDToken(authz authorization) (string, error) {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: server.key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", server.keyID),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := userClaims(authz.user)
+	claims["iss"] = server.Issuer()
+	claims["aud"] = authz.clientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(server.Config.TokenExpiry).Unix()
+	if authz.nonce != "" {
+		claims["nonce"] = authz.nonce
+	}
+	return jwt.Signed(signer).Claims(claims).CompactSerialize()
+}
+
+func (server *OAuthServer) findUser(hint string) (OAuthUser, bool) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	for _, user := range server.Config.Users {
+		if hint == "" || hint == user.Subject || hint == user.Login || hint == user.Email {
+			return user, true
+		}
+	}
+	return OAuthUser{}, false
+}
+
+func userClaims(user OAuthUser) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub":                user.Subject,
+		"id":                 user.Subject,
+		"login":              user.Login,
+		"preferred_username": user.Login,
+		"name":               user.Name,
+		"email":              user.Email,
+		"email_verified":     user.Email != "",
+		"picture":            user.Picture,
+		"avatar_url":         user.Picture,
+	}
+
+	for key, value := range user.Claims {
+		claims[key] = value
+	}
+	return claims
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
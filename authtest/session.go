@@ -0,0 +1,71 @@
+package authtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/qor/auth"
+	"github.com/qor/auth/claims"
+)
+
+// LoginAs sign user in with Auth's SessionStorer, return the session cookie, user could be claims.ClaimerInterface, or user model with primary key `ID`
+//
+//	req := httptest.NewRequest("GET", "/account", nil)
+//	req.AddCookie(authtest.LoginAs(t, Auth, &User{ID: 1}))
+func LoginAs(t testing.TB, Auth *auth.Auth, user interface{}) *http.Cookie {
+	t.Helper()
+
+	var (
+		w   = httptest.NewRecorder()
+		req = httptest.NewRequest("GET", "/", nil)
+	)
+
+	if err := Auth.Login(w, req, toClaims(t, user)); err != nil {
+		t.Fatalf("authtest: failed to login: %v", err)
+	}
+
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name != auth.DeviceCookieName {
+			return cookie
+		}
+	}
+
+	t.Fatalf("authtest: no session cookie written, is SessionStorer saving sessions into cookies?")
+	return nil
+}
+
+// AuthorizationHeader mint a signed session token for user, set it as request's `Authorization` header, sessions won't be saved
+func AuthorizationHeader(t testing.TB, Auth *auth.Auth, req *http.Request, user interface{}) *http.Request {
+	t.Helper()
+
+	token, err := Auth.SessionStorer.SignedToken(toClaims(t, user))
+	if err != nil {
+		t.Fatalf("authtest: failed to sign token: %v", err)
+	}
+	req.Header.Set("Authorization", token)
+	return req
+}
+
+// RequestWithUser return a shallow copy of request with user set as current user, Auth.GetCurrentUser returns it without decoding sessions
+func RequestWithUser(req *http.Request, user interface{}) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), auth.CurrentUser, user))
+}
+
+func toClaims(t testing.TB, user interface{}) *claims.Claims {
+	if claimer, ok := user.(claims.ClaimerInterface); ok {
+		return claimer.ToClaims()
+	}
+
+	if value := reflect.Indirect(reflect.ValueOf(user)); value.Kind() == reflect.Struct {
+		if field := value.FieldByName("ID"); field.IsValid() {
+			return &claims.Claims{UserID: fmt.Sprint(field.Interface())}
+		}
+	}
+
+	t.Fatalf("authtest: couldn't get user ID from %T, pass claims.ClaimerInterface or user model with field `ID`", user)
+	return nil
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/qor/auth/claims"
+	"github.com/qor/qor/utils"
+)
+
+// BootstrapConfig grant admin role to initial users, so fresh deployments aren't locked out of their own admin features
+type BootstrapConfig struct {
+	// AdminRole role granted to initial admins, default value is `admin`
+	AdminRole string
+	// FirstUserIsAdmin grant AdminRole to the very first registered user
+	FirstUserIsAdmin bool
+	// InitialAdminEmails grant AdminRole to users registered with those emails, only once per email, the email need to be verified,
+	// which means user's auth identity is confirmed, or user registered with one of VerifiedEmailProviders
+	InitialAdminEmails []string
+	// VerifiedEmailProviders providers only return verified emails, like OAuth providers check `email_verified`, emails of other providers need their auth identities confirmed
+	VerifiedEmailProviders []string
+}
+
+// BootstrapMarker marker of granted bootstrap, its Name is unique, so only one of concurrent first registrations could insert it
+type BootstrapMarker struct {
+	Name      string `gorm:"primary_key"`
+	UserID    string
+	CreatedAt time.Time
+}
+
+// TableName table name of bootstrap markers
+func (BootstrapMarker) TableName() string {
+	return TableName("auth_bootstraps")
+}
+
+// Bootstrap grant admin role to registered user based on Config.Bootstrap's rules, it is called by DefaultRegisterHandler, call it from your RegisterHandler if you have customized it,
+// and after user confirmed its email, so initial admins registered with unconfirmed emails are granted, granted rules are recorded, so calling it again is safe
+func (auth *Auth) Bootstrap(context *Context, claims *claims.Claims) error {
+	var config = auth.Config.Bootstrap
+	if config == nil || claims == nil {
+		return nil
+	}
+
+	if config.FirstUserIsAdmin && auth.isFirstUser(context) && auth.markBootstrap(context, "first_user_is_admin", claims.GetUserID()) {
+		return auth.RoleStorer.Add(claims.GetUserID(), config.AdminRole, context)
+	}
+
+	if len(config.InitialAdminEmails) > 0 {
+		email := auth.verifiedEmail(context, claims)
+		for _, adminEmail := range config.InitialAdminEmails {
+			if email != "" && strings.EqualFold(strings.TrimSpace(adminEmail), email) && auth.markBootstrap(context, "initial_admin_email:"+strings.ToLower(email), claims.GetUserID()) {
+				return auth.RoleStorer.Add(claims.GetUserID(), config.AdminRole, context)
+			}
+		}
+	}
+
+	return nil
+}
+
+// verifiedEmail get user's email if it is verified, emails of VerifiedEmailProviders are verified by them, otherwise, user's auth identity need to be confirmed, and its UID is the email
+func (auth *Auth) verifiedEmail(context *Context, claims *claims.Claims) string {
+	for _, provider := range auth.Config.Bootstrap.VerifiedEmailProviders {
+		if provider == claims.Provider {
+			return auth.GetEmail(context, claims)
+		}
+	}
+
+	identity, err := auth.IdentityStore.FindByProviderUID(context, claims.Provider, claims.ID)
+	if err != nil || identity == nil {
+		return ""
+	}
+
+	value := utils.Indirect(reflect.ValueOf(identity))
+	if value.Kind() != reflect.Struct {
+		return ""
+	}
+
+	if field := value.FieldByName("ConfirmedAt"); !field.IsValid() {
+		return ""
+	} else if confirmedAt, ok := field.Interface().(*time.Time); !ok || confirmedAt == nil {
+		return ""
+	}
+
+	if uid := value.FieldByName("UID"); uid.Kind() == reflect.String && strings.Contains(uid.String(), "@") {
+		return strings.TrimSpace(uid.String())
+	}
+	return ""
+}
+
+// isFirstUser check there is only one user (the one just registered) in database, concurrent registrations may all see themselves as the first one in their transactions, so markBootstrap is checked too
+func (auth *Auth) isFirstUser(context *Context) bool {
+	var (
+		count int
+		err   error
+		tx    = auth.GetDB(context.Request)
+	)
+
+	if auth.Config.UserModel != nil {
+		err = tx.Model(reflect.New(utils.ModelType(auth.Config.UserModel)).Interface()).Count(&count).Error
+	} else {
+		count, err = auth.IdentityStore.Count(context)
+	}
+
+	return err == nil && count == 1
+}
+
+// markBootstrap insert marker with name, returns false if it already exists, in registration's transaction, concurrent registrations wait for the first one's transaction, then fail with unique constraint
+func (auth *Auth) markBootstrap(context *Context, name string, userID string) bool {
+	return auth.savepoint(context, "auth_bootstrap", func() error {
+		return auth.GetDB(context.Request).Create(&BootstrapMarker{Name: name, UserID: userID, CreatedAt: auth.Now()}).Error
+	}) == nil
+}
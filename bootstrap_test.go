@@ -0,0 +1,76 @@
+package auth_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/qor/auth"
+	"github.com/qor/auth/auth_identity"
+	"github.com/qor/auth/claims"
+)
+
+func TestInitialAdminEmailsRequireVerifiedEmail(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	Auth := auth.New(&auth.Config{DB: db, Redirector: redirector{}, Bootstrap: &auth.BootstrapConfig{
+		AdminRole:              "admin",
+		InitialAdminEmails:     []string{"ops@example.com", "oncall@example.com"},
+		VerifiedEmailProviders: []string{"google"},
+	}})
+	if err := auth.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		now     = time.Now()
+		context = &auth.Context{Auth: Auth, Request: httptest.NewRequest("POST", "/", nil)}
+	)
+
+	isAdmin := func(userID string) bool {
+		roles, _ := Auth.RoleStorer.Get(userID, context)
+		return len(roles) == 1 && roles[0] == "admin"
+	}
+
+	register := func(identity auth_identity.AuthIdentity) *claims.Claims {
+		if identity.Provider != "google" {
+			db.Create(&identity)
+		}
+
+		claims := identity.ToClaims()
+		if err := Auth.Bootstrap(context, claims); err != nil {
+			t.Fatal(err)
+		}
+		return claims
+	}
+
+	unconfirmed := register(auth_identity.AuthIdentity{Basic: auth_identity.Basic{Provider: "password", UID: "ops@example.com", UserID: "1"}})
+	if isAdmin("1") {
+		t.Errorf("expect no admin role granted to unconfirmed email")
+	}
+
+	// confirmed later, bootstrap again
+	db.Model(&auth_identity.AuthIdentity{}).Where("uid = ?", "ops@example.com").Update("confirmed_at", now)
+	if err := Auth.Bootstrap(context, unconfirmed); err != nil {
+		t.Fatal(err)
+	}
+	if !isAdmin("1") {
+		t.Errorf("expect admin role granted after email confirmed")
+	}
+
+	register(auth_identity.AuthIdentity{Basic: auth_identity.Basic{Provider: "email", UID: "OPS@example.com", UserID: "2", ConfirmedAt: &now}})
+	if isAdmin("2") {
+		t.Errorf("expect admin role granted once per email")
+	}
+
+	register(auth_identity.AuthIdentity{Basic: auth_identity.Basic{Provider: "google", UID: "oncall@example.com", UserID: "3"}})
+	if !isAdmin("3") {
+		t.Errorf("expect admin role granted to email of verified email provider")
+	}
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"html/template"
+
+	"github.com/qor/mailer"
+)
+
+// Branding branding of default views and emails, available as func `branding` in them, so basic white-labeling doesn't require template forks
+type Branding struct {
+	ProductName string
+	LogoURL     string
+	// PrimaryColor CSS color of buttons and links, like `#4f46e5`
+	PrimaryColor string
+	SupportURL   string
+}
+
+func (auth *Auth) branding() *Branding {
+	if auth.Config.Branding == nil {
+		return &Branding{}
+	}
+	return auth.Config.Branding
+}
+
+// MailTemplate build mail template with func `branding`, functions in funcMaps overwrite it
+func (context *Context) MailTemplate(name string, data interface{}, funcMaps ...template.FuncMap) mailer.Template {
+	funcMap := template.FuncMap{"branding": context.Auth.branding}
+	for _, fm := range funcMaps {
+		for key, fc := range fm {
+			funcMap[key] = fc
+		}
+	}
+
+	return mailer.Template{
+		Name:    name,
+		Data:    data,
+		Request: context.Request,
+		Writer:  context.Writer,
+	}.Funcs(funcMap)
+}
@@ -0,0 +1,23 @@
+package cache
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound cache not found error
+var ErrNotFound = errors.New("cache: not found")
+
+// Interface cache store interface, values are encoded when saving, and decoded into result when getting, so cached values won't be changed by callers
+type Interface interface {
+	// Get get cached value with key, decode it into result, return ErrNotFound if not found or expired
+	Get(key string, result interface{}) error
+	// Set save value with key, a zero ttl means never expire
+	Set(key string, value interface{}, ttl time.Duration) error
+	// SetNX save value with key only if the key doesn't exist or is expired, returns false if it exists, it is atomic, so only one of concurrent callers could set the key
+	SetNX(key string, value interface{}, ttl time.Duration) (bool, error)
+	// Increment increase integer value of key by 1, and reset its ttl, a missing or expired key starts from 0, it is atomic, so concurrent increments are all counted
+	Increment(key string, ttl time.Duration) (int64, error)
+	// Delete delete cached values with keys
+	Delete(keys ...string) error
+}
@@ -0,0 +1,155 @@
+package memory
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/qor/auth/cache"
+)
+
+// DefaultSweepInterval default interval of sweeping expired items
+const DefaultSweepInterval = time.Minute
+
+// New initialize in-memory cache store
+func New() *Memory {
+	return &Memory{SweepInterval: DefaultSweepInterval, items: map[string]item{}, sweptAt: time.Now()}
+}
+
+// Memory in-memory cache store, values are cached in current process, use a shared store like redis if you have multiple instances
+type Memory struct {
+	// SweepInterval expired items are removed by writes once per interval, so keys never read again, like nonces, don't pile up
+	SweepInterval time.Duration
+
+	mutex   sync.RWMutex
+	items   map[string]item
+	sweptAt time.Time
+}
+
+type item struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+var _ cache.Interface = &Memory{}
+
+// Get get cached value with key
+func (memory *Memory) Get(key string, result interface{}) error {
+	memory.mutex.RLock()
+	item, ok := memory.items[key]
+	memory.mutex.RUnlock()
+
+	if !ok {
+		return cache.ErrNotFound
+	}
+
+	if !item.expiresAt.IsZero() && item.expiresAt.Before(time.Now()) {
+		memory.Delete(key)
+		return cache.ErrNotFound
+	}
+
+	return json.Unmarshal(item.value, result)
+}
+
+// Set save value with key
+func (memory *Memory) Set(key string, value interface{}, ttl time.Duration) error {
+	result, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	item := item{value: result}
+	if ttl > 0 {
+		item.expiresAt = time.Now().Add(ttl)
+	}
+
+	memory.mutex.Lock()
+	memory.sweep(time.Now())
+	memory.items[key] = item
+	memory.mutex.Unlock()
+	return nil
+}
+
+// SetNX save value with key if it doesn't exist or is expired
+func (memory *Memory) SetNX(key string, value interface{}, ttl time.Duration) (bool, error) {
+	result, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	item := item{value: result}
+	if ttl > 0 {
+		item.expiresAt = now.Add(ttl)
+	}
+
+	memory.mutex.Lock()
+	defer memory.mutex.Unlock()
+	memory.sweep(now)
+
+	if existing, ok := memory.items[key]; ok && (existing.expiresAt.IsZero() || existing.expiresAt.After(now)) {
+		return false, nil
+	}
+	memory.items[key] = item
+	return true, nil
+}
+
+// Increment increase integer value of key by 1, and reset its ttl
+func (memory *Memory) Increment(key string, ttl time.Duration) (int64, error) {
+	now := time.Now()
+
+	memory.mutex.Lock()
+	defer memory.mutex.Unlock()
+	memory.sweep(now)
+
+	var count int64
+	if existing, ok := memory.items[key]; ok && (existing.expiresAt.IsZero() || existing.expiresAt.After(now)) {
+		if err := json.Unmarshal(existing.value, &count); err != nil {
+			return 0, err
+		}
+	}
+	count++
+
+	result, err := json.Marshal(count)
+	if err != nil {
+		return 0, err
+	}
+
+	item := item{value: result}
+	if ttl > 0 {
+		item.expiresAt = now.Add(ttl)
+	}
+	memory.items[key] = item
+	return count, nil
+}
+
+// Delete delete cached values with keys
+func (memory *Memory) Delete(keys ...string) error {
+	memory.mutex.Lock()
+	for _, key := range keys {
+		delete(memory.items, key)
+	}
+	memory.mutex.Unlock()
+	return nil
+}
+
+// Len number of cached items, including expired ones haven't been swept
+func (memory *Memory) Len() int {
+	memory.mutex.RLock()
+	defer memory.mutex.RUnlock()
+	return len(memory.items)
+}
+
+// sweep remove expired items if they haven't been swept in SweepInterval, callers must hold the write lock
+func (memory *Memory) sweep(now time.Time) {
+	if memory.SweepInterval <= 0 || now.Sub(memory.sweptAt) < memory.SweepInterval {
+		return
+	}
+
+	for key, item := range memory.items {
+		if !item.expiresAt.IsZero() && item.expiresAt.Before(now) {
+			delete(memory.items, key)
+		}
+	}
+	memory.sweptAt = now
+}
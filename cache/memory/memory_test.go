@@ -0,0 +1,61 @@
+package memory_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qor/auth/cache/memory"
+)
+
+func TestConcurrentIncrement(t *testing.T) {
+	var (
+		store = memory.New()
+		wg    sync.WaitGroup
+	)
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := store.Increment("failures", time.Minute); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var count int
+	if err := store.Get("failures", &count); err != nil || count != 50 {
+		t.Errorf("expect 50 increments counted, got %v, %v", count, err)
+	}
+}
+
+func TestIncrementExpiredKey(t *testing.T) {
+	store := memory.New()
+	store.Increment("failures", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if count, err := store.Increment("failures", time.Minute); err != nil || count != 1 {
+		t.Errorf("expect expired counter restarted from 0, got %v, %v", count, err)
+	}
+}
+
+func TestExpiredNoncesAreEvicted(t *testing.T) {
+	store := memory.New()
+	store.SweepInterval = 10 * time.Millisecond
+
+	for i := 0; i < 100; i++ {
+		if ok, err := store.SetNX(fmt.Sprintf("nonce:%v", i), true, time.Millisecond); !ok || err != nil {
+			t.Fatalf("expect nonce %v saved, got %v, %v", i, ok, err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	// nonces are never read again, they are removed by the next write
+	store.SetNX("nonce:latest", true, time.Minute)
+	if count := store.Len(); count != 1 {
+		t.Errorf("expect expired nonces evicted, got %v items", count)
+	}
+}
@@ -0,0 +1,81 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/qor/auth/cache"
+)
+
+// New initialize redis cache store, keys are prefixed with prefix
+func New(client redis.UniversalClient, prefix string) *Redis {
+	return &Redis{Client: client, Prefix: prefix}
+}
+
+// Redis redis cache store, could be shared by multiple instances
+type Redis struct {
+	Client redis.UniversalClient
+	Prefix string
+}
+
+var _ cache.Interface = &Redis{}
+
+// Get get cached value with key
+func (r *Redis) Get(key string, result interface{}) error {
+	value, err := r.Client.Get(context.Background(), r.Prefix+key).Bytes()
+	if err == redis.Nil {
+		return cache.ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal(value, result)
+}
+
+// Set save value with key
+func (r *Redis) Set(key string, value interface{}, ttl time.Duration) error {
+	result, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return r.Client.Set(context.Background(), r.Prefix+key, result, ttl).Err()
+}
+
+// SetNX save value with key if it doesn't exist, with redis' SETNX
+func (r *Redis) SetNX(key string, value interface{}, ttl time.Duration) (bool, error) {
+	result, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	return r.Client.SetNX(context.Background(), r.Prefix+key, result, ttl).Result()
+}
+
+// Increment increase integer value of key by 1 with redis' INCR, and reset its ttl with EXPIRE in the same transaction
+func (r *Redis) Increment(key string, ttl time.Duration) (int64, error) {
+	var incr *redis.IntCmd
+	_, err := r.Client.TxPipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(context.Background(), r.Prefix+key)
+		if ttl > 0 {
+			pipe.Expire(context.Background(), r.Prefix+key, ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// Delete delete cached values with keys
+func (r *Redis) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	prefixedKeys := make([]string, len(keys))
+	for idx, key := range keys {
+		prefixedKeys[idx] = r.Prefix + key
+	}
+	return r.Client.Del(context.Background(), prefixedKeys...).Err()
+}
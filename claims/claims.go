@@ -10,6 +10,9 @@ import (
 type Claims struct {
 	Provider                         string         `json:"provider,omitempty"`
 	UserID                           string         `json:"userid,omitempty"`
+	OrganizationID                   string         `json:"org_id,omitempty"`
+	OrganizationRole                 string         `json:"org_role,omitempty"`
+	SessionID                        string         `json:"sid,omitempty"`
 	LastLoginAt                      *time.Time     `json:"last_login,omitempty"`
 	LastActiveAt                     *time.Time     `json:"last_active,omitempty"`
 	LongestDistractionSinceLastLogin *time.Duration `json:"distraction_time,omitempty"`
@@ -25,3 +28,11 @@ func (claims *Claims) ToClaims() *Claims {
 type ClaimerInterface interface {
 	ToClaims() *Claims
 }
+
+// GetUserID get linked user's ID, return auth identity's UID if there is no user linked
+func (claims *Claims) GetUserID() string {
+	if claims.UserID != "" {
+		return claims.UserID
+	}
+	return claims.ID
+}
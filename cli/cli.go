@@ -0,0 +1,376 @@
+// Package cli commands for user and auth identity management against Auth's database, for ops tasks and initial provisioning, mount it into your application's binary like:
+//
+//	if len(os.Args) > 1 && os.Args[1] == "auth" {
+//		if err := cli.New(&cli.Config{Auth: Auth}).Run(os.Args[2:]); err != nil {
+//			fmt.Fprintln(os.Stderr, err)
+//			os.Exit(1)
+//		}
+//		return
+//	}
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/qor/auth"
+	"github.com/qor/auth/claims"
+	"github.com/qor/qor/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnknownCommand unknown command
+var ErrUnknownCommand = errors.New("unknown command")
+
+// Config cli config
+type Config struct {
+	Auth *auth.Auth
+	// Output where command results are written, default value is os.Stdout
+	Output io.Writer
+	// Provider provider name of auth identities created for email, password, default value is `password`
+	Provider string
+	// EncryptPassword encrypt password before saving it into auth identity, default is bcrypt with default cost
+	EncryptPassword func(password string) (string, error)
+}
+
+// New initialize cli, panics if config is invalid, use NewWithError to handle the error
+func New(config *Config) *CLI {
+	cli, err := NewWithError(config)
+	if err != nil {
+		panic(err)
+	}
+	return cli
+}
+
+// NewWithError initialize cli, returns a *auth.ConfigError lists all problems if config is invalid
+func NewWithError(config *Config) (*CLI, error) {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if config.Output == nil {
+		config.Output = os.Stdout
+	}
+
+	if config.Provider == "" {
+		config.Provider = "password"
+	}
+
+	if config.EncryptPassword == nil {
+		config.EncryptPassword = func(password string) (string, error) {
+			encrypted, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+			return string(encrypted), err
+		}
+	}
+
+	cli := &CLI{Config: config}
+	cli.commands = map[string]command{
+		"create-user":     {"create user with email, password", cli.createUser},
+		"set-password":    {"set password of email", cli.setPassword},
+		"confirm-email":   {"mark email as confirmed", cli.confirmEmail},
+		"assign-role":     {"grant role to user, or revoke it with -remove", cli.assignRole},
+		"revoke-sessions": {"revoke all sessions of user", cli.revokeSessions},
+		"list-identities": {"list auth identities of user", cli.listIdentities},
+	}
+	return cli, nil
+}
+
+// Validate validate config
+func (config *Config) Validate() error {
+	configErr := &auth.ConfigError{Name: "cli"}
+	if config.Auth == nil {
+		configErr.Add("Auth must be specified")
+	}
+	return configErr.Err()
+}
+
+// CLI user and auth identity management commands
+type CLI struct {
+	*Config
+	commands map[string]command
+}
+
+type command struct {
+	usage string
+	run   func(context *auth.Context, args []string) error
+}
+
+// Run run command with args, like `[]string{"create-user", "-email", "admin@example.com", "-password", "secret"}`
+func (cli *CLI) Run(args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" {
+		cli.usage()
+		return nil
+	}
+
+	cmd, ok := cli.commands[args[0]]
+	if !ok {
+		cli.usage()
+		return fmt.Errorf("%v: %v", ErrUnknownCommand, args[0])
+	}
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	context := &auth.Context{Auth: cli.Auth, Request: req}
+	return cmd.run(context, args[1:])
+}
+
+func (cli *CLI) usage() {
+	var names []string
+	for name := range cli.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(cli.Output, "Commands:")
+	for _, name := range names {
+		fmt.Fprintf(cli.Output, "  %-16v %v\n", name, cli.commands[name].usage)
+	}
+}
+
+func (cli *CLI) flagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(cli.Output)
+	return flags
+}
+
+func (cli *CLI) createUser(context *auth.Context, args []string) error {
+	var (
+		flags     = cli.flagSet("create-user")
+		email     = flags.String("email", "", "user's email, required")
+		name      = flags.String("name", "", "user's name")
+		password  = flags.String("password", "", "user's password, required")
+		confirmed = flags.Bool("confirmed", false, "mark email as confirmed")
+		roles     = flags.String("role", "", "grant role to created user")
+	)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *email == "" || *password == "" {
+		return errors.New("create-user: -email, -password are required")
+	}
+
+	encryptedPassword, err := cli.EncryptPassword(*password)
+	if err != nil {
+		return err
+	}
+
+	var userID string
+	err = cli.Auth.Transaction(context, func(context *auth.Context) (err error) {
+		schema := auth.Schema{Provider: cli.Provider, UID: *email, Email: *email, Name: *name}
+		if _, userID, err = cli.Auth.UserStorer.Save(&schema, context); err != nil {
+			return err
+		}
+
+		identity := cli.Auth.NewAuthIdentity()
+		value := utils.Indirect(reflect.ValueOf(identity))
+		value.FieldByName("Provider").SetString(cli.Provider)
+		value.FieldByName("UID").SetString(*email)
+		value.FieldByName("UserID").SetString(userID)
+		value.FieldByName("EncryptedPassword").SetString(encryptedPassword)
+		if *confirmed {
+			now := cli.Auth.Now()
+			value.FieldByName("ConfirmedAt").Set(reflect.ValueOf(&now))
+		}
+
+		if err = cli.Auth.IdentityStore.Create(context, identity); err != nil {
+			return err
+		}
+
+		if *roles != "" {
+			return cli.Auth.RoleStorer.Add(userID, *roles, context)
+		}
+		return nil
+	})
+
+	if err == nil {
+		fmt.Fprintf(cli.Output, "created user %v, ID: %v\n", *email, userID)
+	}
+	return err
+}
+
+func (cli *CLI) setPassword(context *auth.Context, args []string) error {
+	var (
+		flags    = cli.flagSet("set-password")
+		email    = flags.String("email", "", "user's email, required")
+		password = flags.String("password", "", "new password, required")
+	)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *email == "" || *password == "" {
+		return errors.New("set-password: -email, -password are required")
+	}
+
+	encryptedPassword, err := cli.EncryptPassword(*password)
+	if err != nil {
+		return err
+	}
+
+	return cli.updateIdentity(context, *email, func(value reflect.Value) {
+		value.FieldByName("EncryptedPassword").SetString(encryptedPassword)
+	})
+}
+
+func (cli *CLI) confirmEmail(context *auth.Context, args []string) error {
+	var (
+		flags = cli.flagSet("confirm-email")
+		email = flags.String("email", "", "user's email, required")
+	)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *email == "" {
+		return errors.New("confirm-email: -email is required")
+	}
+
+	return cli.updateIdentity(context, *email, func(value reflect.Value) {
+		now := cli.Auth.Now()
+		value.FieldByName("ConfirmedAt").Set(reflect.ValueOf(&now))
+	})
+}
+
+func (cli *CLI) assignRole(context *auth.Context, args []string) error {
+	var (
+		flags  = cli.flagSet("assign-role")
+		userID = flags.String("user-id", "", "user's ID, or use -email")
+		email  = flags.String("email", "", "user's email")
+		role   = flags.String("role", "", "role name, required")
+		remove = flags.Bool("remove", false, "revoke the role instead")
+	)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *role == "" {
+		return errors.New("assign-role: -role is required")
+	}
+
+	id, err := cli.userID(context, *userID, *email)
+	if err != nil {
+		return err
+	}
+
+	if *remove {
+		err = cli.Auth.RoleStorer.Remove(id, *role, context)
+	} else {
+		err = cli.Auth.RoleStorer.Add(id, *role, context)
+	}
+
+	if err == nil {
+		roles, _ := cli.Auth.RoleStorer.Get(id, context)
+		fmt.Fprintf(cli.Output, "user %v roles: %v\n", id, roles)
+	}
+	return err
+}
+
+func (cli *CLI) revokeSessions(context *auth.Context, args []string) error {
+	var (
+		flags  = cli.flagSet("revoke-sessions")
+		userID = flags.String("user-id", "", "user's ID, or use -email")
+		email  = flags.String("email", "", "user's email")
+	)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	id, err := cli.userID(context, *userID, *email)
+	if err != nil {
+		return err
+	}
+
+	if err = cli.Auth.RevokeSessions(context.Request, id); err == nil {
+		fmt.Fprintf(cli.Output, "revoked sessions of user %v\n", id)
+	}
+	return err
+}
+
+func (cli *CLI) listIdentities(context *auth.Context, args []string) error {
+	var (
+		flags  = cli.flagSet("list-identities")
+		userID = flags.String("user-id", "", "user's ID, or use -email")
+		email  = flags.String("email", "", "user's email")
+	)
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	id, err := cli.userID(context, *userID, *email)
+	if err != nil {
+		return err
+	}
+
+	identities, err := cli.Auth.IdentityStore.FindByUserID(context, id)
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(cli.Output, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "PROVIDER\tUID\tCONFIRMED AT")
+	for _, identity := range identities {
+		var (
+			value       = utils.Indirect(reflect.ValueOf(identity))
+			confirmedAt = "-"
+		)
+
+		if t, ok := value.FieldByName("ConfirmedAt").Interface().(*time.Time); ok && t != nil {
+			confirmedAt = t.Format(time.RFC3339)
+		}
+		fmt.Fprintf(writer, "%v\t%v\t%v\n", value.FieldByName("Provider").String(), value.FieldByName("UID").String(), confirmedAt)
+	}
+	return writer.Flush()
+}
+
+// updateIdentity update email's auth identity of Provider
+func (cli *CLI) updateIdentity(context *auth.Context, email string, update func(value reflect.Value)) error {
+	identity, err := cli.Auth.IdentityStore.FindByProviderUID(context, cli.Provider, email)
+	if err != nil {
+		return fmt.Errorf("%v: %v", email, err)
+	}
+
+	update(utils.Indirect(reflect.ValueOf(identity)))
+	if err = cli.Auth.IdentityStore.Update(context, identity); err == nil {
+		fmt.Fprintf(cli.Output, "updated %v\n", email)
+	}
+	return err
+}
+
+// userID get user ID from flags, user is found with email's auth identity if user ID is blank
+func (cli *CLI) userID(context *auth.Context, userID, email string) (string, error) {
+	if userID != "" {
+		return userID, nil
+	}
+
+	if email == "" {
+		return "", errors.New("-user-id or -email is required")
+	}
+
+	identity, err := cli.Auth.IdentityStore.FindByProviderUID(context, cli.Provider, email)
+	if err != nil {
+		return "", fmt.Errorf("%v: %v", email, err)
+	}
+
+	if claimer, ok := identity.(claims.ClaimerInterface); ok {
+		return claimer.ToClaims().GetUserID(), nil
+	}
+	return "", fmt.Errorf("%v: %v", email, auth.ErrInvalidAccount)
+}
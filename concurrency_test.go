@@ -0,0 +1,205 @@
+package auth_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/qor/auth"
+	"github.com/qor/auth/claims"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// run these tests with `go test -race`
+
+const workers = 50
+
+type redirector struct{}
+
+func (redirector) Redirect(w http.ResponseWriter, req *http.Request, action string) {}
+
+type provider struct{ name string }
+
+func (p provider) GetName() string        { return p.name }
+func (provider) ConfigAuth(*auth.Auth)    {}
+func (provider) Login(*auth.Context)      {}
+func (provider) Logout(*auth.Context)     {}
+func (provider) Register(*auth.Context)   {}
+func (provider) Deregister(*auth.Context) {}
+func (provider) Callback(*auth.Context)   {}
+func (provider) ServeHTTP(*auth.Context)  {}
+func newProvider(idx int) provider        { return provider{name: fmt.Sprintf("provider_%v", idx)} }
+func newContext(Auth *auth.Auth) *auth.Context {
+	return &auth.Context{Auth: Auth, Request: httptest.NewRequest("GET", "/", nil)}
+}
+
+func newAuth(t *testing.T) *auth.Auth {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return auth.New(&auth.Config{DB: db, Redirector: redirector{}})
+}
+
+func TestConcurrentProviderRegistration(t *testing.T) {
+	var (
+		Auth = newAuth(t)
+		wg   sync.WaitGroup
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func(idx int) {
+			defer wg.Done()
+			Auth.RegisterProvider(newProvider(idx))
+		}(i)
+
+		go func(idx int) {
+			defer wg.Done()
+			Auth.GetProvider(newProvider(idx).GetName())
+			Auth.GetProviders()
+		}(i)
+	}
+	wg.Wait()
+
+	if providers := Auth.GetProviders(); len(providers) != workers {
+		t.Errorf("expect %v providers registered, got %v", workers, len(providers))
+	}
+
+	for i := 0; i < workers; i++ {
+		if Auth.GetProvider(newProvider(i).GetName()) == nil {
+			t.Errorf("provider %v not registered", newProvider(i).GetName())
+		}
+	}
+}
+
+func TestConcurrentHookRegistration(t *testing.T) {
+	var (
+		Auth = newAuth(t)
+		wg   sync.WaitGroup
+		runs int64
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			Auth.RegisterHook(auth.BeforeLogin, func(*auth.Context, interface{}) error {
+				atomic.AddInt64(&runs, 1)
+				return nil
+			})
+		}()
+
+		go func() {
+			defer wg.Done()
+			if err := Auth.RunHooks(auth.BeforeLogin, newContext(Auth), nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	atomic.StoreInt64(&runs, 0)
+	if err := Auth.RunHooks(auth.BeforeLogin, newContext(Auth), nil); err != nil {
+		t.Error(err)
+	}
+
+	if runs != workers {
+		t.Errorf("expect %v hooks run, got %v", workers, runs)
+	}
+}
+
+func TestConcurrentPublishSubscribe(t *testing.T) {
+	var (
+		Auth     = newAuth(t)
+		wg       sync.WaitGroup
+		received int64
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			Auth.Subscribe(auth.EventLogin, func(event *auth.Event) {
+				if event.Claims != nil && event.Claims.UserID == "alice" {
+					atomic.AddInt64(&received, 1)
+				}
+			})
+		}()
+
+		go func() {
+			defer wg.Done()
+			context := newContext(Auth)
+			context.Claims = &claims.Claims{UserID: "bob"}
+			Auth.Publish(auth.EventLogin, context, map[string]interface{}{"provider": "password"})
+		}()
+	}
+	wg.Wait()
+
+	context := newContext(Auth)
+	context.Claims = &claims.Claims{UserID: "alice"}
+	Auth.Publish(auth.EventLogin, context, nil)
+
+	if received != workers {
+		t.Errorf("expect event received by %v handlers, got %v", workers, received)
+	}
+}
+
+func TestConcurrentSigningKeyRotation(t *testing.T) {
+	var (
+		mutex        sync.RWMutex
+		keys         = []string{"secret-0"}
+		wg           sync.WaitGroup
+		sessionStore = &auth.SessionStorer{
+			SigningMethod: jose.HS256,
+			SigningKeys: func() []string {
+				mutex.RLock()
+				defer mutex.RUnlock()
+				return keys
+			},
+		}
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func(idx int) {
+			defer wg.Done()
+			// prepend the new secret, keep previous ones, so issued tokens are still valid
+			mutex.Lock()
+			keys = append([]string{fmt.Sprintf("secret-%v", idx+1)}, keys...)
+			mutex.Unlock()
+		}(i)
+
+		go func(idx int) {
+			defer wg.Done()
+			userID := fmt.Sprint(idx)
+			token, err := sessionStore.SignedToken(&claims.Claims{UserID: userID})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if result, err := sessionStore.ValidateClaims(token); err != nil {
+				t.Errorf("token signed during rotation should be valid, got %v", err)
+			} else if result.UserID != userID {
+				t.Errorf("expect user %v, got %v", userID, result.UserID)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	previous, err := (&auth.SessionStorer{SigningMethod: jose.HS256, SignedString: "secret-0"}).SignedToken(&claims.Claims{UserID: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sessionStore.ValidateClaims(previous); err != nil {
+		t.Errorf("token signed with previous secret should be valid after rotated, got %v", err)
+	}
+}
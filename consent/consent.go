@@ -0,0 +1,85 @@
+package consent
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/qor/auth"
+)
+
+func init() {
+	auth.RegisterTables("consents")
+	auth.RegisterMigration(auth.Migration{ID: "consent/001_create_consents", Migrate: func(db *gorm.DB) error {
+		return db.AutoMigrate(&Consent{}).Error
+	}})
+}
+
+const (
+	// EventConsentGranted user granted scopes to a client
+	EventConsentGranted = "consent.granted"
+	// EventConsentDenied user denied scopes requested by a client
+	EventConsentDenied = "consent.denied"
+	// EventConsentRevoked user revoked consent of a client, or some scopes of it if data has `scopes`
+	EventConsentRevoked = "consent.revoked"
+)
+
+// Consent user's consent decision of scopes requested by a client
+type Consent struct {
+	gorm.Model
+	UserID   string `gorm:"unique_index:uix_consents_user_id_client_id"`
+	ClientID string `gorm:"unique_index:uix_consents_user_id_client_id"`
+	// Scopes granted scopes, separated by space
+	Scopes    string `gorm:"type:text"`
+	GrantedAt time.Time
+}
+
+// GetScopes get granted scopes
+func (consent Consent) GetScopes() []string {
+	return strings.Fields(consent.Scopes)
+}
+
+// Covers check all scopes are granted
+func (consent Consent) Covers(scopes []string) bool {
+	granted := map[string]bool{}
+	for _, scope := range consent.GetScopes() {
+		granted[scope] = true
+	}
+
+	for _, scope := range scopes {
+		if !granted[scope] {
+			return false
+		}
+	}
+	return true
+}
+
+// Missing get scopes haven't been granted
+func (consent Consent) Missing(scopes []string) []string {
+	var missing []string
+	for _, scope := range scopes {
+		if !consent.Covers([]string{scope}) {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+// ParseScopes parse scopes separated by space or comma, duplicated scopes are removed, results are sorted
+func ParseScopes(value string) []string {
+	var (
+		scopes []string
+		seen   = map[string]bool{}
+	)
+
+	for _, scope := range strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == ',' }) {
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	sort.Strings(scopes)
+	return scopes
+}
@@ -0,0 +1,329 @@
+package consent
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/qor/auth"
+	"github.com/qor/responder"
+)
+
+// ErrInvalidReturnTo return_to must be a local path
+var ErrInvalidReturnTo = errors.New("invalid return_to")
+
+// Scope describes what will be shared when a scope is granted, shown on consent page
+type Scope struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Config consent provider config
+type Config struct {
+	// Scopes descriptions of known scopes, unknown scopes are shown with their names
+	Scopes []Scope
+	// ClientName get display name of client, default value is client ID, or registered client's name if used with oauth_server
+	ClientName func(context *auth.Context, clientID string) string
+}
+
+// New initialize consent provider
+func New(config *Config) *Provider {
+	if config == nil {
+		config = &Config{}
+	}
+
+	return &Provider{Config: config}
+}
+
+// Provider consent provider, render consent page for requested scopes and save user's decision,
+// the prompt is skipped on subsequent logins unless requested scopes changed
+type Provider struct {
+	*Config
+	Auth *auth.Auth
+}
+
+// GetName return provider name
+func (Provider) GetName() string {
+	return "consent"
+}
+
+// ConfigAuth config auth
+func (provider *Provider) ConfigAuth(auth *auth.Auth) {
+	provider.Auth = auth
+}
+
+// Login consent provider doesn't support login
+func (provider Provider) Login(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Logout consent provider doesn't support logout
+func (provider Provider) Logout(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Register consent provider doesn't support register
+func (provider Provider) Register(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Deregister consent provider doesn't support deregister
+func (provider Provider) Deregister(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Callback consent provider doesn't support callback
+func (provider Provider) Callback(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// ServeHTTP serve consent endpoints
+//
+//	GET  {Auth Prefix}/consent/prompt   show consent page for `client_id`, `scope`, `return_to`, only scopes haven't been granted are shown
+//	POST {Auth Prefix}/consent/approve  grant `scope` to `client_id`, then redirect to `return_to`
+//	POST {Auth Prefix}/consent/deny     redirect to `return_to` with `error=access_denied`
+//	GET  {Auth Prefix}/consent/apps     connected apps page, list clients current user granted scopes to, respond JSON for JSON requests
+//	POST {Auth Prefix}/consent/revoke   revoke consent of `client_id`, or only `scope` of it if present
+func (provider Provider) ServeHTTP(context *auth.Context) {
+	var (
+		req     = context.Request
+		w       = context.Writer
+		reqPath = strings.TrimPrefix(req.URL.Path, context.Auth.URLPrefix)
+		paths   = strings.Split(reqPath, "/")
+	)
+
+	if len(paths) < 2 {
+		http.NotFound(w, req)
+		return
+	}
+
+	req.ParseForm()
+	if paths[1] != "prompt" && paths[1] != "apps" && req.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, err := context.Auth.SessionStorer.Get(req)
+	if err != nil {
+		http.Error(w, context.TranslateError(auth.ErrUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	context.Claims = claims
+
+	var (
+		clientID = context.FormValue("client_id")
+		scopes   = ParseScopes(context.FormValue("scope"))
+		returnTo = context.FormValue("return_to")
+	)
+
+	if paths[1] != "revoke" && paths[1] != "apps" && !auth.IsLocalPath(returnTo) {
+		http.Error(w, context.TranslateError(ErrInvalidReturnTo), http.StatusBadRequest)
+		return
+	}
+
+	switch paths[1] {
+	case "prompt":
+		// already granted scopes won't be asked again
+		newScopes := scopes
+		if consent, err := provider.GetConsent(context, claims.GetUserID(), clientID); err == nil {
+			newScopes = consent.Missing(scopes)
+		}
+
+		context.Execute("auth/consent/prompt", template.FuncMap{
+			"client_name": func() string { return provider.clientName(context, clientID) },
+			"client_id":   func() string { return clientID },
+			"scopes":      func() []Scope { return provider.describe(newScopes) },
+			"scope":       func() string { return strings.Join(scopes, " ") },
+			"return_to":   func() string { return returnTo },
+		})
+	case "approve":
+		if err := provider.Grant(context, claims.GetUserID(), clientID, scopes); err != nil {
+			http.Error(w, context.TranslateError(err), http.StatusUnprocessableEntity)
+			return
+		}
+		http.Redirect(w, req, returnTo, http.StatusSeeOther)
+	case "deny":
+		context.Auth.Publish(EventConsentDenied, context, map[string]interface{}{"client_id": clientID, "scopes": scopes})
+		redirectURL, _ := url.Parse(returnTo)
+		query := redirectURL.Query()
+		query.Set("error", "access_denied")
+		redirectURL.RawQuery = query.Encode()
+		http.Redirect(w, req, redirectURL.String(), http.StatusSeeOther)
+	case "apps":
+		apps, err := provider.ConnectedApps(context, claims.GetUserID())
+		if err != nil {
+			http.Error(w, context.TranslateError(err), http.StatusInternalServerError)
+			return
+		}
+
+		responder.With("html", func() {
+			context.Execute("auth/consent/apps", template.FuncMap{
+				"apps": func() []ConnectedApp { return apps },
+			})
+		}).With([]string{"json"}, func() {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(apps)
+		}).Respond(req)
+	case "revoke":
+		if len(scopes) > 0 {
+			err = provider.RevokeScopes(context, claims.GetUserID(), clientID, scopes)
+		} else {
+			err = provider.Revoke(context, claims.GetUserID(), clientID)
+		}
+
+		if err != nil {
+			http.Error(w, context.TranslateError(err), http.StatusUnprocessableEntity)
+			return
+		}
+
+		responder.With("html", func() {
+			context.Auth.Redirector.Redirect(w, req, "revoke_consent")
+		}).With([]string{"json"}, func() {
+			w.WriteHeader(http.StatusNoContent)
+		}).Respond(req)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// GetConsent get user's consent of client
+func (provider Provider) GetConsent(context *auth.Context, userID string, clientID string) (*Consent, error) {
+	var consent Consent
+	err := context.Auth.GetDB(context.Request).Where("user_id = ? AND client_id = ?", userID, clientID).First(&consent).Error
+	return &consent, err
+}
+
+// GetConsents get user's consents of all clients
+func (provider Provider) GetConsents(context *auth.Context, userID string) ([]Consent, error) {
+	var consents []Consent
+	err := context.Auth.GetReadDB(context.Request).Where("user_id = ?", userID).Order("granted_at DESC").Find(&consents).Error
+	return consents, err
+}
+
+// ConnectedApp client user granted scopes to, listed on connected apps page
+type ConnectedApp struct {
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []Scope   `json:"scopes"`
+	GrantedAt  time.Time `json:"granted_at"`
+}
+
+// ConnectedApps get clients user granted scopes to, with their display names and scopes' descriptions
+func (provider Provider) ConnectedApps(context *auth.Context, userID string) ([]ConnectedApp, error) {
+	consents, err := provider.GetConsents(context, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	apps := make([]ConnectedApp, len(consents))
+	for idx, consent := range consents {
+		apps[idx] = ConnectedApp{
+			ClientID:   consent.ClientID,
+			ClientName: provider.clientName(context, consent.ClientID),
+			Scopes:     provider.describe(consent.GetScopes()),
+			GrantedAt:  consent.GrantedAt,
+		}
+	}
+	return apps, nil
+}
+
+// Granted check user has granted all scopes to client
+func (provider Provider) Granted(context *auth.Context, userID string, clientID string, scopes []string) bool {
+	consent, err := provider.GetConsent(context, userID, clientID)
+	return err == nil && consent.Covers(scopes)
+}
+
+// Require check current user has granted scopes to client, redirect to consent page and return false if not, e.g:
+//
+//	if !Consent.Require(context, clientID, scopes, req.URL.RequestURI()) {
+//	  return
+//	}
+func (provider Provider) Require(context *auth.Context, clientID string, scopes []string, returnTo string) bool {
+	claims, err := context.Auth.SessionStorer.Get(context.Request)
+	if err == nil && provider.Granted(context, claims.GetUserID(), clientID, scopes) {
+		return true
+	}
+
+	promptURL := url.URL{
+		Path:     context.Auth.AuthURL("consent/prompt"),
+		RawQuery: url.Values{"client_id": []string{clientID}, "scope": []string{strings.Join(scopes, " ")}, "return_to": []string{returnTo}}.Encode(),
+	}
+	http.Redirect(context.Writer, context.Request, promptURL.String(), http.StatusSeeOther)
+	return false
+}
+
+// Grant save user's decision granting scopes to client, previously granted scopes are kept
+func (provider Provider) Grant(context *auth.Context, userID string, clientID string, scopes []string) error {
+	var (
+		tx      = context.Auth.GetDB(context.Request)
+		consent Consent
+	)
+
+	tx.Where("user_id = ? AND client_id = ?", userID, clientID).First(&consent)
+	consent.UserID, consent.ClientID, consent.GrantedAt = userID, clientID, context.Auth.Now()
+	consent.Scopes = strings.Join(ParseScopes(consent.Scopes+" "+strings.Join(scopes, " ")), " ")
+
+	if err := tx.Save(&consent).Error; err != nil {
+		return err
+	}
+
+	context.Auth.Publish(EventConsentGranted, context, map[string]interface{}{"client_id": clientID, "scopes": scopes})
+	return nil
+}
+
+// Revoke revoke user's consent of client, user will be prompted again
+func (provider Provider) Revoke(context *auth.Context, userID string, clientID string) error {
+	if err := context.Auth.GetDB(context.Request).Unscoped().Where("user_id = ? AND client_id = ?", userID, clientID).Delete(&Consent{}).Error; err != nil {
+		return err
+	}
+
+	context.Auth.Publish(EventConsentRevoked, context, map[string]interface{}{"client_id": clientID, "user_id": userID})
+	return nil
+}
+
+// RevokeScopes revoke some scopes user granted to client, the consent is revoked if no scopes left
+func (provider Provider) RevokeScopes(context *auth.Context, userID string, clientID string, scopes []string) error {
+	consent, err := provider.GetConsent(context, userID, clientID)
+	if err != nil {
+		return err
+	}
+
+	revoked := Consent{Scopes: strings.Join(scopes, " ")}
+	remaining := revoked.Missing(consent.GetScopes())
+
+	if len(remaining) == 0 {
+		return provider.Revoke(context, userID, clientID)
+	}
+
+	if err := context.Auth.GetDB(context.Request).Model(consent).Update("scopes", strings.Join(remaining, " ")).Error; err != nil {
+		return err
+	}
+
+	context.Auth.Publish(EventConsentRevoked, context, map[string]interface{}{"client_id": clientID, "user_id": userID, "scopes": scopes})
+	return nil
+}
+
+func (provider Provider) clientName(context *auth.Context, clientID string) string {
+	if provider.ClientName != nil {
+		return provider.ClientName(context, clientID)
+	}
+	return clientID
+}
+
+func (provider Provider) describe(scopes []string) []Scope {
+	results := make([]Scope, len(scopes))
+	for idx, name := range scopes {
+		results[idx] = Scope{Name: name, Description: name}
+		for _, scope := range provider.Scopes {
+			if scope.Name == name {
+				results[idx] = scope
+				break
+			}
+		}
+	}
+	return results
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/qor/auth/claims"
+	"github.com/qor/auth/i18n"
 	"github.com/qor/session"
 )
 
@@ -14,6 +15,8 @@ type Context struct {
 	Provider Provider
 	Request  *http.Request
 	Writer   http.ResponseWriter
+
+	cspNonce string
 }
 
 // Flashes get flash messages
@@ -21,6 +24,26 @@ func (context Context) Flashes() []session.Message {
 	return context.Auth.SessionStorer.Flashes(context.Writer, context.Request)
 }
 
+// Locale get request's locale
+func (context Context) Locale() string {
+	return context.Auth.I18n.Locale(context.Request)
+}
+
+// Direction text direction of request's locale, `rtl` or `ltr`, used in views like `<div dir="{{.Direction}}">`
+func (context Context) Direction() string {
+	return i18n.Direction(context.Locale())
+}
+
+// T translate key to request's locale, could be used in views like `{{.T "auth.flash.logged"}}`
+func (context Context) T(key string, args ...interface{}) string {
+	return context.Auth.I18n.T(context.Locale(), key, args...)
+}
+
+// TranslateError translate error to request's locale, errors are translated with their messages as keys
+func (context Context) TranslateError(err error) string {
+	return context.T(err.Error())
+}
+
 // FormValue get form value with name
 func (context Context) FormValue(name string) string {
 	return context.Request.Form.Get(name)
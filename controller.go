@@ -26,6 +26,13 @@ func (serveMux *serveMux) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		context = &Context{Auth: serveMux.Auth, Claims: claims, Request: req, Writer: w}
 	)
 
+	if headers := serveMux.Auth.Config.SecurityHeaders; headers != nil {
+		headers.Apply(w)
+		if headers.Nonce && headers.ContentSecurityPolicy != "" {
+			w.Header().Set("Content-Security-Policy", headers.PolicyWithNonce(context.CSPNonce()))
+		}
+	}
+
 	if len(paths) >= 2 {
 		// render assets
 		if paths[0] == "assets" {
@@ -37,13 +44,24 @@ func (serveMux *serveMux) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		if provider := serveMux.Auth.GetProvider(paths[0]); provider != nil {
 			context.Provider = provider
 
+			if !serveMux.Auth.AllowsIP(req, provider.GetName()) {
+				serveMux.Auth.respondForbidden(context, ErrIPNotAllowed)
+				return
+			}
+
+			if !serveMux.validateCSRFToken(context) {
+				return
+			}
+
 			// serve mux
 			switch paths[1] {
 			case "login":
+				context.SaveReturnTo()
 				provider.Login(context)
 			case "logout":
 				provider.Logout(context)
 			case "register":
+				context.SaveReturnTo()
 				provider.Register(context)
 			case "deregister":
 				provider.Deregister(context)
@@ -55,20 +73,34 @@ func (serveMux *serveMux) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 	} else if len(paths) == 1 {
+		if !serveMux.Auth.AllowsIP(req, "") {
+			serveMux.Auth.respondForbidden(context, ErrIPNotAllowed)
+			return
+		}
+
+		if !serveMux.validateCSRFToken(context) {
+			return
+		}
+
 		// eg: /login, /logout
 		switch paths[0] {
 		case "login":
-			// render login page
-			serveMux.Auth.Render.Execute("auth/login", context, req, w)
+			// render login page, remember where to return after logged in
+			context.SaveReturnTo()
+			context.Execute("auth/login")
 		case "register":
 			// render register page
-			serveMux.Auth.Render.Execute("auth/register", context, req, w)
+			context.SaveReturnTo()
+			context.Execute("auth/register")
 		case "deregister":
 			// remove user from database
 			serveMux.Auth.DeregisterHandler(context)
 		case "logout":
 			// destroy login context
 			serveMux.Auth.LogoutHandler(context)
+		case "csrf_token":
+			// respond CSRF token for JSON clients
+			serveMux.Auth.csrfTokenHandler(context)
 		default:
 			http.NotFound(w, req)
 		}
@@ -78,6 +110,22 @@ func (serveMux *serveMux) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	http.NotFound(w, req)
 }
 
+// validateCSRFToken validate request's CSRF token, respond failure and return false if invalid,
+// token cookie is set for safe requests, so it is sent before pages' contents
+func (serveMux *serveMux) validateCSRFToken(context *Context) bool {
+	if serveMux.Auth.Config.CSRF == nil {
+		return true
+	}
+
+	if err := context.ValidateCSRFToken(); err != nil {
+		serveMux.Auth.respondForbidden(context, err)
+		return false
+	}
+
+	context.CSRFToken()
+	return true
+}
+
 // AuthURL generate URL for auth
 func (auth *Auth) AuthURL(pth string) string {
 	return path.Join(auth.URLPrefix, pth)
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/sessions"
+	"github.com/qor/session"
+	"github.com/qor/session/gorilla"
+	"github.com/qor/session/manager"
+)
+
+const (
+	// HostCookiePrefix cookies with the prefix must be Secure, Path `/`, without Domain, so they couldn't be set by subdomains or plain HTTP pages
+	HostCookiePrefix = "__Host-"
+	// SecureCookiePrefix cookies with the prefix must be Secure, Domain is allowed
+	SecureCookiePrefix = "__Secure-"
+)
+
+// CookiePolicy strict cookie policy, auth's cookies, like device, CSRF, return_to cookies are named with the prefix,
+// and are always Secure, HttpOnly, with Path `/` and SameSite, NewWithError refuses insecure combinations, like SameSite None, default session manager with hard-coded key
+type CookiePolicy struct {
+	// Prefix cookie names' prefix, HostCookiePrefix or SecureCookiePrefix, default value is HostCookiePrefix
+	Prefix string
+	// Domain domain of cookies, only allowed with SecureCookiePrefix
+	Domain string
+	// SameSite SameSite of cookies, default value is http.SameSiteLaxMode, cookies asked for strict mode are kept strict, http.SameSiteNoneMode isn't allowed
+	SameSite http.SameSite
+	// Development cookie names aren't prefixed and Secure isn't forced, so it works with plain HTTP on localhost, never enable it in production
+	Development bool
+}
+
+// Name cookie name with policy's prefix
+func (policy *CookiePolicy) Name(name string) string {
+	if policy == nil || policy.Development {
+		return name
+	}
+	return policy.prefix() + name
+}
+
+// Apply apply policy to cookie, cookie's name is prefixed, Secure, HttpOnly, Path, Domain, SameSite are overwritten,
+// without policy, cookie is Secure if request is HTTPS
+func (policy *CookiePolicy) Apply(req *http.Request, cookie *http.Cookie) {
+	if policy == nil {
+		cookie.Secure = cookie.Secure || req.TLS != nil
+		return
+	}
+
+	cookie.Name = policy.Name(cookie.Name)
+	cookie.Secure = !policy.Development || req.TLS != nil
+	cookie.HttpOnly = true
+	cookie.Path = "/"
+	cookie.Domain = ""
+	if policy.prefix() == SecureCookiePrefix {
+		cookie.Domain = policy.Domain
+	}
+
+	if cookie.SameSite != http.SameSiteStrictMode {
+		cookie.SameSite = policy.sameSite()
+	}
+}
+
+// SessionManager session manager saves session into cookie `{Prefix}_session` applied the policy, keyPairs are authentication, encryption keys of gorilla's CookieStore, e.g:
+//
+//	Auth := auth.New(&auth.Config{
+//	  CookiePolicy:  policy,
+//	  SessionStorer: &auth.SessionStorer{SessionName: "_auth_session", SessionManager: policy.SessionManager([]byte(os.Getenv("SESSION_KEY"))), SigningMethod: jose.HS256, SignedString: os.Getenv("SIGNING_KEY")},
+//	})
+//
+// mount its middleware into your router like the default session manager
+func (policy *CookiePolicy) SessionManager(keyPairs ...[]byte) session.ManagerInterface {
+	store := sessions.NewCookieStore(keyPairs...)
+	store.Options = &sessions.Options{
+		Path:     "/",
+		MaxAge:   86400 * 30,
+		Secure:   !policy.Development,
+		HttpOnly: true,
+		SameSite: policy.sameSite(),
+	}
+
+	if policy.prefix() == SecureCookiePrefix {
+		store.Options.Domain = policy.Domain
+	}
+	return gorilla.New(policy.Name("_session"), store)
+}
+
+// validate check policy, insecure combinations are reported, unless in Development
+func (policy *CookiePolicy) validate(configErr *ConfigError) {
+	if policy.Development {
+		return
+	}
+
+	if policy.Prefix != "" && policy.Prefix != HostCookiePrefix && policy.Prefix != SecureCookiePrefix {
+		configErr.Add("CookiePolicy.Prefix should be %v or %v", HostCookiePrefix, SecureCookiePrefix)
+	}
+
+	if policy.Domain != "" && policy.prefix() != SecureCookiePrefix {
+		configErr.Add("CookiePolicy.Domain is only allowed with prefix %v", SecureCookiePrefix)
+	}
+
+	if policy.SameSite == http.SameSiteNoneMode {
+		configErr.Add("CookiePolicy.SameSite shouldn't be None")
+	}
+}
+
+func (policy *CookiePolicy) prefix() string {
+	if policy.Prefix == "" {
+		return HostCookiePrefix
+	}
+	return policy.Prefix
+}
+
+func (policy *CookiePolicy) sameSite() http.SameSite {
+	if policy.SameSite == 0 || policy.SameSite == http.SameSiteDefaultMode {
+		return http.SameSiteLaxMode
+	}
+	return policy.SameSite
+}
+
+// GetCookie get auth's cookie with name, the name is prefixed with CookiePolicy's prefix
+func (auth *Auth) GetCookie(req *http.Request, name string) (*http.Cookie, error) {
+	return req.Cookie(auth.Config.CookiePolicy.Name(name))
+}
+
+// SetCookie set auth's cookie, CookiePolicy is applied to it, cookie's Name is changed to the prefixed one
+func (auth *Auth) SetCookie(w http.ResponseWriter, req *http.Request, cookie *http.Cookie) {
+	auth.Config.CookiePolicy.Apply(req, cookie)
+	http.SetCookie(w, cookie)
+}
+
+// DeleteCookie delete auth's cookie with name
+func (auth *Auth) DeleteCookie(w http.ResponseWriter, req *http.Request, name string) {
+	auth.SetCookie(w, req, &http.Cookie{Name: name, Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+}
+
+// isHardenedSessionStorer check session storer signs tokens with a key, and doesn't use default session manager, whose key is hard-coded
+func isHardenedSessionStorer(storer SessionStorerInterface) bool {
+	sessionStorer, ok := storer.(*SessionStorer)
+	if !ok {
+		return storer != nil
+	}
+	return strings.TrimSpace(sessionStorer.signingKey()) != "" && sessionStorer.SessionManager != nil && sessionStorer.SessionManager != manager.SessionManager
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+)
+
+// CSRFConfig CSRF protection config, with it, POST, PUT, PATCH, DELETE requests to auth routes need to have a CSRF token in form value or header,
+// tokens are saved in a cookie and compared with submitted ones, requests authenticated with `Authorization` header are not validated
+type CSRFConfig struct {
+	// CookieName cookie saves CSRF token, default value is `_auth_csrf`
+	CookieName string
+	// FieldName form field of CSRF token, default value is `csrf_token`
+	FieldName string
+	// HeaderName header of CSRF token used by JSON requests, default value is `X-CSRF-Token`
+	HeaderName string
+	// Exempt skip validation of requests, like callbacks posted by other sites
+	Exempt func(req *http.Request) bool
+}
+
+// CSRFExempter providers implement it to skip CSRF validation of their endpoints, like endpoints authenticated with client credentials, or posted by other sites by design
+type CSRFExempter interface {
+	CSRFExempt(req *http.Request) bool
+}
+
+// CSRFToken get CSRF token of request, a new token is generated and saved to cookie if not exists, returns blank string if CSRF protection isn't enabled,
+// or failed to generate the token, forms submitted without token are rejected then
+func (context Context) CSRFToken() string {
+	config := context.Auth.Config.CSRF
+	if config == nil {
+		return ""
+	}
+
+	if cookie, err := context.Auth.GetCookie(context.Request, config.CookieName); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+
+	token, err := context.Auth.GenerateToken()
+	if err != nil {
+		return ""
+	}
+
+	cookie := &http.Cookie{
+		Name:     config.CookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	context.Auth.Config.CookiePolicy.Apply(context.Request, cookie)
+	if context.Writer != nil {
+		http.SetCookie(context.Writer, cookie)
+	}
+
+	// later calls in the same request get the same token
+	context.Request.AddCookie(cookie)
+	return cookie.Value
+}
+
+// CSRFField hidden input of CSRF token, used in views like `{{csrf_field}}`
+func (context Context) CSRFField() template.HTML {
+	config := context.Auth.Config.CSRF
+	if config == nil {
+		return ""
+	}
+	return template.HTML(fmt.Sprintf(`<input type="hidden" name="%v" value="%v">`, template.HTMLEscapeString(config.FieldName), template.HTMLEscapeString(context.CSRFToken())))
+}
+
+// ValidateCSRFToken validate submitted CSRF token of request, safe requests, requests authenticated with `Authorization` header and exempted requests are always valid
+func (context Context) ValidateCSRFToken() error {
+	var (
+		config = context.Auth.Config.CSRF
+		req    = context.Request
+	)
+
+	if config == nil {
+		return nil
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return nil
+	}
+
+	if req.Header.Get("Authorization") != "" || (config.Exempt != nil && config.Exempt(req)) {
+		return nil
+	}
+
+	if exempter, ok := context.Provider.(CSRFExempter); ok && exempter.CSRFExempt(req) {
+		return nil
+	}
+
+	cookie, err := context.Auth.GetCookie(req, config.CookieName)
+	if err != nil || cookie.Value == "" {
+		return ErrInvalidCSRFToken
+	}
+
+	token := req.Header.Get(config.HeaderName)
+	if token == "" {
+		token = req.FormValue(config.FieldName)
+	}
+
+	if !SecureCompare(token, cookie.Value) {
+		return ErrInvalidCSRFToken
+	}
+	return nil
+}
+
+// csrfTokenHandler respond CSRF token as JSON for JSON clients, like `{"csrf_token": "..."}`, send it back with header `X-CSRF-Token`
+func (auth *Auth) csrfTokenHandler(context *Context) {
+	if auth.Config.CSRF == nil {
+		http.NotFound(context.Writer, context.Request)
+		return
+	}
+
+	context.Writer.Header().Set("Content-Type", "application/json")
+	context.Writer.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(context.Writer).Encode(map[string]string{"csrf_token": context.CSRFToken()})
+}
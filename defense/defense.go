@@ -0,0 +1,324 @@
+// Package defense adaptive brute-force defense, login sources with recent failures face escalating countermeasures, like growing delays, CAPTCHA, proof-of-work challenges
+package defense
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qor/auth"
+	"github.com/qor/auth/cache"
+	"github.com/qor/auth/cache/memory"
+	"github.com/qor/auth/claims"
+)
+
+// EventChallenged published when a login attempt faces countermeasures, data has `failures`, `delay`, `captcha`, `proof_of_work`
+const EventChallenged = "defense.challenged"
+
+// challengeError countermeasure's error, with its own error code
+type challengeError struct {
+	code    string
+	message string
+}
+
+func (err challengeError) Error() string     { return err.message }
+func (err challengeError) ErrorCode() string { return err.code }
+
+var (
+	// ErrCaptchaRequired CAPTCHA required error, returned if login source needs to solve CAPTCHA, error code is `captcha_required`
+	ErrCaptchaRequired error = challengeError{code: "captcha_required", message: "captcha required"}
+	// ErrProofOfWorkRequired proof-of-work required error, returned if login source needs to solve proof-of-work challenge, error code is `proof_of_work_required`
+	ErrProofOfWorkRequired error = challengeError{code: "proof_of_work_required", message: "proof of work required"}
+)
+
+// Policy countermeasures of login sources have at least Failures recent failed logins, the policy with most Failures matched applies
+type Policy struct {
+	Failures int
+	// Delay artificial delay before authorizing, doubled for each failure over Failures, up to Config's MaxDelay
+	Delay time.Duration
+	// Captcha require solving CAPTCHA, requires Config's VerifyCaptcha
+	Captcha bool
+	// ProofOfWork require solving proof-of-work challenge with the difficulty, in leading zero bits of SHA-256
+	ProofOfWork int
+}
+
+// DefaultPolicies default policies, growing delays after 3 failures, proof-of-work after 10 failures
+var DefaultPolicies = []Policy{
+	{Failures: 3, Delay: 500 * time.Millisecond},
+	{Failures: 10, Delay: time.Second, ProofOfWork: 18},
+}
+
+// Config brute-force defense config
+type Config struct {
+	Auth *auth.Auth
+	// Policies countermeasures by failures, default value is DefaultPolicies
+	Policies []Policy
+	// Window failures older than it are forgotten, default value is 15 minutes
+	Window time.Duration
+	// MaxDelay max artificial delay, default value is 10 seconds
+	MaxDelay time.Duration
+	// Cache store failures, challenges, default value is in-memory cache, use a shared one like redis if you have multiple instances
+	Cache cache.Interface
+	// Secret sign proof-of-work challenges, default value is a random secret, set it if you have multiple instances
+	Secret string
+	// Sources login sources failures are counted for, default value is client IP, and login identifier of form fields `login`, `email`, `username`
+	Sources func(context *auth.Context) []string
+	// VerifyCaptcha verify CAPTCHA response, like calling reCAPTCHA, hCaptcha's verification API
+	VerifyCaptcha func(context *auth.Context, response string) (bool, error)
+	// CaptchaField form field of CAPTCHA response, default value is `captcha_response`
+	CaptchaField string
+}
+
+// New initialize brute-force defense, count failed logins, and wrap Auth's LoginHandler to apply countermeasures before authorizing, panics if config is invalid, use NewWithError to handle the error
+func New(config *Config) *Defense {
+	defense, err := NewWithError(config)
+	if err != nil {
+		panic(err)
+	}
+	return defense
+}
+
+// NewWithError initialize brute-force defense, returns a *auth.ConfigError lists all problems if config is invalid
+func NewWithError(config *Config) (*Defense, error) {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if config.Policies == nil {
+		config.Policies = DefaultPolicies
+	}
+
+	if config.Window == 0 {
+		config.Window = 15 * time.Minute
+	}
+
+	if config.MaxDelay == 0 {
+		config.MaxDelay = 10 * time.Second
+	}
+
+	if config.Cache == nil {
+		config.Cache = memory.New()
+	}
+
+	if config.Secret == "" {
+		secret, err := config.Auth.GenerateToken()
+		if err != nil {
+			return nil, err
+		}
+		config.Secret = secret
+	}
+
+	if config.Sources == nil {
+		config.Sources = DefaultSources
+	}
+
+	if config.CaptchaField == "" {
+		config.CaptchaField = "captcha_response"
+	}
+
+	policies := append([]Policy{}, config.Policies...)
+	sort.SliceStable(policies, func(i, j int) bool { return policies[i].Failures < policies[j].Failures })
+	config.Policies = policies
+
+	defense := &Defense{Config: config}
+	loginHandler := config.Auth.Config.LoginHandler
+	config.Auth.Config.LoginHandler = func(context *auth.Context, authorize func(*auth.Context) (*claims.Claims, error)) {
+		loginHandler(context, defense.authorize(authorize))
+	}
+
+	config.Auth.Subscribe(auth.EventLoginFailed, defense.handleLoginFailed)
+	config.Auth.Subscribe(auth.EventLogin, defense.handleLogin)
+	return defense, nil
+}
+
+// Validate validate config
+func (config *Config) Validate() error {
+	configErr := &auth.ConfigError{Name: "defense"}
+	if config.Auth == nil {
+		configErr.Add("Auth must be specified")
+	}
+	return configErr.Err()
+}
+
+// DefaultSources client IP, and login identifier of form fields `login`, `email`, `username`
+var DefaultSources = func(context *auth.Context) []string {
+	sources := []string{"ip:" + context.Auth.GetClientIP(context.Request).String()}
+	for _, field := range []string{"login", "email", "username"} {
+		if value := strings.TrimSpace(context.Request.FormValue(field)); value != "" {
+			sources = append(sources, "login:"+strings.ToLower(value))
+			break
+		}
+	}
+	return sources
+}
+
+// Defense brute-force defense
+type Defense struct {
+	*Config
+}
+
+// Countermeasures countermeasures a login attempt faces
+type Countermeasures struct {
+	Failures    int           `json:"failures"`
+	Delay       time.Duration `json:"-"`
+	Captcha     bool          `json:"captcha"`
+	ProofOfWork int           `json:"proof_of_work,omitempty"`
+}
+
+// Countermeasures get countermeasures of request's login sources, based on their recent failures
+func (defense *Defense) Countermeasures(context *auth.Context) Countermeasures {
+	var countermeasures Countermeasures
+	for _, source := range defense.Sources(context) {
+		if failures := defense.failures(source); failures > countermeasures.Failures {
+			countermeasures.Failures = failures
+		}
+	}
+
+	for _, policy := range defense.Policies {
+		if countermeasures.Failures < policy.Failures || policy.Failures <= 0 {
+			continue
+		}
+
+		countermeasures.Delay = policy.Delay
+		for idx := policy.Failures; idx < countermeasures.Failures && countermeasures.Delay < defense.MaxDelay; idx++ {
+			countermeasures.Delay *= 2
+		}
+		if countermeasures.Delay > defense.MaxDelay {
+			countermeasures.Delay = defense.MaxDelay
+		}
+
+		countermeasures.Captcha = policy.Captcha && defense.VerifyCaptcha != nil
+		countermeasures.ProofOfWork = policy.ProofOfWork
+	}
+	return countermeasures
+}
+
+// authorize apply countermeasures, then authorize with the provider
+func (defense *Defense) authorize(authorize func(*auth.Context) (*claims.Claims, error)) func(*auth.Context) (*claims.Claims, error) {
+	return func(context *auth.Context) (*claims.Claims, error) {
+		countermeasures := defense.Countermeasures(context)
+		if countermeasures.Failures == 0 || (countermeasures.Delay == 0 && !countermeasures.Captcha && countermeasures.ProofOfWork == 0) {
+			return authorize(context)
+		}
+
+		context.Auth.Publish(EventChallenged, context, map[string]interface{}{
+			"failures":      countermeasures.Failures,
+			"delay":         countermeasures.Delay,
+			"captcha":       countermeasures.Captcha,
+			"proof_of_work": countermeasures.ProofOfWork,
+		})
+
+		if countermeasures.Delay > 0 {
+			timer := time.NewTimer(countermeasures.Delay)
+			select {
+			case <-timer.C:
+			case <-context.Request.Context().Done():
+				timer.Stop()
+				return nil, context.Request.Context().Err()
+			}
+		}
+
+		if countermeasures.ProofOfWork > 0 && !defense.verifyProofOfWork(context, countermeasures.ProofOfWork) {
+			return nil, defense.reject(context, countermeasures, ErrProofOfWorkRequired)
+		}
+
+		if countermeasures.Captcha {
+			response := context.Request.FormValue(defense.CaptchaField)
+			if response == "" {
+				return nil, defense.reject(context, countermeasures, ErrCaptchaRequired)
+			}
+
+			if ok, err := defense.VerifyCaptcha(context, response); err != nil {
+				return nil, err
+			} else if !ok {
+				return nil, defense.reject(context, countermeasures, ErrCaptchaRequired)
+			}
+		}
+
+		return authorize(context)
+	}
+}
+
+// reject publish auth.EventRateLimited for login attempt rejected by countermeasures, returns err
+func (defense *Defense) reject(context *auth.Context, countermeasures Countermeasures, err error) error {
+	context.Auth.Publish(auth.EventRateLimited, context, map[string]interface{}{"reason": auth.ErrorCode(err), "failures": countermeasures.Failures})
+	return err
+}
+
+// ChallengeHandler respond countermeasures of request's login sources, with a proof-of-work challenge if required, mount it like `mux.Handle("/auth/challenge", Defense.ChallengeHandler())`
+//
+//	GET /auth/challenge?login=jinzhu@example.com
+//	{"failures": 12, "delay": 4, "captcha": false, "proof_of_work": 18, "challenge": "..."}
+func (defense *Defense) ChallengeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var (
+			context         = &auth.Context{Auth: defense.Auth, Request: req, Writer: w}
+			countermeasures = defense.Countermeasures(context)
+			result          = struct {
+				Countermeasures
+				Delay     float64 `json:"delay"`
+				Challenge string  `json:"challenge,omitempty"`
+			}{Countermeasures: countermeasures, Delay: countermeasures.Delay.Seconds()}
+		)
+
+		if countermeasures.ProofOfWork > 0 {
+			challenge, err := defense.NewChallenge(context, countermeasures.ProofOfWork)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			result.Challenge = challenge
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(result)
+	})
+}
+
+func (defense *Defense) failures(source string) int {
+	var count int
+	if err := defense.Cache.Get("defense:failures:"+source, &count); err != nil {
+		return 0
+	}
+	return count
+}
+
+func (defense *Defense) handleLoginFailed(event *auth.Event) {
+	if event.Context == nil || event.Context.Request == nil {
+		return
+	}
+
+	// failures caused by countermeasures aren't counted, or they would never end
+	if err, _ := event.Data["error"].(error); err == ErrCaptchaRequired || err == ErrProofOfWorkRequired {
+		return
+	}
+
+	for _, source := range defense.Sources(event.Context) {
+		defense.Cache.Increment("defense:failures:"+source, defense.Window)
+	}
+}
+
+// handleLogin forget failures of login identifier after logged in, failures of IP are kept, so credential stuffing with a few valid accounts is still slowed down
+func (defense *Defense) handleLogin(event *auth.Event) {
+	if event.Context == nil || event.Context.Request == nil {
+		return
+	}
+
+	var keys []string
+	for _, source := range defense.Sources(event.Context) {
+		if !strings.HasPrefix(source, "ip:") {
+			keys = append(keys, "defense:failures:"+source)
+		}
+	}
+
+	if len(keys) > 0 {
+		defense.Cache.Delete(keys...)
+	}
+}
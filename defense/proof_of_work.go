@@ -0,0 +1,85 @@
+package defense
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qor/auth"
+)
+
+// ChallengeExpiry proof-of-work challenges should be solved in it
+var ChallengeExpiry = 5 * time.Minute
+
+// NewChallenge issue a proof-of-work challenge with difficulty, clients need to find a nonce that SHA-256 of `{challenge}:{nonce}` has difficulty leading zero bits,
+// and submit them with form fields `pow_challenge`, `pow_nonce`, challenges are signed, so they are not saved
+func (defense *Defense) NewChallenge(context *auth.Context, difficulty int) (string, error) {
+	token, err := context.Auth.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+
+	payload := fmt.Sprintf("%d.%d.%v", context.Auth.Now().Add(ChallengeExpiry).Unix(), difficulty, token[:16])
+	return payload + "." + defense.sign(payload), nil
+}
+
+// Solve find nonce of proof-of-work challenge, for Go clients and tests, browsers solve it with JavaScript
+func Solve(challenge string, difficulty int) string {
+	for nonce := 0; ; nonce++ {
+		if leadingZeroBits(challenge, strconv.Itoa(nonce)) >= difficulty {
+			return strconv.Itoa(nonce)
+		}
+	}
+}
+
+// verifyProofOfWork verify submitted proof-of-work, challenge need to be signed, not expired, not used, at least as difficult as required
+func (defense *Defense) verifyProofOfWork(context *auth.Context, difficulty int) bool {
+	var (
+		challenge = context.Request.FormValue("pow_challenge")
+		nonce     = context.Request.FormValue("pow_nonce")
+		parts     = strings.Split(challenge, ".")
+	)
+
+	if len(parts) != 4 || nonce == "" || !hmac.Equal([]byte(defense.sign(strings.Join(parts[:3], "."))), []byte(parts[3])) {
+		return false
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || context.Auth.Now().Unix() > expiresAt {
+		return false
+	}
+
+	if challengeDifficulty, err := strconv.Atoi(parts[1]); err != nil || challengeDifficulty < difficulty {
+		return false
+	}
+
+	if leadingZeroBits(challenge, nonce) < difficulty {
+		return false
+	}
+
+	// challenges could only be used once
+	ok, err := defense.Cache.SetNX("defense:challenge:"+parts[2], true, ChallengeExpiry)
+	return err == nil && ok
+}
+
+func (defense *Defense) sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(defense.Secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func leadingZeroBits(challenge, nonce string) (count int) {
+	sum := sha256.Sum256([]byte(challenge + ":" + nonce))
+	for _, b := range sum {
+		if b != 0 {
+			return count + bits.LeadingZeros8(b)
+		}
+		count += 8
+	}
+	return count
+}
@@ -9,4 +9,12 @@ var (
 	ErrInvalidAccount = errors.New("invalid account")
 	// ErrUnauthorized unauthorized error
 	ErrUnauthorized = errors.New("Unauthorized")
+	// ErrAccountLocked account locked error, returned by providers if account is locked, like too many failed logins
+	ErrAccountLocked = errors.New("account locked")
+	// ErrStateExpired state expired error, returned by OAuth providers if callback's state expired
+	ErrStateExpired = errors.New("state expired")
+	// ErrInvalidCSRFToken invalid CSRF token error, returned if unsafe requests to auth routes don't have a valid CSRF token
+	ErrInvalidCSRFToken = errors.New("invalid CSRF token")
+	// ErrIPNotAllowed IP not allowed error, returned if client IP isn't allowed by IP rules
+	ErrIPNotAllowed = errors.New("IP address not allowed")
 )
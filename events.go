@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/qor/auth/claims"
+)
+
+const (
+	// EventAll subscribe to this name to receive all events
+	EventAll = "*"
+	// EventLogin published after user logged in
+	EventLogin = "user.login"
+	// EventLoginFailed published after user failed to login, error is saved in event's data with key `error`
+	EventLoginFailed = "user.login_failed"
+	// EventRegistered published after user registered
+	EventRegistered = "user.registered"
+	// EventRegisterFailed published after user failed to register, error is saved in event's data with key `error`
+	EventRegisterFailed = "user.register_failed"
+	// EventLogout published after user logged out
+	EventLogout = "user.logout"
+	// EventUserLocked published by providers after locked an user, e.g. too many failed logins
+	EventUserLocked = "user.locked"
+	// EventMFAEnrolled published by MFA providers after user enrolled a second factor
+	EventMFAEnrolled = "mfa.enrolled"
+	// EventTokenExchanged published by OAuth providers after exchanged access token, like oauth_server's token endpoint, time spent is saved in event's data with key `duration`
+	EventTokenExchanged = "oauth.token_exchanged"
+	// EventMFAChallenged published by MFA providers after challenged user with a second factor, like login_approval's emailed code
+	EventMFAChallenged = "mfa.challenged"
+	// EventRateLimited published by rate limiters after rejected a request, like defense rejected a login without solved challenge
+	EventRateLimited = "rate_limit.rejected"
+)
+
+// Event auth event, published when something happened, like user registered, invitation accepted
+type Event struct {
+	Name      string
+	Context   *Context
+	Claims    *claims.Claims
+	Data      map[string]interface{}
+	Location  *GeoLocation
+	CreatedAt time.Time
+}
+
+// ProviderName get name of the provider which published the event
+func (event *Event) ProviderName() string {
+	if event.Context != nil && event.Context.Provider != nil {
+		return event.Context.Provider.GetName()
+	}
+
+	if provider, ok := event.Data["provider"].(string); ok {
+		return provider
+	}
+
+	if event.Claims != nil {
+		return event.Claims.Provider
+	}
+	return ""
+}
+
+// EventHandler event handler
+type EventHandler func(event *Event)
+
+// Subscribe register handler for events with name, use `EventAll` to receive all events
+func (auth *Auth) Subscribe(name string, handler EventHandler) {
+	auth.eventsMutex.Lock()
+	defer auth.eventsMutex.Unlock()
+
+	if auth.eventHandlers == nil {
+		auth.eventHandlers = map[string][]EventHandler{}
+	}
+	auth.eventHandlers[name] = append(auth.eventHandlers[name], handler)
+}
+
+// Publish publish event to subscribed handlers
+func (auth *Auth) Publish(name string, context *Context, data map[string]interface{}) {
+	event := &Event{Name: name, Context: context, Data: data, CreatedAt: auth.Now()}
+	if context != nil {
+		// copy claims, so handlers keeping the event won't see later changes of context's claims
+		if context.Claims != nil {
+			claims := *context.Claims
+			event.Claims = &claims
+		}
+		event.Location = auth.GetLocation(context.Request)
+	}
+
+	if event.Data == nil {
+		event.Data = map[string]interface{}{}
+	}
+
+	auth.eventsMutex.RLock()
+	handlers := append(append([]EventHandler{}, auth.eventHandlers[name]...), auth.eventHandlers[EventAll]...)
+	auth.eventsMutex.RUnlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
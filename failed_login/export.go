@@ -0,0 +1,158 @@
+package failed_login
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxExportLimit max number of failed logins, or aggregations exported by one request of ExportHandler
+const MaxExportLimit = 10000
+
+// ExportCSV write failed logins as CSV, cells like identifier, user agent come from clients, those could be read as formulas by spreadsheets are prefixed with `'`
+func ExportCSV(w io.Writer, attempts []FailedLogin) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"time", "identifier", "ip_address", "provider", "reason", "country", "user_agent"})
+
+	for _, attempt := range attempts {
+		writer.Write([]string{
+			attempt.CreatedAt.UTC().Format(time.RFC3339),
+			escapeCSVCell(attempt.Identifier),
+			escapeCSVCell(attempt.IPAddress),
+			escapeCSVCell(attempt.Provider),
+			escapeCSVCell(attempt.Reason),
+			escapeCSVCell(attempt.Country),
+			escapeCSVCell(attempt.UserAgent),
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// escapeCSVCell prefix cell starts with `=`, `+`, `-`, `@`, tab or carriage return with `'`, so spreadsheets won't evaluate it as formula
+func escapeCSVCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+type exportedFailedLogin struct {
+	Time       time.Time `json:"time"`
+	Identifier string    `json:"identifier"`
+	IPAddress  string    `json:"ip_address"`
+	Provider   string    `json:"provider"`
+	Reason     string    `json:"reason"`
+	Country    string    `json:"country,omitempty"`
+	UserAgent  string    `json:"user_agent"`
+}
+
+// ExportJSON write failed logins as JSON array
+func ExportJSON(w io.Writer, attempts []FailedLogin) error {
+	results := make([]exportedFailedLogin, len(attempts))
+	for idx, attempt := range attempts {
+		results[idx] = exportedFailedLogin{
+			Time:       attempt.CreatedAt.UTC(),
+			Identifier: attempt.Identifier,
+			IPAddress:  attempt.IPAddress,
+			Provider:   attempt.Provider,
+			Reason:     attempt.Reason,
+			Country:    attempt.Country,
+			UserAgent:  attempt.UserAgent,
+		}
+	}
+	return json.NewEncoder(w).Encode(results)
+}
+
+// ExportHandler export failed logins, make sure it is protected, only accessible for admins
+//
+//	GET /failed_logins?format=csv&since=2006-01-02T15:04:05Z&until=...&provider=...&identifier=...&ip_address=...&limit=1000&offset=0
+//	GET /failed_logins?aggregate=accounts|ips&limit=10
+func (tracker *Tracker) ExportHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var (
+			params = req.URL.Query()
+			query  = Query{Provider: params.Get("provider"), Identifier: params.Get("identifier"), IPAddress: params.Get("ip_address")}
+		)
+
+		for key, value := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+			if params.Get(key) != "" {
+				t, err := time.Parse(time.RFC3339, params.Get(key))
+				if err != nil {
+					http.Error(w, fmt.Sprintf("invalid %v: %v", key, err), http.StatusBadRequest)
+					return
+				}
+				*value = t
+			}
+		}
+
+		if aggregate := params.Get("aggregate"); aggregate != "" {
+			var (
+				results []Aggregation
+				err     error
+				limit   = 10
+			)
+
+			if l, err := strconv.Atoi(params.Get("limit")); err == nil && l > 0 {
+				limit = l
+			}
+
+			if limit > MaxExportLimit {
+				limit = MaxExportLimit
+			}
+
+			switch aggregate {
+			case "accounts":
+				results, err = tracker.TopTargetedAccounts(req, query, limit)
+			case "ips":
+				results, err = tracker.TopSourceIPs(req, query, limit)
+			default:
+				http.Error(w, "invalid aggregate", http.StatusBadRequest)
+				return
+			}
+
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(results)
+			return
+		}
+
+		if limit, err := strconv.Atoi(params.Get("limit")); err == nil && limit > 0 {
+			query.Limit = limit
+		}
+
+		if query.Limit > MaxExportLimit {
+			query.Limit = MaxExportLimit
+		}
+
+		if offset, err := strconv.Atoi(params.Get("offset")); err == nil && offset > 0 {
+			query.Offset = offset
+		}
+
+		attempts, err := tracker.Find(req, query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if params.Get("format") == "csv" {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", "attachment; filename=failed_logins.csv")
+			ExportCSV(w, attempts)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		ExportJSON(w, attempts)
+	})
+}
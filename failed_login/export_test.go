@@ -0,0 +1,89 @@
+package failed_login_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/qor/auth"
+	"github.com/qor/auth/failed_login"
+)
+
+type redirector struct{}
+
+func (redirector) Redirect(w http.ResponseWriter, req *http.Request, action string) {}
+
+func TestExportCSVEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	if err := failed_login.ExportCSV(&buf, []failed_login.FailedLogin{
+		{Identifier: "=HYPERLINK(\"https://evil.example.com\")", IPAddress: "+1", Provider: "-1", Reason: "@SUM(A1)", Country: "\tDE", UserAgent: "\rcurl"},
+		{Identifier: "alice@example.com", IPAddress: "10.0.0.1", Provider: "password", Reason: "invalid_password", UserAgent: "Mozilla/5.0 (a=b)"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		got, want []string
+	}{
+		{rows[1][1:], []string{"'=HYPERLINK(\"https://evil.example.com\")", "'+1", "'-1", "'@SUM(A1)", "'\tDE", "'\rcurl"}},
+		{rows[2][1:], []string{"alice@example.com", "10.0.0.1", "password", "invalid_password", "", "Mozilla/5.0 (a=b)"}},
+	}
+
+	for _, test := range tests {
+		if fmt.Sprint(test.got) != fmt.Sprint(test.want) {
+			t.Errorf("expect cells %q, got %q", test.want, test.got)
+		}
+	}
+}
+
+func TestExportLimitsAreCapped(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	Auth := auth.New(&auth.Config{DB: db, Redirector: redirector{}})
+	tracker := failed_login.New(&failed_login.Config{Auth: Auth})
+	if err := auth.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// distinct accounts and IPs, more than MaxExportLimit
+	sqlDB := db.DB()
+	sqlDB.Exec("BEGIN")
+	for i := 0; i <= failed_login.MaxExportLimit; i++ {
+		if _, err := sqlDB.Exec("INSERT INTO failed_logins (created_at, identifier, ip_address, provider, reason) VALUES (?, ?, ?, 'password', 'invalid_password')",
+			time.Now(), fmt.Sprintf("user%v@example.com", i), fmt.Sprintf("10.0.%v.%v", i/256, i%256)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sqlDB.Exec("COMMIT")
+
+	for _, path := range []string{"/failed_logins?limit=100000", "/failed_logins?aggregate=accounts&limit=100000", "/failed_logins?aggregate=ips&limit=100000"} {
+		w := httptest.NewRecorder()
+		tracker.ExportHandler().ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+		var results []interface{}
+		if err := json.NewDecoder(w.Body).Decode(&results); err != nil {
+			t.Fatalf("%v: %v", path, err)
+		}
+
+		if len(results) != failed_login.MaxExportLimit {
+			t.Errorf("%v: expect %v results, got %v", path, failed_login.MaxExportLimit, len(results))
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package failed_login
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/qor/auth"
+)
+
+func init() {
+	auth.RegisterTables("failed_logins")
+	auth.RegisterMigration(auth.Migration{ID: "failed_login/001_create_failed_logins", Migrate: func(db *gorm.DB) error {
+		return db.AutoMigrate(&FailedLogin{}).Error
+	}})
+}
+
+// FailedLogin failed login attempt model
+type FailedLogin struct {
+	gorm.Model
+	Identifier string `gorm:"index"`
+	IPAddress  string `gorm:"index"`
+	Provider   string
+	Reason     string
+	UserAgent  string
+	Country    string
+}
+
+// Config failed login tracker config
+type Config struct {
+	Auth *auth.Auth
+	// IdentifierFields form fields tried to get login identifier from, default value is `login`, `email`, `username`
+	IdentifierFields []string
+	// Reason convert login error to reason, default value is DefaultReason
+	Reason func(err error) string
+}
+
+// DefaultReason convert login error to reason, like `invalid_password`
+var DefaultReason = func(err error) string {
+	switch err {
+	case nil:
+		return "unknown"
+	case auth.ErrInvalidPassword:
+		return "invalid_password"
+	case auth.ErrInvalidAccount:
+		return "invalid_account"
+	case auth.ErrUnauthorized:
+		return "unauthorized"
+	}
+	return err.Error()
+}
+
+// New initialize failed login tracker, subscribe to failed login events and save them into database, panics if config is invalid, use NewWithError to handle the error
+func New(config *Config) *Tracker {
+	tracker, err := NewWithError(config)
+	if err != nil {
+		panic(err)
+	}
+	return tracker
+}
+
+// NewWithError initialize failed login tracker, returns a *auth.ConfigError lists all problems if config is invalid
+func NewWithError(config *Config) (*Tracker, error) {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if len(config.IdentifierFields) == 0 {
+		config.IdentifierFields = []string{"login", "email", "username"}
+	}
+
+	if config.Reason == nil {
+		config.Reason = DefaultReason
+	}
+
+	tracker := &Tracker{Config: config}
+	config.Auth.Subscribe(auth.EventLoginFailed, tracker.handleEvent)
+	return tracker, nil
+}
+
+// Validate validate config
+func (config *Config) Validate() error {
+	configErr := &auth.ConfigError{Name: "failed_login"}
+	if config.Auth == nil {
+		configErr.Add("Auth must be specified")
+	}
+	return configErr.Err()
+}
+
+// Tracker failed login tracker
+type Tracker struct {
+	*Config
+}
+
+func (tracker *Tracker) handleEvent(event *auth.Event) {
+	if event.Context == nil || event.Context.Request == nil {
+		return
+	}
+
+	var (
+		req     = event.Context.Request
+		err, _  = event.Data["error"].(error)
+		attempt = FailedLogin{
+			Provider:  event.ProviderName(),
+			Reason:    tracker.Reason(err),
+			IPAddress: event.Context.Auth.GetClientIP(req).String(),
+			UserAgent: req.UserAgent(),
+		}
+	)
+
+	for _, field := range tracker.IdentifierFields {
+		if value := req.FormValue(field); value != "" {
+			attempt.Identifier = value
+			break
+		}
+	}
+
+	if event.Location != nil {
+		attempt.Country = event.Location.Country
+	}
+
+	if err := event.Context.Auth.GetDB(req).Create(&attempt).Error; err != nil {
+		fmt.Printf("failed to save failed login: %v\n", err)
+	}
+}
+
+// DefaultLimit default max number of failed logins found by Find
+const DefaultLimit = 1000
+
+// Query failed logins query conditions, zero values are ignored
+type Query struct {
+	Since      time.Time
+	Until      time.Time
+	Provider   string
+	Identifier string
+	IPAddress  string
+	// Limit max number of failed logins found by Find, default value is DefaultLimit, page with Offset
+	Limit  int
+	Offset int
+}
+
+// scope failed logins matched query, read from Auth's ReplicaDB if configured
+func (tracker *Tracker) scope(req *http.Request, query Query) *gorm.DB {
+	db := tracker.Auth.GetReadDB(req).Model(&FailedLogin{})
+
+	if !query.Since.IsZero() {
+		db = db.Where("created_at >= ?", query.Since)
+	}
+
+	if !query.Until.IsZero() {
+		db = db.Where("created_at < ?", query.Until)
+	}
+
+	if query.Provider != "" {
+		db = db.Where("provider = ?", query.Provider)
+	}
+
+	if query.Identifier != "" {
+		db = db.Where("identifier = ?", query.Identifier)
+	}
+
+	if query.IPAddress != "" {
+		db = db.Where("ip_address = ?", query.IPAddress)
+	}
+	return db
+}
+
+// Find find failed logins matched query, latest first, at most query's Limit
+func (tracker *Tracker) Find(req *http.Request, query Query) (attempts []FailedLogin, err error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultLimit
+	}
+	err = tracker.scope(req, query).Order("id DESC").Limit(query.Limit).Offset(query.Offset).Find(&attempts).Error
+	return
+}
+
+// Count count failed logins matched query
+func (tracker *Tracker) Count(req *http.Request, query Query) (count int, err error) {
+	err = tracker.scope(req, query).Count(&count).Error
+	return
+}
+
+// Aggregation aggregated failed logins count
+type Aggregation struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// TopTargetedAccounts accounts have most failed logins
+func (tracker *Tracker) TopTargetedAccounts(req *http.Request, query Query, limit int) ([]Aggregation, error) {
+	return tracker.aggregate(req, query, "identifier", limit)
+}
+
+// TopSourceIPs IP addresses have most failed logins
+func (tracker *Tracker) TopSourceIPs(req *http.Request, query Query, limit int) ([]Aggregation, error) {
+	return tracker.aggregate(req, query, "ip_address", limit)
+}
+
+func (tracker *Tracker) aggregate(req *http.Request, query Query, column string, limit int) (results []Aggregation, err error) {
+	err = tracker.scope(req, query).
+		Select(column + " AS value, COUNT(*) AS count").
+		Where(column + " <> ''").
+		Group(column).
+		Order("count DESC").
+		Limit(limit).
+		Scan(&results).Error
+	return
+}
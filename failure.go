@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/qor/responder"
+	"github.com/qor/session"
+)
+
+const (
+	// ErrorCodeUnauthorized error code of ErrUnauthorized
+	ErrorCodeUnauthorized = "unauthorized"
+	// ErrorCodeInvalidPassword error code of ErrInvalidPassword
+	ErrorCodeInvalidPassword = "invalid_password"
+	// ErrorCodeInvalidAccount error code of ErrInvalidAccount
+	ErrorCodeInvalidAccount = "invalid_account"
+	// ErrorCodeLocked error code of ErrAccountLocked
+	ErrorCodeLocked = "locked"
+	// ErrorCodeStateExpired error code of ErrStateExpired
+	ErrorCodeStateExpired = "state_expired"
+	// ErrorCodeInvalidCSRFToken error code of ErrInvalidCSRFToken
+	ErrorCodeInvalidCSRFToken = "invalid_csrf_token"
+	// ErrorCodeIPNotAllowed error code of ErrIPNotAllowed
+	ErrorCodeIPNotAllowed = "ip_not_allowed"
+	// ErrorCodeUnknown error code of other errors
+	ErrorCodeUnknown = "error"
+)
+
+var errorCodes = map[error]string{
+	ErrUnauthorized:     ErrorCodeUnauthorized,
+	ErrInvalidPassword:  ErrorCodeInvalidPassword,
+	ErrInvalidAccount:   ErrorCodeInvalidAccount,
+	ErrAccountLocked:    ErrorCodeLocked,
+	ErrStateExpired:     ErrorCodeStateExpired,
+	ErrInvalidCSRFToken: ErrorCodeInvalidCSRFToken,
+	ErrIPNotAllowed:     ErrorCodeIPNotAllowed,
+}
+
+// ErrorCode get error's code, errors could define their own code with method `ErrorCode() string`
+func ErrorCode(err error) string {
+	if coder, ok := err.(interface {
+		ErrorCode() string
+	}); ok {
+		return coder.ErrorCode()
+	}
+
+	if code, ok := errorCodes[err]; ok {
+		return code
+	}
+	return ErrorCodeUnknown
+}
+
+// FailureResponse defined how to respond a failure, only one of Redirect, Template, JSON should be set
+type FailureResponse struct {
+	// Redirect redirect to the URL with query params `error` (error code), `error_description` (translated message)
+	Redirect string
+	// Template render the template, like `auth/errors/locked`, with context as data
+	Template string
+	// JSON respond JSON `{"error": "locked", "error_description": "account locked"}`
+	JSON bool
+	// Status status code of Template and JSON responses, default value is 422
+	Status int
+}
+
+// failureResponder errors of providers could define how to respond them, so providers needn't change Config's Failures after initialized
+type failureResponder interface {
+	FailureResponse(context *Context) *FailureResponse
+}
+
+// RespondFailure respond failure with FailureResponse configured for error's code in `Failures`, or error's own one with method `FailureResponse(context *Context) *FailureResponse`, or `Failures["*"]`,
+// default behaviour is adding error to flash messages, and rendering defaultTemplate for HTML request, JSON for JSON request
+func (auth *Auth) RespondFailure(context *Context, err error, defaultTemplate string) {
+	if err == nil {
+		err = ErrUnauthorized
+	}
+
+	var (
+		w       = context.Writer
+		req     = context.Request
+		code    = ErrorCode(err)
+		message = context.TranslateError(err)
+	)
+
+	failure, ok := auth.Config.Failures[code]
+	if !ok {
+		if responder, isResponder := err.(failureResponder); isResponder {
+			failure = responder.FailureResponse(context)
+		} else {
+			failure = auth.Config.Failures["*"]
+		}
+	}
+
+	status := http.StatusUnprocessableEntity
+	if failure != nil && failure.Status != 0 {
+		status = failure.Status
+	}
+
+	writeJSON := func() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": code, "error_description": message})
+	}
+
+	switch {
+	case failure != nil && failure.Redirect != "":
+		if redirectURL, parseErr := url.Parse(failure.Redirect); parseErr == nil {
+			query := redirectURL.Query()
+			query.Set("error", code)
+			query.Set("error_description", message)
+			redirectURL.RawQuery = query.Encode()
+			http.Redirect(w, req, redirectURL.String(), http.StatusSeeOther)
+			return
+		}
+	case failure != nil && failure.JSON:
+		writeJSON()
+		return
+	case failure != nil && failure.Template != "":
+		w.WriteHeader(status)
+		context.Execute(failure.Template)
+		return
+	}
+
+	responder.With("html", func() {
+		context.SessionStorer.Flash(w, req, session.Message{Message: template.HTML(message), Type: "error"})
+		context.Execute(defaultTemplate)
+	}).With([]string{"json"}, writeJSON).Respond(req)
+}
+
+// respondForbidden respond 403 for requests rejected before reaching handlers, like invalid CSRF token, IP not allowed, Failures configured for error's code overwrite it
+func (auth *Auth) respondForbidden(context *Context, err error) {
+	code := ErrorCode(err)
+	if failure, ok := auth.Config.Failures[code]; ok && failure != nil {
+		auth.RespondFailure(context, err, "auth/login")
+		return
+	}
+
+	var (
+		w       = context.Writer
+		message = context.TranslateError(err)
+	)
+
+	responder.With("html", func() {
+		http.Error(w, message, http.StatusForbidden)
+	}).With([]string{"json"}, func() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"error": code, "error_description": message})
+	}).Respond(context.Request)
+}
@@ -0,0 +1,64 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/qor/auth"
+)
+
+// promptError error of a provider responds its own failure
+type promptError struct{}
+
+func (promptError) Error() string     { return "prompt required" }
+func (promptError) ErrorCode() string { return "prompt_required" }
+func (promptError) FailureResponse(context *auth.Context) *auth.FailureResponse {
+	return &auth.FailureResponse{Redirect: context.Auth.AuthURL("prompt")}
+}
+
+func respondFailure(Auth *auth.Auth, err error) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	Auth.RespondFailure(&auth.Context{Auth: Auth, Request: httptest.NewRequest("POST", "/auth/login", nil), Writer: w}, err, "auth/login")
+	return w
+}
+
+func TestErrorsRespondTheirOwnFailures(t *testing.T) {
+	Auth := newAuth(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := respondFailure(Auth, promptError{})
+
+			location, _ := url.Parse(w.Header().Get("Location"))
+			if w.Code != http.StatusSeeOther || location.Path != Auth.AuthURL("prompt") || location.Query().Get("error") != "prompt_required" {
+				t.Errorf("expect redirected to error's own failure, got %v %v", w.Code, location)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if Auth.Config.Failures != nil {
+		t.Errorf("expect Config's Failures unchanged, got %v", Auth.Config.Failures)
+	}
+}
+
+func TestConfiguredFailuresOverwriteErrorsOwnFailures(t *testing.T) {
+	Auth := newAuth(t)
+	Auth.Config.Failures = map[string]*auth.FailureResponse{"prompt_required": {JSON: true, Status: http.StatusForbidden}, "*": {Redirect: "/oops"}}
+
+	if w := respondFailure(Auth, promptError{}); w.Code != http.StatusForbidden {
+		t.Errorf("expect Failures configured for error code used, got %v", w.Code)
+	}
+
+	Auth.Config.Failures = map[string]*auth.FailureResponse{"*": {Redirect: "/oops"}}
+	if w := respondFailure(Auth, promptError{}); !strings.HasPrefix(w.Header().Get("Location"), Auth.AuthURL("prompt")+"?") {
+		t.Errorf("expect error's own failure preferred over Failures[*], got %v", w.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/qor/auth/api_key"
+	"github.com/qor/auth/claims"
+	"github.com/qor/qor/utils"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+)
+
+// Fixtures fixture file's content, see LoadFixtures
+type Fixtures struct {
+	Users []UserFixture `json:"users" yaml:"users"`
+}
+
+// UserFixture user could be loaded with LoadFixtures
+type UserFixture struct {
+	Name      string `json:"name" yaml:"name"`
+	Email     string `json:"email" yaml:"email"`
+	FirstName string `json:"first_name" yaml:"first_name"`
+	LastName  string `json:"last_name" yaml:"last_name"`
+	Phone     string `json:"phone" yaml:"phone"`
+	// Password if set, an auth identity with provider `password`, UID Email is created
+	Password   string            `json:"password" yaml:"password"`
+	Confirmed  bool              `json:"confirmed" yaml:"confirmed"`
+	Roles      []string          `json:"roles" yaml:"roles"`
+	Identities []IdentityFixture `json:"identities" yaml:"identities"`
+	APIKeys    []APIKeyFixture   `json:"api_keys" yaml:"api_keys"`
+}
+
+// IdentityFixture auth identity of UserFixture
+type IdentityFixture struct {
+	Provider  string `json:"provider" yaml:"provider"`
+	UID       string `json:"uid" yaml:"uid"`
+	Password  string `json:"password" yaml:"password"`
+	Confirmed bool   `json:"confirmed" yaml:"confirmed"`
+}
+
+// APIKeyFixture API key of UserFixture, Key is saved hashed
+type APIKeyFixture struct {
+	Name      string     `json:"name" yaml:"name"`
+	Key       string     `json:"key" yaml:"key"`
+	Scopes    []string   `json:"scopes" yaml:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at" yaml:"expires_at"`
+}
+
+// LoadFixtures create users, auth identities, roles and API keys from a YAML or JSON (`.json`) file, for demo environments and reproducible integration test databases,
+// all of them are created in a transaction, records already exist are kept, so it is safe to load the same file multiple times
+//
+//	users:
+//	  - name: Admin
+//	    email: admin@example.com
+//	    password: secret
+//	    confirmed: true
+//	    roles: [admin]
+//	    identities:
+//	      - {provider: github, uid: "1024"}
+//	    api_keys:
+//	      - {name: ci, key: demo-ci-key, scopes: [read]}
+func (auth *Auth) LoadFixtures(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var fixtures Fixtures
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(content, &fixtures)
+	} else {
+		err = yaml.UnmarshalStrict(content, &fixtures)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse fixtures %v: %v", path, err)
+	}
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	return auth.Transaction(&Context{Auth: auth, Request: req}, func(context *Context) error {
+		for _, user := range fixtures.Users {
+			if err := auth.loadUserFixture(context, user); err != nil {
+				return fmt.Errorf("failed to load user %v: %v", user.Email+user.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (auth *Auth) loadUserFixture(context *Context, user UserFixture) error {
+	identities := user.Identities
+	if user.Password != "" {
+		identities = append([]IdentityFixture{{Provider: "password", UID: user.Email, Password: user.Password, Confirmed: user.Confirmed}}, identities...)
+	}
+
+	// reuse user linked to loaded identities
+	var userID string
+	for _, fixture := range identities {
+		if identity, err := auth.IdentityStore.FindByProviderUID(context, fixture.Provider, fixture.UID); err == nil {
+			if claimer, ok := identity.(claims.ClaimerInterface); ok {
+				userID = claimer.ToClaims().UserID
+				break
+			}
+		} else if err != ErrInvalidAccount {
+			return err
+		}
+	}
+
+	if userID == "" {
+		var err error
+		schema := Schema{Name: user.Name, Email: user.Email, FirstName: user.FirstName, LastName: user.LastName, Phone: user.Phone}
+		if _, userID, err = auth.UserStorer.Save(&schema, context); err != nil {
+			return err
+		}
+	}
+
+	for _, fixture := range identities {
+		identity := auth.NewAuthIdentity()
+		value := utils.Indirect(reflect.ValueOf(identity))
+		value.FieldByName("Provider").SetString(fixture.Provider)
+		value.FieldByName("UID").SetString(fixture.UID)
+		value.FieldByName("UserID").SetString(userID)
+
+		if fixture.Password != "" {
+			encryptedPassword, err := bcrypt.GenerateFromPassword([]byte(fixture.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return err
+			}
+			value.FieldByName("EncryptedPassword").SetString(string(encryptedPassword))
+		}
+
+		if fixture.Confirmed {
+			now := auth.Now()
+			value.FieldByName("ConfirmedAt").Set(reflect.ValueOf(&now))
+		}
+
+		if _, _, err := FindOrCreateIdentity(context, identity); err != nil {
+			return err
+		}
+	}
+
+	for _, role := range user.Roles {
+		if err := auth.RoleStorer.Add(userID, role, context); err != nil {
+			return err
+		}
+	}
+
+	for _, fixture := range user.APIKeys {
+		if fixture.Key == "" {
+			return fmt.Errorf("key of API key %v is blank", fixture.Name)
+		}
+
+		key := api_key.APIKey{UserID: userID, Name: fixture.Name, Scopes: strings.Join(fixture.Scopes, " "), ExpiresAt: fixture.ExpiresAt}
+		if err := auth.GetDB(context.Request).Where(api_key.APIKey{HashedKey: api_key.Hash(fixture.Key)}).Attrs(key).FirstOrCreate(&api_key.APIKey{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
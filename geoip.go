@@ -0,0 +1,29 @@
+package auth
+
+import "net/http"
+
+// GeoLocation approximate location of an IP address
+type GeoLocation struct {
+	Country     string  `json:"country,omitempty"`
+	CountryCode string  `json:"country_code,omitempty"`
+	City        string  `json:"city,omitempty"`
+	Latitude    float64 `json:"latitude,omitempty"`
+	Longitude   float64 `json:"longitude,omitempty"`
+}
+
+// GeoIPResolverInterface resolve IP address's location, implement it with your GeoIP database like MaxMind's
+type GeoIPResolverInterface interface {
+	Resolve(ip string) (*GeoLocation, error)
+}
+
+// GetLocation get request's approximate location with Config.GeoIPResolver, returns nil if not configured or failed to resolve
+func (auth *Auth) GetLocation(req *http.Request) *GeoLocation {
+	if auth.Config.GeoIPResolver == nil || req == nil {
+		return nil
+	}
+
+	if location, err := auth.Config.GeoIPResolver.Resolve(auth.GetClientIP(req).String()); err == nil {
+		return location
+	}
+	return nil
+}
@@ -1,16 +1,18 @@
 module github.com/qor/auth
 
-go 1.15
+go 1.16
 
 require (
-	github.com/google/go-cmp v0.5.1 // indirect
+	github.com/crewjam/saml v0.4.14
+	github.com/go-redis/redis/v8 v8.11.5
 	github.com/gorilla/context v1.1.1 // indirect
-	github.com/gorilla/sessions v1.2.1 // indirect
+	github.com/gorilla/sessions v1.2.1
+	github.com/jackc/pgx/v4 v4.18.3
 	github.com/jinzhu/copier v0.0.0-20201025035756-632e723a6687
 	github.com/jinzhu/gorm v1.9.16
-	github.com/kr/pretty v0.1.0 // indirect
 	github.com/mattn/go-sqlite3 v1.14.4 // indirect
-	github.com/qor/assetfs v0.0.0-20170713023933-ff57fdc13a14 // indirect
+	github.com/prometheus/client_golang v1.11.1
+	github.com/qor/assetfs v0.0.0-20170713023933-ff57fdc13a14
 	github.com/qor/mailer v0.0.0-20180329083248-0555e49f99ac
 	github.com/qor/middlewares v0.0.0-20170822143614-781378b69454
 	github.com/qor/qor v0.0.0-20200729071734-d587cffbbb93
@@ -19,10 +21,10 @@ require (
 	github.com/qor/responder v0.0.0-20201015104727-4f3a345378c2
 	github.com/qor/roles v0.0.0-20201008080147-dcaf8a4646d8
 	github.com/qor/session v0.0.0-20170907035918-8206b0adab70
-	github.com/stretchr/testify v1.4.0 // indirect
-	golang.org/x/crypto v0.0.0-20201016220609-9e8e0b390897 // indirect
-	golang.org/x/net v0.0.0-20200822124328-c89045814202 // indirect
+	github.com/russellhaering/goxmldsig v1.3.0
+	golang.org/x/crypto v0.20.0
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
-	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
 	gopkg.in/square/go-jose.v2 v2.5.1
+	gopkg.in/yaml.v2 v2.4.0
+	rsc.io/qr v0.2.0
 )
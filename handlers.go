@@ -16,11 +16,42 @@ import (
 	"github.com/qor/session"
 )
 
-func respondAfterLogged(claims *claims.Claims, context *Context) {
-	// login user
-	context.Auth.Login(context.Writer, context.Request, claims)
+// loginWithHooks sign user in, hooks of the point are invoked before session issued, AfterLogin hooks are invoked after, any of them could reject the login
+func loginWithHooks(claims *claims.Claims, context *Context, point HookPoint) error {
+	context.Claims = claims
 
+	if err := context.Auth.runHooks(point, context); err != nil {
+		return err
+	}
+	return issueSession(claims, context)
+}
+
+// issueSession login user with claims, run AfterLogin hooks, the session is destroyed if any of them failed
+func issueSession(claims *claims.Claims, context *Context) error {
+	// login user, claims are copied when issuing session, use issued claims, which have session ID, login time, in following hooks
+	issued, err := context.Auth.login(context.Writer, context.Request, claims)
+	if err != nil {
+		return err
+	}
+	context.Claims = issued
+
+	if err := context.Auth.runHooks(AfterLogin, context); err != nil {
+		context.Auth.Logout(context.Writer, context.Request)
+		return err
+	}
+	return nil
+}
+
+func respondAfterLogged(context *Context) {
 	responder.With("html", func() {
+		// redirect to validated `return_to` if exists
+		returnTo := context.ReturnTo()
+		context.clearReturnTo()
+		if returnTo != "" {
+			http.Redirect(context.Writer, context.Request, returnTo, http.StatusSeeOther)
+			return
+		}
+
 		// write cookie
 		context.Auth.Redirector.Redirect(context.Writer, context.Request, "login")
 	}).With([]string{"json"}, func() {
@@ -37,49 +68,60 @@ var DefaultLoginHandler = func(context *Context, authorize func(*Context) (*clai
 	)
 
 	if err == nil && claims != nil {
-		context.SessionStorer.Flash(w, req, session.Message{Message: "logged"})
-		respondAfterLogged(claims, context)
-		return
+		if err = loginWithHooks(claims, context, BeforeLogin); err == nil {
+			context.SessionStorer.Flash(w, req, session.Message{Message: template.HTML(context.T("auth.flash.logged"))})
+			respondAfterLogged(context)
+			context.Auth.Publish(EventLogin, context, nil)
+			return
+		}
 	}
 
-	context.SessionStorer.Flash(w, req, session.Message{Message: template.HTML(err.Error()), Type: "error"})
-
-	// error handling
-	responder.With("html", func() {
-		context.Auth.Config.Render.Execute("auth/login", context, req, w)
-	}).With([]string{"json"}, func() {
-		// TODO write json error
-	}).Respond(context.Request)
+	context.Auth.Publish(EventLoginFailed, context, map[string]interface{}{"error": err})
+	context.Auth.RespondFailure(context, err, "auth/login")
 }
 
 // DefaultRegisterHandler default register behaviour
 var DefaultRegisterHandler = func(context *Context, register func(*Context) (*claims.Claims, error)) {
-	var (
-		req         = context.Request
-		w           = context.Writer
-		claims, err = register(context)
-	)
+	var claims *claims.Claims
+
+	// create user, auth identity, run AfterRegister hooks in a transaction, so failures and vetoes won't leave orphaned users
+	err := context.Auth.Transaction(context, func(context *Context) (err error) {
+		if claims, err = register(context); err == nil && claims != nil {
+			if err = context.Auth.Bootstrap(context, claims); err == nil {
+				context.Claims = claims
+				err = context.Auth.runHooks(AfterRegister, context)
+			}
+		}
+		return err
+	})
 
 	if err == nil && claims != nil {
-		respondAfterLogged(claims, context)
-		return
+		if err = issueSession(claims, context); err == nil {
+			respondAfterLogged(context)
+			context.Auth.Publish(EventRegistered, context, nil)
+			return
+		}
 	}
 
-	context.SessionStorer.Flash(w, req, session.Message{Message: template.HTML(err.Error()), Type: "error"})
-
-	// error handling
-	responder.With("html", func() {
-		context.Auth.Config.Render.Execute("auth/register", context, req, w)
-	}).With([]string{"json"}, func() {
-		// TODO write json error
-	}).Respond(context.Request)
+	context.Auth.Publish(EventRegisterFailed, context, map[string]interface{}{"error": err})
+	context.Auth.RespondFailure(context, err, "auth/register")
 }
 
 // DefaultLogoutHandler default logout behaviour
 var DefaultLogoutHandler = func(context *Context) {
-	// Clear auth session
-	context.SessionStorer.Delete(context.Writer, context.Request)
+	if claims, err := context.SessionStorer.Get(context.Request); err == nil {
+		context.Claims = claims
+	}
+
+	// Clear auth session, tracked session is revoked, so copied cookies or tokens of it are rejected
+	context.Auth.Logout(context.Writer, context.Request)
+
+	if err := context.Auth.runHooks(AfterLogout, context); err != nil {
+		context.SessionStorer.Flash(context.Writer, context.Request, session.Message{Message: template.HTML(context.TranslateError(err)), Type: "error"})
+	}
+
 	context.Auth.Redirector.Redirect(context.Writer, context.Request, "logout")
+	context.Auth.Publish(EventLogout, context, nil)
 }
 
 // DefaultDeregisterHandler default deregister behaviour
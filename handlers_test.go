@@ -0,0 +1,64 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/qor/auth"
+	"github.com/qor/auth/authtest"
+	"github.com/qor/session/manager"
+)
+
+type user struct {
+	gorm.Model
+	Name string
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	Auth := auth.New(&auth.Config{DB: db, Redirector: redirector{}, UserModel: &user{}, TrackSessions: true})
+	if err := auth.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&user{})
+
+	alice := user{Name: "alice"}
+	db.Create(&alice)
+
+	mux := http.NewServeMux()
+	mux.Handle("/auth/", Auth.NewServeMux())
+	mux.HandleFunc("/account", func(w http.ResponseWriter, req *http.Request) {
+		if Auth.GetCurrentUser(req) == nil {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	handler := manager.SessionManager.Middleware(mux)
+
+	request := func(path string, cookie *http.Cookie) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	cookie := authtest.LoginAs(t, Auth, &alice)
+	if code := request("/account", cookie); code != http.StatusOK {
+		t.Fatalf("expect logged in user before logout, got status %v", code)
+	}
+
+	request("/auth/logout", cookie)
+
+	// replay the cookie issued before logout
+	if code := request("/account", cookie); code != http.StatusUnauthorized {
+		t.Errorf("expect no current user with cookie of logged out session, got status %v", code)
+	}
+}
@@ -0,0 +1,64 @@
+package auth
+
+// HookPoint lifecycle point when hooks are invoked
+type HookPoint string
+
+const (
+	// BeforeLogin invoked after user authorized, before session issued, return an error to reject the login
+	BeforeLogin HookPoint = "before_login"
+	// AfterLogin invoked after session issued, return an error to destroy the session and reject the login
+	AfterLogin HookPoint = "after_login"
+	// AfterRegister invoked after user registered, in the registration's transaction, before committed, return an error to roll back the registration
+	AfterRegister HookPoint = "after_register"
+	// AfterPasswordChange invoked by password providers after password changed, providers should revert the change if got an error
+	AfterPasswordChange HookPoint = "after_password_change"
+	// AfterLogout invoked after session destroyed, returned error will be shown as flash message
+	AfterLogout HookPoint = "after_logout"
+)
+
+// Hook lifecycle hook, receives request context (with claims, provider) and current user, return an error to veto the operation
+type Hook func(context *Context, user interface{}) error
+
+// RegisterHook register hook for lifecycle point
+//
+//	Auth.RegisterHook(auth.AfterRegister, func(context *auth.Context, user interface{}) error {
+//	  return crm.CreateContact(user)
+//	})
+func (auth *Auth) RegisterHook(point HookPoint, hook Hook) {
+	auth.hooksMutex.Lock()
+	defer auth.hooksMutex.Unlock()
+
+	if auth.hooks == nil {
+		auth.hooks = map[HookPoint][]Hook{}
+	}
+	auth.hooks[point] = append(auth.hooks[point], hook)
+}
+
+// RunHooks run hooks registered for lifecycle point in order, stop at the first error
+func (auth *Auth) RunHooks(point HookPoint, context *Context, user interface{}) error {
+	for _, hook := range auth.getHooks(point) {
+		if err := hook(context, user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runHooks run hooks with current user loaded from context's claims
+func (auth *Auth) runHooks(point HookPoint, context *Context) error {
+	if len(auth.getHooks(point)) == 0 {
+		return nil
+	}
+
+	var user interface{}
+	if context.Claims != nil {
+		user, _ = auth.UserStorer.Get(context.Claims, context)
+	}
+	return auth.RunHooks(point, context, user)
+}
+
+func (auth *Auth) getHooks(point HookPoint) []Hook {
+	auth.hooksMutex.RLock()
+	defer auth.hooksMutex.RUnlock()
+	return append([]Hook{}, auth.hooks[point]...)
+}
@@ -0,0 +1,261 @@
+package i18n
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultTranslations default English translations of auth's user-facing strings, errors are translated with their messages as keys, like `invalid password`
+var DefaultTranslations = map[string]string{
+	"auth.flash.logged": "logged",
+	"auth.support":      "Need help? Contact support",
+
+	"auth.form.email":            "Email",
+	"auth.form.password":         "Password",
+	"auth.form.new_password":     "New password",
+	"auth.form.confirm_password": "Confirm password",
+
+	"auth.login.title":           "Sign in",
+	"auth.login.submit":          "Sign in",
+	"auth.login.with":            "Sign in with %v",
+	"auth.login.forgot_password": "Forgot your password?",
+	"auth.login.no_account":      "Don't have an account?",
+
+	"auth.register.title":       "Sign up",
+	"auth.register.submit":      "Sign up",
+	"auth.register.with":        "Sign up with %v",
+	"auth.register.has_account": "Already have an account?",
+
+	"auth.password.new.title":     "Reset your password",
+	"auth.password.new.message":   "Enter your email, we will send you a link to reset your password.",
+	"auth.password.new.submit":    "Send reset link",
+	"auth.password.edit.title":    "Choose a new password",
+	"auth.password.edit.submit":   "Change password",
+	"auth.password.back_to_login": "Back to sign in",
+
+	"auth.organization.invitation.title":   "Join %v",
+	"auth.organization.invitation.message": "You have been invited to join %v as %v.",
+	"auth.organization.invitation.accept":  "Accept",
+	"auth.organization.invitation.decline": "Decline",
+
+	"auth.consent.title":   "Authorize %v",
+	"auth.consent.message": "%v would like to:",
+	"auth.consent.approve": "Allow",
+	"auth.consent.deny":    "Deny",
+
+	"auth.consent.apps.title":      "Connected apps",
+	"auth.consent.apps.granted_at": "Authorized on %v",
+	"auth.consent.apps.revoke":     "Revoke access",
+	"auth.consent.apps.empty":      "You haven't authorized any apps.",
+
+	"auth.login_approval.title":              "Approve this login",
+	"auth.login_approval.message":            "We don't recognize this device or location, we sent you an email, approve the login with the link, or enter the code from the email.",
+	"auth.login_approval.code":               "Code",
+	"auth.login_approval.continue":           "Continue",
+	"auth.login_approval.approved":           "Login approved, continue to sign in.",
+	"auth.login_approval.invalid":            "This login request is invalid or expired, please sign in again.",
+	"auth.login_approval.review":             "Is this you signing in?",
+	"auth.login_approval.approve":            "Yes, approve",
+	"auth.login_approval.deny":               "No, deny",
+	"auth.login_approval.approved_elsewhere": "Login approved, continue on the device you are signing in from.",
+	"auth.login_approval.denied":             "Login denied, please secure your account, like changing your password.",
+
+	"auth.devlogin.title":    "Developer login",
+	"auth.devlogin.message":  "Development only, pick a user to login as.",
+	"auth.devlogin.no_users": "No users found, seed some users first.",
+
+	"auth.mailers.hello":                           "Hello,",
+	"auth.mailers.support":                         "Need help? Contact support",
+	"auth.mailers.organization.invitation.subject": "You have been invited to join an organization",
+	"auth.mailers.organization.invitation.message": "You have been invited to join %v as %v.",
+	"auth.mailers.organization.invitation.view":    "View invitation",
+	"auth.mailers.organization.invitation.expire":  "This invitation will expire at %v.",
+
+	"auth.mailers.login_approval.subject": "Approve login to your account",
+	"auth.mailers.login_approval.message": "Someone is signing in to your account from a device or location we don't recognize.",
+	"auth.mailers.login_approval.review":  "Review the login",
+	"auth.mailers.login_approval.code":    "Or enter code %v on the device you are signing in from.",
+	"auth.mailers.login_approval.expire":  "This request will expire at %v.",
+
+	"auth.mailers.reset_password.subject": "Reset your password",
+	"auth.mailers.reset_password.message": "Someone asked to reset the password of your account.",
+	"auth.mailers.reset_password.reset":   "Reset password",
+	"auth.mailers.reset_password.ignore":  "If this wasn't you, you can ignore this email, your password won't be changed.",
+
+	"auth.mailers.new_device_login.subject":        "New login to your account",
+	"auth.mailers.new_device_login.message":        "We noticed a new login to your account.",
+	"auth.mailers.new_device_login.new_device":     "We noticed a new login to your account from a device you haven't used before.",
+	"auth.mailers.new_device_login.time":           "Time: %v",
+	"auth.mailers.new_device_login.location":       "Approximate location: %v",
+	"auth.mailers.new_device_login.ip_address":     "IP address: %v",
+	"auth.mailers.new_device_login.browser":        "Browser: %v",
+	"auth.mailers.new_device_login.ignore":         "If this was you, you can ignore this email.",
+	"auth.mailers.new_device_login.secure_account": "If not, please secure your account now",
+}
+
+// Config i18n config
+type Config struct {
+	// DefaultLocale locale used if request's locale isn't available, and translation missing in request's locale, default value is `en`
+	DefaultLocale string
+	// CookieName cookie has user's chosen locale, which has higher priority than Accept-Language header, default value is `locale`
+	CookieName string
+	// Translations override catalog, key is locale, value is translations of the locale, which overwrite DefaultTranslations for default locale
+	Translations map[string]map[string]string
+}
+
+// New initialize i18n
+func New(config *Config) *I18n {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if config.DefaultLocale == "" {
+		config.DefaultLocale = "en"
+	}
+
+	if config.CookieName == "" {
+		config.CookieName = "locale"
+	}
+
+	i18n := &I18n{Config: config, translations: map[string]map[string]string{}}
+	i18n.AddTranslations(config.DefaultLocale, DefaultTranslations)
+	for locale, translations := range config.Translations {
+		i18n.AddTranslations(locale, translations)
+	}
+	return i18n
+}
+
+// I18n translate auth's user-facing strings
+type I18n struct {
+	*Config
+	mutex        sync.RWMutex
+	translations map[string]map[string]string
+}
+
+// AddTranslations add translations of locale, existing ones will be overwritten
+func (i18n *I18n) AddTranslations(locale string, translations map[string]string) {
+	i18n.mutex.Lock()
+	defer i18n.mutex.Unlock()
+
+	locale = normalize(locale)
+	if i18n.translations[locale] == nil {
+		i18n.translations[locale] = map[string]string{}
+	}
+
+	for key, value := range translations {
+		i18n.translations[locale][key] = value
+	}
+}
+
+// T translate key to locale, fallback to default locale, then the key itself, args are formatted into translation with fmt.Sprintf
+func (i18n *I18n) T(locale string, key string, args ...interface{}) string {
+	i18n.mutex.RLock()
+	translation, ok := i18n.translations[normalize(locale)][key]
+	if !ok {
+		translation, ok = i18n.translations[normalize(i18n.DefaultLocale)][key]
+	}
+	i18n.mutex.RUnlock()
+
+	if !ok {
+		translation = key
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(translation, args...)
+	}
+	return translation
+}
+
+// Locale detect request's locale from cookie, then Accept-Language header, only available locales are returned, otherwise default locale
+func (i18n *I18n) Locale(req *http.Request) string {
+	if cookie, err := req.Cookie(i18n.CookieName); err == nil {
+		if locale, ok := i18n.match(cookie.Value); ok {
+			return locale
+		}
+	}
+
+	for _, language := range parseAcceptLanguage(req.Header.Get("Accept-Language")) {
+		if locale, ok := i18n.match(language); ok {
+			return locale
+		}
+	}
+	return normalize(i18n.DefaultLocale)
+}
+
+// RTLLanguages languages written from right to left
+var RTLLanguages = []string{"ar", "arc", "dv", "fa", "ha", "he", "khw", "ks", "ku", "ps", "ur", "yi"}
+
+// Direction text direction of locale, `rtl` for right-to-left languages like Arabic, Hebrew, otherwise `ltr`
+func Direction(locale string) string {
+	language := normalize(locale)
+	if idx := strings.Index(language, "-"); idx > 0 {
+		language = language[:idx]
+	}
+
+	for _, rtl := range RTLLanguages {
+		if language == rtl {
+			return "rtl"
+		}
+	}
+	return "ltr"
+}
+
+// match find available locale, `zh-CN` matches `zh-cn`, then `zh`
+func (i18n *I18n) match(locale string) (string, bool) {
+	i18n.mutex.RLock()
+	defer i18n.mutex.RUnlock()
+
+	locale = normalize(locale)
+	if _, ok := i18n.translations[locale]; ok {
+		return locale, true
+	}
+
+	if idx := strings.Index(locale, "-"); idx > 0 {
+		if _, ok := i18n.translations[locale[:idx]]; ok {
+			return locale[:idx], true
+		}
+	}
+	return "", false
+}
+
+func normalize(locale string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(locale), "_", "-", -1))
+}
+
+// parseAcceptLanguage parse Accept-Language header, returns languages sorted by quality
+func parseAcceptLanguage(header string) []string {
+	type language struct {
+		name    string
+		quality float64
+	}
+
+	var languages []language
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		if fields[0] == "" || fields[0] == "*" {
+			continue
+		}
+
+		lang := language{name: fields[0], quality: 1}
+		for _, param := range fields[1:] {
+			if param = strings.TrimSpace(param); strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					lang.quality = q
+				}
+			}
+		}
+		languages = append(languages, lang)
+	}
+
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].quality > languages[j].quality })
+
+	names := make([]string, len(languages))
+	for idx, lang := range languages {
+		names[idx] = lang.name
+	}
+	return names
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"reflect"
+
+	"github.com/jinzhu/gorm"
+	"github.com/qor/auth/claims"
+	"github.com/qor/qor/utils"
+)
+
+// IdentityStoreInterface identity store interface, defined how to find/save auth identities, providers and handlers should use it instead of calling database directly, Auth provides a default one based on gorm
+type IdentityStoreInterface interface {
+	// FindByProviderUID find auth identity with provider and UID, return ErrInvalidAccount if not found
+	FindByProviderUID(context *Context, provider string, uid string) (identity interface{}, err error)
+	// FindByUserID find auth identities linked to user
+	FindByUserID(context *Context, userID string) (identities []interface{}, err error)
+	// Create save a new auth identity
+	Create(context *Context, identity interface{}) error
+	// Update save changed auth identity
+	Update(context *Context, identity interface{}) error
+	// Link link auth identity to user
+	Link(context *Context, identity interface{}, userID string) error
+	// UpdateToken update auth identity's token, like OAuth token
+	UpdateToken(context *Context, identity interface{}, token string) error
+	// Delete delete auth identity permanently
+	Delete(context *Context, identity interface{}) error
+	// Count count all auth identities
+	Count(context *Context) (count int, err error)
+}
+
+// IdentityStore default identity store, save auth identities into database with gorm
+type IdentityStore struct {
+}
+
+var _ IdentityStoreInterface = IdentityStore{}
+
+// NewAuthIdentity initialize a new auth identity of Config.AuthIdentityModel
+func (auth *Auth) NewAuthIdentity() interface{} {
+	return reflect.New(utils.ModelType(auth.Config.AuthIdentityModel)).Interface()
+}
+
+// FindByProviderUID find auth identity with provider and UID
+func (IdentityStore) FindByProviderUID(context *Context, provider string, uid string) (interface{}, error) {
+	var (
+		tx           = context.Auth.GetReadDB(context.Request)
+		authIdentity = context.Auth.NewAuthIdentity()
+	)
+
+	if err := tx.Where("provider = ? AND uid = ?", provider, uid).First(authIdentity).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, ErrInvalidAccount
+		}
+		return nil, err
+	}
+	return authIdentity, nil
+}
+
+// FindByUserID find auth identities linked to user
+func (IdentityStore) FindByUserID(context *Context, userID string) (identities []interface{}, err error) {
+	var (
+		tx      = context.Auth.GetReadDB(context.Request)
+		results = reflect.New(reflect.SliceOf(reflect.PtrTo(utils.ModelType(context.Auth.Config.AuthIdentityModel))))
+	)
+
+	if err = tx.Where("user_id = ?", userID).Find(results.Interface()).Error; err == nil {
+		for i := 0; i < results.Elem().Len(); i++ {
+			identities = append(identities, results.Elem().Index(i).Interface())
+		}
+	}
+	return
+}
+
+// Create save a new auth identity
+func (IdentityStore) Create(context *Context, identity interface{}) error {
+	return context.Auth.GetDB(context.Request).Create(identity).Error
+}
+
+// Update save changed auth identity
+func (IdentityStore) Update(context *Context, identity interface{}) error {
+	return context.Auth.GetDB(context.Request).Save(identity).Error
+}
+
+// Link link auth identity to user
+func (IdentityStore) Link(context *Context, identity interface{}, userID string) error {
+	return context.Auth.GetDB(context.Request).Model(identity).Update("user_id", userID).Error
+}
+
+// UpdateToken update auth identity's token
+func (IdentityStore) UpdateToken(context *Context, identity interface{}, token string) error {
+	return context.Auth.GetDB(context.Request).Model(identity).Update("token", token).Error
+}
+
+// Delete delete auth identity, it is deleted permanently, as provider, uid are unique, soft deleted one will block user to register with them again
+func (IdentityStore) Delete(context *Context, identity interface{}) error {
+	return context.Auth.GetDB(context.Request).Unscoped().Delete(identity).Error
+}
+
+// Count count all auth identities
+func (IdentityStore) Count(context *Context) (count int, err error) {
+	err = context.Auth.GetDB(context.Request).Model(context.Auth.NewAuthIdentity()).Count(&count).Error
+	return
+}
+
+// FindOrCreateIdentity find auth identity with identity's provider and UID, create it if not found, use it instead of gorm's FirstOrCreate in providers' authorize handlers,
+// which isn't atomic, concurrent callbacks could try to create the same identity, the unique index on provider, uid rejects all of them except one, others will get the created one
+func FindOrCreateIdentity(context *Context, identity interface{}) (result interface{}, created bool, err error) {
+	basic, ok := identity.(interface {
+		ToClaims() *claims.Claims
+	})
+	if !ok {
+		return nil, false, ErrInvalidAccount
+	}
+
+	provider, uid := basic.ToClaims().Provider, basic.ToClaims().ID
+	if result, err = context.Auth.IdentityStore.FindByProviderUID(context, provider, uid); err != ErrInvalidAccount {
+		return result, false, err
+	}
+
+	if err = context.Auth.savepoint(context, "auth_create_identity", func() error {
+		return context.Auth.IdentityStore.Create(context, identity)
+	}); err == nil {
+		return identity, true, nil
+	}
+
+	// lost the race, identity has been created by another request, find it from primary DB as it may not be replicated yet
+	primaryContext := *context
+	primaryContext.Request = UsePrimaryDB(context.Request)
+	if result, findErr := context.Auth.IdentityStore.FindByProviderUID(&primaryContext, provider, uid); findErr == nil {
+		return result, false, nil
+	}
+	return nil, false, err
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// IPRules CIDR based allow/deny rules of client IP, like `10.8.0.0/16`, single IPs like `203.0.113.7` are accepted too,
+// denied ranges are checked first, then if Allow is not blank, only IPs in allowed ranges are allowed
+type IPRules struct {
+	Allow []string
+	Deny  []string
+
+	parsed bool
+	allow  []*net.IPNet
+	deny   []*net.IPNet
+}
+
+// Allows check ip is allowed by rules
+func (rules *IPRules) Allows(ip net.IP) bool {
+	if rules == nil {
+		return true
+	}
+
+	allow, deny := rules.allow, rules.deny
+	if !rules.parsed {
+		// rules not parsed by New, invalid ranges deny all IPs
+		var allowErr, denyErr error
+		allow, allowErr = parseCIDRs(rules.Allow)
+		deny, denyErr = parseCIDRs(rules.Deny)
+		if allowErr != nil || denyErr != nil {
+			return false
+		}
+	}
+
+	if ip == nil {
+		return len(allow) == 0 && len(deny) == 0
+	}
+
+	if containsIP(deny, ip) {
+		return false
+	}
+	return len(allow) == 0 || containsIP(allow, ip)
+}
+
+// parse parse Allow, Deny ranges, called by New, so rules aren't parsed for each request
+func (rules *IPRules) parse() (err error) {
+	if rules == nil || rules.parsed {
+		return nil
+	}
+
+	if rules.allow, err = parseCIDRs(rules.Allow); err != nil {
+		return err
+	}
+
+	if rules.deny, err = parseCIDRs(rules.Deny); err != nil {
+		return err
+	}
+	rules.parsed = true
+	return nil
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCIDRs(values []string) ([]*net.IPNet, error) {
+	networks := []*net.IPNet{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP range %q", value)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// GetClientIP get client IP of request, X-Forwarded-For, X-Real-Ip headers are only trusted if the request comes from Config.TrustedProxies,
+// X-Forwarded-For, including all its header lines, is read from right to left, trusted proxies appended themselves are skipped, used to evaluate IP rules
+func (auth *Auth) GetClientIP(req *http.Request) net.IP {
+	remoteAddr := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		remoteAddr = host
+	}
+
+	ip := net.ParseIP(remoteAddr)
+	if ip == nil || !auth.isTrustedProxy(ip) {
+		return ip
+	}
+
+	// proxies may append another header line instead of joining the client's, read all of them
+	if forwardedFor := req.Header.Values("X-Forwarded-For"); len(forwardedFor) > 0 {
+		hops := strings.Split(strings.Join(forwardedFor, ","), ",")
+		for idx := len(hops) - 1; idx >= 0; idx-- {
+			hop := net.ParseIP(strings.TrimSpace(hops[idx]))
+			if hop == nil {
+				return ip
+			}
+
+			if ip = hop; !auth.isTrustedProxy(hop) {
+				return hop
+			}
+		}
+		return ip
+	}
+
+	if realIP := net.ParseIP(strings.TrimSpace(req.Header.Get("X-Real-Ip"))); realIP != nil {
+		return realIP
+	}
+	return ip
+}
+
+func (auth *Auth) isTrustedProxy(ip net.IP) bool {
+	return containsIP(auth.trustedProxies, ip)
+}
+
+// AllowsIP check request's client IP is allowed by global IPRules, and ProviderIPRules of provider if it is not blank
+func (auth *Auth) AllowsIP(req *http.Request, provider string) bool {
+	ip := auth.GetClientIP(req)
+	if !auth.Config.IPRules.Allows(ip) {
+		return false
+	}
+
+	if provider != "" {
+		return auth.Config.ProviderIPRules[provider].Allows(ip)
+	}
+	return true
+}
+
+// checkRoleIPRules reject login if any of user's roles has IP rules not allowing request's client IP, like admins could only login from VPN
+func (auth *Auth) checkRoleIPRules(context *Context, user interface{}) error {
+	if context.Claims == nil {
+		return nil
+	}
+
+	roles, err := auth.RoleStorer.Get(context.Claims.GetUserID(), context)
+	if err != nil {
+		return err
+	}
+
+	ip := auth.GetClientIP(context.Request)
+	for _, role := range roles {
+		if !auth.Config.RoleIPRules[role].Allows(ip) {
+			return ErrIPNotAllowed
+		}
+	}
+	return nil
+}
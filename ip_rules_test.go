@@ -0,0 +1,106 @@
+package auth_test
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/qor/auth"
+)
+
+func TestGetClientIP(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	var (
+		direct  = auth.New(&auth.Config{DB: db, Redirector: redirector{}})
+		proxied = auth.New(&auth.Config{DB: db, Redirector: redirector{}, TrustedProxies: []string{"10.0.0.0/8", "fd00::/8"}})
+	)
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		forwardedFor  []string
+		realIP        string
+		direct, proxy string
+	}{
+		{"no proxy headers", "203.0.113.7:4321", nil, "", "203.0.113.7", "203.0.113.7"},
+		{"forwarded by trusted proxy", "10.0.0.1:4321", []string{"203.0.113.7"}, "", "10.0.0.1", "203.0.113.7"},
+		{"forwarded by chain of trusted proxies", "10.0.0.1:4321", []string{"203.0.113.7, 10.0.0.2, 10.0.0.3"}, "", "10.0.0.1", "203.0.113.7"},
+		{"spoofed hops before client are ignored", "10.0.0.1:4321", []string{"198.51.100.1, 10.0.0.9, 203.0.113.7"}, "", "10.0.0.1", "203.0.113.7"},
+		{"spoofed trusted hop before client", "10.0.0.1:4321", []string{"10.0.0.9, 203.0.113.7, 10.0.0.2"}, "", "10.0.0.1", "203.0.113.7"},
+		{"spoofed header of untrusted remote", "203.0.113.7:4321", []string{"10.0.0.2"}, "10.0.0.3", "203.0.113.7", "203.0.113.7"},
+		{"spoofed header sent as another header line", "10.0.0.1:4321", []string{"198.51.100.1", "203.0.113.7"}, "", "10.0.0.1", "203.0.113.7"},
+		{"invalid hop stops at last trusted proxy", "10.0.0.1:4321", []string{"203.0.113.7, not-an-ip, 10.0.0.2"}, "", "10.0.0.1", "10.0.0.2"},
+		{"all hops trusted", "10.0.0.1:4321", []string{"10.0.0.3, 10.0.0.2"}, "", "10.0.0.1", "10.0.0.3"},
+		{"real ip of trusted proxy", "10.0.0.1:4321", nil, "203.0.113.7", "10.0.0.1", "203.0.113.7"},
+		{"forwarded for takes precedence over real ip", "10.0.0.1:4321", []string{"203.0.113.7"}, "198.51.100.1", "10.0.0.1", "203.0.113.7"},
+		{"ipv6 trusted proxy", "[fd00::1]:4321", []string{"2001:db8::7"}, "", "fd00::1", "2001:db8::7"},
+		{"ipv4-mapped trusted proxy", "[::ffff:10.0.0.1]:4321", []string{"203.0.113.7"}, "", "10.0.0.1", "203.0.113.7"},
+		{"remote address without port", "10.0.0.1", []string{"203.0.113.7"}, "", "10.0.0.1", "203.0.113.7"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/auth/login", nil)
+		req.RemoteAddr = test.remoteAddr
+		for _, value := range test.forwardedFor {
+			req.Header.Add("X-Forwarded-For", value)
+		}
+		if test.realIP != "" {
+			req.Header.Set("X-Real-Ip", test.realIP)
+		}
+
+		if ip := direct.GetClientIP(req); !ip.Equal(net.ParseIP(test.direct)) {
+			t.Errorf("%v: expect client IP %v without trusted proxies, got %v", test.name, test.direct, ip)
+		}
+
+		if ip := proxied.GetClientIP(req); !ip.Equal(net.ParseIP(test.proxy)) {
+			t.Errorf("%v: expect client IP %v with trusted proxies, got %v", test.name, test.proxy, ip)
+		}
+	}
+}
+
+func TestIPRules(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	Auth := auth.New(&auth.Config{
+		DB:             db,
+		Redirector:     redirector{},
+		TrustedProxies: []string{"10.0.0.1"},
+		IPRules:        &auth.IPRules{Allow: []string{"192.168.0.0/16", "2001:db8::/32"}, Deny: []string{"192.168.1.0/24"}},
+	})
+
+	for _, test := range []struct {
+		remoteAddr, forwardedFor string
+		allowed                  bool
+	}{
+		{"192.168.0.7:4321", "", true},
+		{"192.168.1.7:4321", "", false},
+		{"203.0.113.7:4321", "", false},
+		{"[2001:db8::7]:4321", "", true},
+		{"203.0.113.7:4321", "192.168.0.7", false},
+		{"10.0.0.1:4321", "192.168.0.7", true},
+		{"10.0.0.1:4321", "192.168.0.7, 203.0.113.7", false},
+		{"10.0.0.1:4321", "203.0.113.7, 192.168.0.7", true},
+		{"10.0.0.1:4321", "", false},
+		{"invalid", "", false},
+	} {
+		req := httptest.NewRequest("GET", "/auth/login", nil)
+		req.RemoteAddr = test.remoteAddr
+		if test.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", test.forwardedFor)
+		}
+
+		if allowed := Auth.AllowsIP(req, ""); allowed != test.allowed {
+			t.Errorf("remote %v, X-Forwarded-For %q: expect allowed %v, got %v", test.remoteAddr, test.forwardedFor, test.allowed, allowed)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package login_alert
+
+import (
+	"fmt"
+	"html/template"
+	"net/mail"
+
+	"github.com/qor/auth"
+	"github.com/qor/auth/auth_session"
+	"github.com/qor/mailer"
+	"github.com/qor/qor/utils"
+)
+
+const (
+	// ReasonNewDevice login from a device never used before
+	ReasonNewDevice = "new_device"
+	// ReasonNewIPAddress login from an IP address never used before
+	ReasonNewIPAddress = "new_ip_address"
+	// ReasonNewCountry login from a country never logged in before, requires Auth's GeoIPResolver
+	ReasonNewCountry = "new_country"
+)
+
+// Policy decide a login is suspicious or not, based on current session and user's previous sessions, returns reasons if it is suspicious
+type Policy func(context *auth.Context, current *auth_session.AuthSession, previous []auth_session.AuthSession) (reasons []string)
+
+// Config login alert config
+type Config struct {
+	Auth *auth.Auth
+	// Policy decide a login is suspicious or not, default value is DefaultPolicy
+	Policy Policy
+	// MailSubject subject of notification mail, it is translated to request's locale, default value is `auth.mailers.new_device_login.subject`
+	MailSubject string
+	// MailTemplate template of notification mail, default value is `auth/new_device_login`
+	MailTemplate string
+	// SecureAccountURL link in notification mail to secure user's account, like change password page, default value is `{Auth Prefix}/password/edit`
+	SecureAccountURL string
+	// HistoryLimit how many previous sessions are checked, default value is 100
+	HistoryLimit int
+	// Sender send notification, default value is sending mail with Auth's Mailer to user's email
+	Sender func(notification *Notification) error
+}
+
+// Notification suspicious login notification
+type Notification struct {
+	Context          *auth.Context
+	Email            string
+	Session          *auth_session.AuthSession
+	Reasons          []string
+	SecureAccountURL string
+}
+
+// DefaultPolicy login is suspicious if it is from a new device, first login of an user is never suspicious
+var DefaultPolicy Policy = func(context *auth.Context, current *auth_session.AuthSession, previous []auth_session.AuthSession) (reasons []string) {
+	if len(previous) == 0 {
+		return nil
+	}
+
+	for _, session := range previous {
+		if session.DeviceID == current.DeviceID {
+			return nil
+		}
+	}
+	return []string{ReasonNewDevice}
+}
+
+// NewIPAddressPolicy login is suspicious if it is from a new device or new IP address
+var NewIPAddressPolicy Policy = func(context *auth.Context, current *auth_session.AuthSession, previous []auth_session.AuthSession) (reasons []string) {
+	reasons = DefaultPolicy(context, current, previous)
+	if len(previous) == 0 {
+		return reasons
+	}
+
+	for _, session := range previous {
+		if session.IPAddress == current.IPAddress {
+			return reasons
+		}
+	}
+	return append(reasons, ReasonNewIPAddress)
+}
+
+// NewCountryPolicy login is suspicious if it is from a new device or new country, requires Auth's GeoIPResolver
+var NewCountryPolicy Policy = func(context *auth.Context, current *auth_session.AuthSession, previous []auth_session.AuthSession) (reasons []string) {
+	reasons = DefaultPolicy(context, current, previous)
+	if len(previous) == 0 || current.Country == "" {
+		return reasons
+	}
+
+	for _, session := range previous {
+		if session.Country == current.Country {
+			return reasons
+		}
+	}
+	return append(reasons, ReasonNewCountry)
+}
+
+// New initialize login alert, it requires Auth's TrackSessions enabled to compare logins with previous sessions, panics if config is invalid, use NewWithError to handle the error
+func New(config *Config) *LoginAlert {
+	alert, err := NewWithError(config)
+	if err != nil {
+		panic(err)
+	}
+	return alert
+}
+
+// NewWithError initialize login alert, returns a *auth.ConfigError lists all problems if config is invalid
+func NewWithError(config *Config) (*LoginAlert, error) {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if config.Policy == nil {
+		config.Policy = DefaultPolicy
+	}
+
+	if config.MailSubject == "" {
+		config.MailSubject = "auth.mailers.new_device_login.subject"
+	}
+
+	if config.MailTemplate == "" {
+		config.MailTemplate = "auth/new_device_login"
+	}
+
+	if config.SecureAccountURL == "" {
+		config.SecureAccountURL = config.Auth.AuthURL("password/edit")
+	}
+
+	if config.HistoryLimit == 0 {
+		config.HistoryLimit = 100
+	}
+
+	alert := &LoginAlert{Config: config}
+	if config.Sender == nil {
+		config.Sender = alert.sendMail
+	}
+
+	config.Auth.Subscribe(auth.EventLogin, alert.handleLogin)
+	return alert, nil
+}
+
+// Validate validate config
+func (config *Config) Validate() error {
+	configErr := &auth.ConfigError{Name: "login_alert"}
+	if config.Auth == nil {
+		configErr.Add("Auth must be specified")
+	} else if !config.Auth.Config.TrackSessions {
+		configErr.Add("Auth's TrackSessions must be enabled")
+	}
+	return configErr.Err()
+}
+
+// LoginAlert notify user when login from an unrecognized device
+type LoginAlert struct {
+	*Config
+}
+
+// Check check current login is suspicious or not, returns the notification if it is
+func (alert *LoginAlert) Check(context *auth.Context) (*Notification, error) {
+	if context.Claims == nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	// session is just created, read it from primary DB
+	current, err := context.Auth.GetSession(auth.UsePrimaryDB(context.Request), context.Claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous []auth_session.AuthSession
+	if err := context.Auth.GetReadDB(context.Request).Where("user_id = ? AND id <> ?", current.UserID, current.ID).Order("id DESC").Limit(alert.HistoryLimit).Find(&previous).Error; err != nil {
+		return nil, err
+	}
+
+	reasons := alert.Policy(context, current, previous)
+	if len(reasons) == 0 {
+		return nil, nil
+	}
+
+	secureAccountURL := alert.SecureAccountURL
+	if absURL := utils.GetAbsURL(context.Request); absURL.Host != "" {
+		if u, err := absURL.Parse(secureAccountURL); err == nil {
+			secureAccountURL = u.String()
+		}
+	}
+
+	return &Notification{
+		Context:          context,
+		Email:            context.Auth.GetEmail(context, context.Claims),
+		Session:          current,
+		Reasons:          reasons,
+		SecureAccountURL: secureAccountURL,
+	}, nil
+}
+
+func (alert *LoginAlert) handleLogin(event *auth.Event) {
+	if event.Context == nil {
+		return
+	}
+
+	notification, err := alert.Check(event.Context)
+	if err == nil && notification != nil {
+		err = alert.Sender(notification)
+	}
+
+	if err != nil {
+		fmt.Printf("failed to check suspicious login: %v\n", err)
+	}
+}
+
+func (alert *LoginAlert) sendMail(notification *Notification) error {
+	if notification.Email == "" {
+		return nil
+	}
+
+	context := notification.Context
+	return context.Auth.Mailer.Send(
+		mailer.Email{
+			TO:      []mail.Address{{Address: notification.Email}},
+			Subject: context.T(alert.MailSubject),
+		}, context.MailTemplate(alert.MailTemplate, notification, template.FuncMap{
+			"location": func(session *auth_session.AuthSession) string {
+				if session.City != "" {
+					return session.City + ", " + session.Country
+				}
+				return session.Country
+			},
+			"has_reason": func(reason string) bool {
+				for _, r := range notification.Reasons {
+					if r == reason {
+						return true
+					}
+				}
+				return false
+			},
+		}),
+	)
+}
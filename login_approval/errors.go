@@ -0,0 +1,39 @@
+package login_approval
+
+import "github.com/qor/auth"
+
+const (
+	// ErrorCodeApprovalRequired error code of ErrApprovalRequired
+	ErrorCodeApprovalRequired = "login_approval_required"
+	// ErrorCodeApprovalPending error code of ErrApprovalPending
+	ErrorCodeApprovalPending = "login_approval_pending"
+	// ErrorCodeInvalidCode error code of ErrInvalidCode
+	ErrorCodeInvalidCode = "invalid_login_approval_code"
+	// ErrorCodeInvalidApproval error code of ErrInvalidApproval
+	ErrorCodeInvalidApproval = "invalid_login_approval"
+)
+
+// approvalError login approval's error, with its own error code
+type approvalError struct {
+	code    string
+	message string
+}
+
+func (err approvalError) Error() string     { return err.message }
+func (err approvalError) ErrorCode() string { return err.code }
+
+// FailureResponse pending logins are redirected to prompt page, unless Failures configured for the error code
+func (err approvalError) FailureResponse(context *auth.Context) *auth.FailureResponse {
+	return &auth.FailureResponse{Redirect: context.Auth.AuthURL("login_approval/prompt")}
+}
+
+var (
+	// ErrApprovalRequired login from unrecognized location needs approval, approval mail is sent
+	ErrApprovalRequired error = approvalError{code: ErrorCodeApprovalRequired, message: "login approval required"}
+	// ErrApprovalPending pending login isn't approved yet, and no code entered
+	ErrApprovalPending error = approvalError{code: ErrorCodeApprovalPending, message: "login approval pending"}
+	// ErrInvalidCode entered code doesn't match the emailed code
+	ErrInvalidCode error = approvalError{code: ErrorCodeInvalidCode, message: "invalid login approval code"}
+	// ErrInvalidApproval pending login not found, expired, denied or already completed
+	ErrInvalidApproval error = approvalError{code: ErrorCodeInvalidApproval, message: "invalid login approval"}
+)
@@ -0,0 +1,585 @@
+package login_approval
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/qor/auth"
+	"github.com/qor/auth/auth_session"
+	"github.com/qor/auth/claims"
+	"github.com/qor/auth/login_alert"
+	"github.com/qor/mailer"
+	"github.com/qor/qor/utils"
+	"github.com/qor/responder"
+)
+
+func init() {
+	auth.RegisterTables("login_approvals", "login_approval_preferences")
+	auth.RegisterMigration(auth.Migration{ID: "login_approval/001_create_login_approvals", Migrate: func(db *gorm.DB) error {
+		return db.AutoMigrate(&LoginApproval{}, &Preference{}).Error
+	}})
+}
+
+const (
+	// EventApprovalRequested published after approval mail sent for a login from unrecognized location, data has `reasons`
+	EventApprovalRequested = "login_approval.requested"
+	// EventApproved published after user approved a pending login with the emailed link
+	EventApproved = "login_approval.approved"
+	// EventDenied published after user denied a pending login with the emailed link
+	EventDenied = "login_approval.denied"
+)
+
+// LoginApproval pending login from unrecognized location, session is issued after it is approved with the emailed link, or the emailed code is entered
+type LoginApproval struct {
+	gorm.Model
+	UserID   string `gorm:"index"`
+	Provider string
+	// Claims claims of the pending login, in JSON
+	Claims string `gorm:"type:text"`
+	// HashedToken hash of the emailed link's token
+	HashedToken string `gorm:"unique_index"`
+	// HashedBrowserToken hash of the token in the browser's cookie which requested the login, only the browser could complete the login
+	HashedBrowserToken string `gorm:"unique_index"`
+	HashedCode         string
+	Attempts           int
+	DeviceID           string
+	IPAddress          string
+	UserAgent          string
+	Country            string
+	City               string
+	// Reasons why the login needs approval, like `new_device`, `new_country`, separated by space
+	Reasons     string
+	ExpiresAt   time.Time
+	ApprovedAt  *time.Time
+	DeniedAt    *time.Time
+	CompletedAt *time.Time
+}
+
+// GetReasons get reasons why the login needs approval
+func (approval LoginApproval) GetReasons() []string {
+	return strings.Fields(approval.Reasons)
+}
+
+// Location approximate location of the pending login, like `Berlin, Germany`
+func (approval LoginApproval) Location() string {
+	if approval.City != "" {
+		return approval.City + ", " + approval.Country
+	}
+	return approval.Country
+}
+
+// IsPending check the login is still waiting for approval
+func (approval LoginApproval) IsPending(now time.Time) bool {
+	return approval.ApprovedAt == nil && approval.DeniedAt == nil && approval.CompletedAt == nil && now.Before(approval.ExpiresAt)
+}
+
+// Preference user's login approval preference
+type Preference struct {
+	gorm.Model
+	UserID string `gorm:"unique_index"`
+	// OptedOut logins of the user never need approval
+	OptedOut bool
+}
+
+// TableName table name of Preference
+func (Preference) TableName() string {
+	return auth.TableName("login_approval_preferences")
+}
+
+// Config login approval config
+type Config struct {
+	// Policy decide a login needs approval or not, based on the pending session and user's previous sessions, refer login_alert.Policy, default value is login_alert.NewCountryPolicy
+	Policy login_alert.Policy
+	// Expiry how long pending logins could be approved, default value is 15 minutes
+	Expiry time.Duration
+	// MaxAttempts how many wrong codes could be entered before the pending login is invalidated, default value is 5
+	MaxAttempts int
+	// CookieName cookie has the browser token of pending login, default value is `_auth_login_approval`
+	CookieName string
+	// MailSubject subject of approval mail, it is translated to request's locale, default value is `auth.mailers.login_approval.subject`
+	MailSubject string
+	// MailTemplate template of approval mail, default value is `auth/login_approval`
+	MailTemplate string
+	// HistoryLimit how many previous sessions are checked, default value is 100
+	HistoryLimit int
+	// Sender send approval request, default value is sending mail with Auth's Mailer to user's email
+	Sender func(notification *Notification) error
+}
+
+// Notification approval request of pending login
+type Notification struct {
+	Context     *auth.Context
+	Email       string
+	Approval    *LoginApproval
+	Code        string
+	ApprovalURL string
+}
+
+// New initialize login approval provider, register it with `Auth.RegisterProvider`, it requires Auth's TrackSessions enabled to compare logins with previous sessions
+func New(config *Config) *Provider {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if config.Policy == nil {
+		config.Policy = login_alert.NewCountryPolicy
+	}
+
+	if config.Expiry == 0 {
+		config.Expiry = 15 * time.Minute
+	}
+
+	if config.MaxAttempts == 0 {
+		config.MaxAttempts = 5
+	}
+
+	if config.CookieName == "" {
+		config.CookieName = "_auth_login_approval"
+	}
+
+	if config.MailSubject == "" {
+		config.MailSubject = "auth.mailers.login_approval.subject"
+	}
+
+	if config.MailTemplate == "" {
+		config.MailTemplate = "auth/login_approval"
+	}
+
+	if config.HistoryLimit == 0 {
+		config.HistoryLimit = 100
+	}
+
+	provider := &Provider{Config: config}
+	if config.Sender == nil {
+		config.Sender = provider.sendMail
+	}
+	return provider
+}
+
+// Provider login approval provider, logins from unrecognized devices or countries need approval with the emailed link or code before session issued
+type Provider struct {
+	*Config
+	Auth *auth.Auth
+}
+
+// GetName return provider name
+func (Provider) GetName() string {
+	return "login_approval"
+}
+
+// ValidateAuth check Auth's config, login approval compares logins with tracked sessions, so it requires TrackSessions
+func (provider *Provider) ValidateAuth(a *auth.Auth) error {
+	configErr := &auth.ConfigError{Name: "login_approval"}
+	if !a.Config.TrackSessions {
+		configErr.Add("Auth's TrackSessions must be enabled")
+	}
+	return configErr.Err()
+}
+
+// ConfigAuth config auth, check logins before session issued, pending logins are redirected to prompt page by the errors' FailureResponse, unless Failures configured for the error codes
+func (provider *Provider) ConfigAuth(a *auth.Auth) {
+	provider.Auth = a
+	a.RegisterHook(auth.BeforeLogin, provider.checkLogin)
+}
+
+// Login complete pending login of current browser, if it is approved with the emailed link, or form value `code` is the emailed code
+func (provider Provider) Login(context *auth.Context) {
+	context.Request.ParseForm()
+	context.Auth.Config.LoginHandler(context, provider.authorize)
+}
+
+// Logout login approval provider doesn't support logout
+func (provider Provider) Logout(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Register login approval provider doesn't support register
+func (provider Provider) Register(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Deregister login approval provider doesn't support deregister
+func (provider Provider) Deregister(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Callback show pending login of the emailed link's `token`, with buttons to approve or deny it, links could be prefetched by mail scanners, so GET never approves
+func (provider Provider) Callback(context *auth.Context) {
+	approval, err := provider.getApproval(context, "hashed_token", context.Request.URL.Query().Get("token"))
+	if err != nil {
+		http.Error(context.Writer, context.TranslateError(err), http.StatusNotFound)
+		return
+	}
+	provider.renderApproval(context, approval, context.Request.URL.Query().Get("token"), "")
+}
+
+// ServeHTTP serve login approval endpoints
+//
+//	GET  {Auth Prefix}/login_approval/prompt      pending page of current browser, enter the emailed code, respond JSON for JSON requests
+//	POST {Auth Prefix}/login_approval/login       complete pending login, with `code` if it isn't approved with the emailed link
+//	GET  {Auth Prefix}/login_approval/callback    emailed link, show pending login of `token`
+//	POST {Auth Prefix}/login_approval/approve     approve pending login of `token`
+//	POST {Auth Prefix}/login_approval/deny        deny pending login of `token`
+//	POST {Auth Prefix}/login_approval/preference  set current user's preference, `opt_out=true` to never need approval
+func (provider Provider) ServeHTTP(context *auth.Context) {
+	var (
+		req     = context.Request
+		w       = context.Writer
+		reqPath = strings.TrimPrefix(req.URL.Path, context.Auth.URLPrefix)
+		paths   = strings.Split(reqPath, "/")
+	)
+
+	req.ParseForm()
+	if paths[1] != "prompt" && req.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch paths[1] {
+	case "prompt":
+		provider.renderPrompt(context)
+	case "approve", "deny":
+		token := context.FormValue("token")
+		approval, err := provider.getApproval(context, "hashed_token", token)
+		if err == nil {
+			if paths[1] == "approve" {
+				err = provider.Approve(context, approval)
+			} else {
+				err = provider.Deny(context, approval)
+			}
+		}
+
+		if err != nil {
+			http.Error(w, context.TranslateError(err), http.StatusUnprocessableEntity)
+			return
+		}
+		provider.renderApproval(context, approval, token, paths[1])
+	case "preference":
+		claims, err := context.Auth.SessionStorer.Get(req)
+		if err != nil {
+			http.Error(w, context.TranslateError(auth.ErrUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		optOut, _ := strconv.ParseBool(context.FormValue("opt_out"))
+		if err := provider.SetOptOut(context, claims.GetUserID(), optOut); err != nil {
+			http.Error(w, context.TranslateError(err), http.StatusUnprocessableEntity)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// Approve approve pending login, the browser requested it could complete the login then
+func (provider Provider) Approve(context *auth.Context, approval *LoginApproval) error {
+	now := context.Auth.Now()
+	if !approval.IsPending(now) {
+		return ErrInvalidApproval
+	}
+
+	if result := context.Auth.GetDB(context.Request).Model(approval).Where("approved_at IS NULL AND denied_at IS NULL").Update("approved_at", now); result.Error != nil {
+		return result.Error
+	} else if result.RowsAffected == 0 {
+		return ErrInvalidApproval
+	}
+
+	approval.ApprovedAt = &now
+	context.Auth.Publish(EventApproved, context, map[string]interface{}{"user_id": approval.UserID})
+	return nil
+}
+
+// Deny deny pending login, it couldn't be completed, subscribe EventDenied to prompt user securing the account
+func (provider Provider) Deny(context *auth.Context, approval *LoginApproval) error {
+	now := context.Auth.Now()
+	if !approval.IsPending(now) {
+		return ErrInvalidApproval
+	}
+
+	if err := context.Auth.GetDB(context.Request).Model(approval).Update("denied_at", now).Error; err != nil {
+		return err
+	}
+
+	approval.DeniedAt = &now
+	context.Auth.Publish(EventDenied, context, map[string]interface{}{"user_id": approval.UserID, "ip_address": approval.IPAddress})
+	return nil
+}
+
+// OptedOut check user opted out of login approval
+func (provider Provider) OptedOut(context *auth.Context, userID string) bool {
+	var preference Preference
+	return context.Auth.GetReadDB(context.Request).Where("user_id = ?", userID).First(&preference).Error == nil && preference.OptedOut
+}
+
+// SetOptOut set user opted out of login approval or not
+func (provider Provider) SetOptOut(context *auth.Context, userID string, optOut bool) error {
+	var (
+		tx         = context.Auth.GetDB(context.Request)
+		preference Preference
+	)
+
+	tx.Where("user_id = ?", userID).First(&preference)
+	preference.UserID, preference.OptedOut = userID, optOut
+	return tx.Save(&preference).Error
+}
+
+// checkLogin BeforeLogin hook, request approval if the login is from unrecognized location, completing approved logins isn't checked again
+func (provider *Provider) checkLogin(context *auth.Context, user interface{}) error {
+	if context.Claims == nil {
+		return nil
+	}
+
+	if p, ok := context.Provider.(*Provider); ok && p == provider {
+		return nil
+	}
+
+	userID := context.Claims.GetUserID()
+	if provider.OptedOut(context, userID) {
+		return nil
+	}
+
+	email := context.Auth.GetEmail(context, context.Claims)
+	if email == "" {
+		// approval couldn't be sent, users without email are never asked
+		return nil
+	}
+
+	var (
+		req     = context.Request
+		current = &auth_session.AuthSession{
+			UserID:    userID,
+			Provider:  context.Claims.Provider,
+			IPAddress: context.Auth.GetClientIP(req).String(),
+			UserAgent: req.UserAgent(),
+		}
+		previous []auth_session.AuthSession
+	)
+
+	if cookie, err := context.Auth.GetCookie(req, auth.DeviceCookieName); err == nil {
+		current.DeviceID = cookie.Value
+	}
+
+	if location := context.Auth.GetLocation(req); location != nil {
+		current.Country, current.City = location.Country, location.City
+	}
+
+	if err := context.Auth.GetReadDB(req).Where("user_id = ?", userID).Order("id DESC").Limit(provider.HistoryLimit).Find(&previous).Error; err != nil {
+		return err
+	}
+
+	reasons := provider.Policy(context, current, previous)
+	if len(reasons) == 0 {
+		return nil
+	}
+
+	if err := provider.request(context, current, reasons, email); err != nil {
+		return err
+	}
+	return ErrApprovalRequired
+}
+
+// request save pending login, send approval mail, and set browser token into cookie
+func (provider *Provider) request(context *auth.Context, current *auth_session.AuthSession, reasons []string, email string) error {
+	claimsJSON, err := json.Marshal(context.Claims)
+	if err != nil {
+		return err
+	}
+
+	tokens := make([]string, 3)
+	for idx := range tokens {
+		if tokens[idx], err = context.Auth.GenerateToken(); err != nil {
+			return err
+		}
+	}
+
+	var (
+		token        = tokens[0]
+		browserToken = tokens[1]
+		code         = generateCode(tokens[2])
+		approval     = &LoginApproval{
+			UserID:             current.UserID,
+			Provider:           current.Provider,
+			Claims:             string(claimsJSON),
+			HashedToken:        hashToken(token),
+			HashedBrowserToken: hashToken(browserToken),
+			HashedCode:         hashToken(code),
+			DeviceID:           current.DeviceID,
+			IPAddress:          current.IPAddress,
+			UserAgent:          current.UserAgent,
+			Country:            current.Country,
+			City:               current.City,
+			Reasons:            strings.Join(reasons, " "),
+			ExpiresAt:          context.Auth.Now().Add(provider.Expiry),
+		}
+	)
+
+	if err := context.Auth.GetDB(context.Request).Create(approval).Error; err != nil {
+		return err
+	}
+
+	approvalURL := context.Auth.AuthURL("login_approval/callback") + "?token=" + token
+	if absURL := utils.GetAbsURL(context.Request); absURL.Host != "" {
+		if u, err := absURL.Parse(approvalURL); err == nil {
+			approvalURL = u.String()
+		}
+	}
+
+	if err := provider.Sender(&Notification{Context: context, Email: email, Approval: approval, Code: code, ApprovalURL: approvalURL}); err != nil {
+		return err
+	}
+
+	context.Auth.SetCookie(context.Writer, context.Request, &http.Cookie{
+		Name:     provider.CookieName,
+		Value:    browserToken,
+		Path:     "/",
+		Expires:  approval.ExpiresAt,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	context.Auth.Publish(EventApprovalRequested, context, map[string]interface{}{"reasons": reasons})
+	context.Auth.Publish(auth.EventMFAChallenged, context, map[string]interface{}{"factor": "email_code", "reasons": reasons})
+	return nil
+}
+
+// authorize authorize pending login of current browser, returns claims of the pending login
+func (provider Provider) authorize(context *auth.Context) (*claims.Claims, error) {
+	approval, err := provider.getApproval(context, "hashed_browser_token", provider.browserToken(context.Request))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		tx  = context.Auth.GetDB(context.Request)
+		now = context.Auth.Now()
+	)
+
+	if approval.CompletedAt != nil || approval.DeniedAt != nil || !now.Before(approval.ExpiresAt) {
+		return nil, ErrInvalidApproval
+	}
+
+	if approval.ApprovedAt == nil {
+		code := strings.TrimSpace(context.Request.Form.Get("code"))
+		if code == "" {
+			return nil, ErrApprovalPending
+		}
+
+		// count the attempt before verifying the code, so concurrent guesses couldn't exceed MaxAttempts
+		if result := tx.Model(approval).Where("attempts < ?", provider.MaxAttempts).UpdateColumn("attempts", gorm.Expr("attempts + 1")); result.Error != nil {
+			return nil, result.Error
+		} else if result.RowsAffected == 0 {
+			return nil, ErrInvalidApproval
+		}
+
+		if !auth.SecureCompare(hashToken(code), approval.HashedCode) {
+			if result := tx.Model(approval).Where("attempts >= ?", provider.MaxAttempts).UpdateColumn("expires_at", now); result.Error == nil && result.RowsAffected > 0 {
+				return nil, ErrInvalidApproval
+			}
+			return nil, ErrInvalidCode
+		}
+	}
+
+	// complete once, concurrent requests with the same browser token won't issue more sessions
+	if result := tx.Model(approval).Where("completed_at IS NULL").Update("completed_at", now); result.Error != nil {
+		return nil, result.Error
+	} else if result.RowsAffected == 0 {
+		return nil, ErrInvalidApproval
+	}
+
+	context.Auth.DeleteCookie(context.Writer, context.Request, provider.CookieName)
+
+	var pending claims.Claims
+	if err := json.Unmarshal([]byte(approval.Claims), &pending); err != nil {
+		return nil, err
+	}
+	return &pending, nil
+}
+
+func (provider Provider) getApproval(context *auth.Context, column string, token string) (*LoginApproval, error) {
+	var approval LoginApproval
+	if token == "" {
+		return nil, ErrInvalidApproval
+	}
+
+	if err := context.Auth.GetDB(context.Request).Where(column+" = ?", hashToken(token)).First(&approval).Error; err != nil {
+		return nil, ErrInvalidApproval
+	}
+	return &approval, nil
+}
+
+func (provider Provider) browserToken(req *http.Request) string {
+	if cookie, err := provider.Auth.GetCookie(req, provider.CookieName); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// renderPrompt render pending page of current browser
+func (provider Provider) renderPrompt(context *auth.Context) {
+	approval, err := provider.getApproval(context, "hashed_browser_token", provider.browserToken(context.Request))
+	if err == nil && !approval.IsPending(context.Auth.Now()) && approval.ApprovedAt == nil {
+		err = ErrInvalidApproval
+	}
+
+	responder.With("html", func() {
+		context.Execute("auth/login_approval/prompt", template.FuncMap{
+			"approval": func() *LoginApproval {
+				if err != nil {
+					return nil
+				}
+				return approval
+			},
+			"error_description": func() string { return context.Request.URL.Query().Get("error_description") },
+		})
+	}).With([]string{"json"}, func() {
+		w := context.Writer
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": ErrorCodeInvalidApproval, "error_description": context.TranslateError(err)})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"approved": approval.ApprovedAt != nil, "expires_at": approval.ExpiresAt})
+	}).Respond(context.Request)
+}
+
+// renderApproval render pending login of the emailed link, state is blank, `approve` or `deny`
+func (provider Provider) renderApproval(context *auth.Context, approval *LoginApproval, token string, state string) {
+	context.Execute("auth/login_approval/approval", template.FuncMap{
+		"approval": func() *LoginApproval { return approval },
+		"pending":  func() bool { return approval.IsPending(context.Auth.Now()) },
+		"token":    func() string { return token },
+		"state":    func() string { return state },
+	})
+}
+
+func (provider Provider) sendMail(notification *Notification) error {
+	context := notification.Context
+	return context.Auth.Mailer.Send(
+		mailer.Email{
+			TO:      []mail.Address{{Address: notification.Email}},
+			Subject: context.T(provider.MailSubject),
+		}, context.MailTemplate(provider.MailTemplate, notification),
+	)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateCode generate 6 digits code from random hex token
+func generateCode(token string) string {
+	value, _ := strconv.ParseUint(token[:12], 16, 64)
+	return fmt.Sprintf("%06d", value%1000000)
+}
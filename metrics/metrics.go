@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/qor/auth"
+)
+
+// Config metrics config
+type Config struct {
+	Auth *auth.Auth
+	// Namespace metrics namespace, default value is `auth`
+	Namespace string
+	// TokenExchangeBuckets histogram buckets of token exchange latency in seconds, default value is prometheus.DefBuckets
+	TokenExchangeBuckets []float64
+	// ActiveSessions return current active sessions count, sessions are stateless by default, so this gauge is only collected if configured
+	ActiveSessions func() float64
+}
+
+// New initialize metrics collector, subscribe to auth's events, register it to prometheus to expose metrics, panics if config is invalid, use NewWithError to handle the error
+//
+//	prometheus.MustRegister(metrics.New(&metrics.Config{Auth: Auth}))
+func New(config *Config) *Collector {
+	collector, err := NewWithError(config)
+	if err != nil {
+		panic(err)
+	}
+	return collector
+}
+
+// NewWithError initialize metrics collector, returns a *auth.ConfigError lists all problems if config is invalid
+func NewWithError(config *Config) (*Collector, error) {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if config.Namespace == "" {
+		config.Namespace = "auth"
+	}
+
+	if config.TokenExchangeBuckets == nil {
+		config.TokenExchangeBuckets = prometheus.DefBuckets
+	}
+
+	collector := &Collector{
+		Config: config,
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "logins_total",
+			Help:      "Total number of login attempts by provider and outcome.",
+		}, []string{"provider", "outcome"}),
+		registrations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "registrations_total",
+			Help:      "Total number of registration attempts by provider and outcome.",
+		}, []string{"provider", "outcome"}),
+		logouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "logouts_total",
+			Help:      "Total number of logouts by provider.",
+		}, []string{"provider"}),
+		tokenExchange: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: config.Namespace,
+			Name:      "token_exchange_duration_seconds",
+			Help:      "Latency of OAuth token exchange by provider.",
+			Buckets:   config.TokenExchangeBuckets,
+		}, []string{"provider"}),
+		mfaChallenges: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "mfa_challenges_total",
+			Help:      "Total number of MFA challenges by provider.",
+		}, []string{"provider"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: config.Namespace,
+			Name:      "rate_limit_rejections_total",
+			Help:      "Total number of requests rejected by rate limiters by provider.",
+		}, []string{"provider"}),
+	}
+
+	if config.ActiveSessions != nil {
+		collector.activeSessions = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: config.Namespace,
+			Name:      "active_sessions",
+			Help:      "Number of active sessions.",
+		}, config.ActiveSessions)
+	}
+
+	config.Auth.Subscribe(auth.EventAll, collector.handleEvent)
+	return collector, nil
+}
+
+// Validate validate config
+func (config *Config) Validate() error {
+	configErr := &auth.ConfigError{Name: "metrics"}
+	if config.Auth == nil {
+		configErr.Add("Auth must be specified")
+	}
+	return configErr.Err()
+}
+
+// Collector prometheus collector for auth metrics
+type Collector struct {
+	*Config
+	logins         *prometheus.CounterVec
+	registrations  *prometheus.CounterVec
+	logouts        *prometheus.CounterVec
+	tokenExchange  *prometheus.HistogramVec
+	mfaChallenges  *prometheus.CounterVec
+	rateLimited    *prometheus.CounterVec
+	activeSessions prometheus.GaugeFunc
+}
+
+var _ prometheus.Collector = &Collector{}
+
+func (collector *Collector) collectors() []prometheus.Collector {
+	collectors := []prometheus.Collector{collector.logins, collector.registrations, collector.logouts, collector.tokenExchange, collector.mfaChallenges, collector.rateLimited}
+	if collector.activeSessions != nil {
+		collectors = append(collectors, collector.activeSessions)
+	}
+	return collectors
+}
+
+// Describe implement prometheus.Collector
+func (collector *Collector) Describe(descs chan<- *prometheus.Desc) {
+	for _, c := range collector.collectors() {
+		c.Describe(descs)
+	}
+}
+
+// Collect implement prometheus.Collector
+func (collector *Collector) Collect(metrics chan<- prometheus.Metric) {
+	for _, c := range collector.collectors() {
+		c.Collect(metrics)
+	}
+}
+
+// ObserveTokenExchange observe token exchange latency, OAuth providers could call this directly instead of publishing auth.EventTokenExchanged
+func (collector *Collector) ObserveTokenExchange(provider string, duration time.Duration) {
+	collector.tokenExchange.WithLabelValues(provider).Observe(duration.Seconds())
+}
+
+func (collector *Collector) handleEvent(event *auth.Event) {
+	provider := event.ProviderName()
+
+	switch event.Name {
+	case auth.EventLogin:
+		collector.logins.WithLabelValues(provider, "success").Inc()
+	case auth.EventLoginFailed:
+		collector.logins.WithLabelValues(provider, "failure").Inc()
+	case auth.EventRegistered:
+		collector.registrations.WithLabelValues(provider, "success").Inc()
+	case auth.EventRegisterFailed:
+		collector.registrations.WithLabelValues(provider, "failure").Inc()
+	case auth.EventLogout:
+		collector.logouts.WithLabelValues(provider).Inc()
+	case auth.EventTokenExchanged:
+		if duration, ok := event.Data["duration"].(time.Duration); ok {
+			collector.ObserveTokenExchange(provider, duration)
+		}
+	case auth.EventMFAChallenged:
+		collector.mfaChallenges.WithLabelValues(provider).Inc()
+	case auth.EventRateLimited:
+		collector.rateLimited.WithLabelValues(provider).Inc()
+	}
+}
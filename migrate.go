@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/qor/auth/api_key"
+	"github.com/qor/auth/auth_identity"
+	"github.com/qor/auth/auth_session"
+	"github.com/qor/auth/user_role"
+)
+
+// Migration versioned schema migration, applied migrations are saved into table `auth_migrations` and won't be run again
+type Migration struct {
+	// ID unique migration ID, prefix it with package name, like `organization/001_create_organizations`
+	ID      string
+	Migrate func(db *gorm.DB) error
+}
+
+// SchemaMigration applied migration record
+type SchemaMigration struct {
+	ID        string `gorm:"primary_key"`
+	AppliedAt time.Time
+}
+
+// TableName table name of applied migration records
+func (SchemaMigration) TableName() string {
+	return TableName("auth_migrations")
+}
+
+var (
+	migrationsMutex sync.Mutex
+	migrations      = []Migration{
+		{ID: "auth/001_create_auth_identities", Migrate: func(db *gorm.DB) error {
+			return db.AutoMigrate(&auth_identity.AuthIdentity{}).Error
+		}},
+		{ID: "auth/002_create_auth_sessions", Migrate: func(db *gorm.DB) error {
+			return db.AutoMigrate(&auth_session.AuthSession{}).Error
+		}},
+		{ID: "auth/003_create_user_roles", Migrate: func(db *gorm.DB) error {
+			return db.AutoMigrate(&user_role.UserRole{}).Error
+		}},
+		{ID: "auth/004_add_unique_index_to_auth_identities", Migrate: func(db *gorm.DB) error {
+			// remove duplicated provider, uid auth identities before running it
+			return db.Model(&auth_identity.AuthIdentity{}).AddUniqueIndex("uix_auth_identities_provider_uid", "provider", "uid").Error
+		}},
+		{ID: "auth/005_create_api_keys", Migrate: func(db *gorm.DB) error {
+			return db.AutoMigrate(&api_key.APIKey{}).Error
+		}},
+		{ID: "auth/006_create_auth_bootstraps", Migrate: func(db *gorm.DB) error {
+			return db.AutoMigrate(&BootstrapMarker{}).Error
+		}},
+	}
+)
+
+// RegisterMigration register a migration run by Migrate, migrations are run in registered order, packages like organization register their migrations in `init`, so importing them is enough
+func RegisterMigration(migration Migration) {
+	migrationsMutex.Lock()
+	defer migrationsMutex.Unlock()
+
+	for _, m := range migrations {
+		if m.ID == migration.ID {
+			panic(fmt.Sprintf("migration %v already registered", migration.ID))
+		}
+	}
+	migrations = append(migrations, migration)
+}
+
+// Migrate create/update all tables auth needs, run registered migrations haven't been applied in order, each of them in a transaction
+//
+// If you are using customized AuthIdentityModel, UserModel, you still need to migrate them by yourself
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&SchemaMigration{}).Error; err != nil {
+		return err
+	}
+
+	migrationsMutex.Lock()
+	pending := make([]Migration, len(migrations))
+	copy(pending, migrations)
+	migrationsMutex.Unlock()
+
+	for _, migration := range pending {
+		if err := db.Where("id = ?", migration.ID).First(&SchemaMigration{}).Error; err == nil {
+			continue
+		} else if !gorm.IsRecordNotFoundError(err) {
+			return err
+		}
+
+		tx := db.Begin()
+		if err := migration.Migrate(tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to run migration %v: %v", migration.ID, err)
+		}
+
+		if err := tx.Create(&SchemaMigration{ID: migration.ID, AppliedAt: time.Now()}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
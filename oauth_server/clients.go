@@ -0,0 +1,262 @@
+package oauth_server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/qor/auth"
+)
+
+// ValidateClient validate client's metadata, redirect URIs must be absolute https URLs without fragment, http is only allowed for loopback hosts, like native apps' `http://127.0.0.1:8080/callback`,
+// grant types, scopes must be known
+func (server Server) ValidateClient(client *Client) error {
+	for _, redirectURI := range client.GetRedirectURIs() {
+		if u, err := url.Parse(redirectURI); err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" || (u.Scheme != "https" && (u.Scheme != "http" || !isLoopback(u.Hostname()))) {
+			return &Error{Code: ErrInvalidClientMetadata.Code, Description: "invalid redirect_uri " + redirectURI}
+		}
+	}
+
+	for _, grantType := range strings.Fields(client.GrantTypes) {
+		known := false
+		for _, g := range GrantTypes {
+			known = known || g == grantType
+		}
+
+		if !known {
+			return &Error{Code: ErrInvalidClientMetadata.Code, Description: "unsupported grant_type " + grantType}
+		}
+	}
+
+	if !server.knownScopes(client.GetScopes()) {
+		return &Error{Code: ErrInvalidClientMetadata.Code, Description: "invalid scope"}
+	}
+
+	if client.AccessTokenExpiry < 0 || client.RefreshTokenExpiry < 0 {
+		return &Error{Code: ErrInvalidClientMetadata.Code, Description: "invalid token lifetime"}
+	}
+	return nil
+}
+
+// GetClients get all registered clients
+func (server Server) GetClients(context *auth.Context) ([]Client, error) {
+	var clients []Client
+	err := context.Auth.GetReadDB(context.Request).Order("id").Find(&clients).Error
+	return clients, err
+}
+
+// UpdateClient save client's name, redirect URIs, scopes, grant types and token lifetimes, client ID, secret can't be changed
+func (server Server) UpdateClient(context *auth.Context, client *Client) error {
+	if err := server.ValidateClient(client); err != nil {
+		return err
+	}
+
+	return context.Auth.GetDB(context.Request).Model(&Client{}).Where("client_id = ?", client.ClientID).Updates(map[string]interface{}{
+		"name":                 client.Name,
+		"redirect_uris":        client.RedirectURIs,
+		"scopes":               client.Scopes,
+		"grant_types":          client.GrantTypes,
+		"access_token_expiry":  client.AccessTokenExpiry,
+		"refresh_token_expiry": client.RefreshTokenExpiry,
+	}).Error
+}
+
+// RotateClientSecret generate a new secret for confidential client, the previous secret is still accepted in SecretRotationGracePeriod
+func (server Server) RotateClientSecret(context *auth.Context, clientID string) (string, error) {
+	client, err := server.FindClient(context, clientID)
+	if err != nil {
+		return "", err
+	}
+
+	if client.Public {
+		return "", &Error{Code: ErrInvalidClientMetadata.Code, Description: "public clients have no secret"}
+	}
+
+	secret, err := context.Auth.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt := context.Auth.Now().Add(server.SecretRotationGracePeriod)
+
+	err = context.Auth.GetDB(context.Request).Model(&Client{}).Where("id = ?", client.ID).Updates(map[string]interface{}{
+		"hashed_secret":              hashToken(secret),
+		"previous_hashed_secret":     client.HashedSecret,
+		"previous_secret_expires_at": &expiresAt,
+	}).Error
+	return secret, err
+}
+
+// DeleteClient delete client, and revoke all its tokens
+func (server Server) DeleteClient(context *auth.Context, clientID string) error {
+	client, err := server.FindClient(context, clientID)
+	if err != nil {
+		return err
+	}
+
+	tx := context.Auth.GetDB(context.Request).Begin()
+	if err := tx.Model(&Token{}).Where("client_id = ? AND revoked_at IS NULL", clientID).Update("revoked_at", context.Auth.Now()).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Unscoped().Delete(client).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// clientJSON client's representation in clients API
+type clientJSON struct {
+	ClientID             string    `json:"client_id"`
+	ClientSecret         string    `json:"client_secret,omitempty"`
+	Name                 string    `json:"name"`
+	RedirectURIs         []string  `json:"redirect_uris"`
+	Scopes               []string  `json:"scopes"`
+	GrantTypes           []string  `json:"grant_types"`
+	Public               bool      `json:"public"`
+	AccessTokenLifetime  int64     `json:"access_token_lifetime,omitempty"`
+	RefreshTokenLifetime int64     `json:"refresh_token_lifetime,omitempty"`
+	CreatedAt            time.Time `json:"created_at"`
+}
+
+func toClientJSON(client Client, secret string) clientJSON {
+	return clientJSON{
+		ClientID:             client.ClientID,
+		ClientSecret:         secret,
+		Name:                 client.Name,
+		RedirectURIs:         client.GetRedirectURIs(),
+		Scopes:               client.GetScopes(),
+		GrantTypes:           client.GetGrantTypes(),
+		Public:               client.Public,
+		AccessTokenLifetime:  int64(client.AccessTokenExpiry.Seconds()),
+		RefreshTokenLifetime: int64(client.RefreshTokenExpiry.Seconds()),
+		CreatedAt:            client.CreatedAt,
+	}
+}
+
+func (value clientJSON) apply(client *Client) {
+	client.Name = value.Name
+	client.RedirectURIs = strings.Join(value.RedirectURIs, " ")
+	client.Scopes = strings.Join(value.Scopes, " ")
+	client.GrantTypes = strings.Join(value.GrantTypes, " ")
+	client.AccessTokenExpiry = time.Duration(value.AccessTokenLifetime) * time.Second
+	client.RefreshTokenExpiry = time.Duration(value.RefreshTokenLifetime) * time.Second
+}
+
+// ClientsHandler admin API managing clients, mount it with http.StripPrefix, and make sure it is protected, only accessible for admins,
+// client secrets are only responded when created or rotated
+//
+//	GET    /                            list clients
+//	POST   /                            register client `{"name": "Wiki", "redirect_uris": [...], "scopes": [...], "grant_types": [...], "public": false, "access_token_lifetime": 3600}`
+//	GET    /{client_id}                 get client
+//	PUT    /{client_id}                 update client
+//	DELETE /{client_id}                 delete client, revoke its tokens
+//	POST   /{client_id}/rotate_secret   rotate client secret
+func (server *Server) ClientsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var (
+			context = &auth.Context{Auth: server.Auth, Request: req, Writer: w}
+			paths   = strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+		)
+
+		switch {
+		case paths[0] == "" && req.Method == http.MethodGet:
+			clients, err := server.GetClients(context)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+
+			results := make([]clientJSON, len(clients))
+			for idx, client := range clients {
+				results[idx] = toClientJSON(client, "")
+			}
+			writeJSON(w, http.StatusOK, results)
+		case paths[0] == "" && req.Method == http.MethodPost:
+			var value clientJSON
+			if err := json.NewDecoder(req.Body).Decode(&value); err != nil {
+				writeError(w, http.StatusBadRequest, &Error{Code: "invalid_request", Description: err.Error()})
+				return
+			}
+
+			client := Client{Public: value.Public}
+			value.apply(&client)
+			secret, err := server.RegisterClient(context, &client)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			writeJSON(w, http.StatusCreated, toClientJSON(client, secret))
+		case paths[0] == "":
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		case len(paths) == 2 && paths[1] == "rotate_secret" && req.Method == http.MethodPost:
+			secret, err := server.RotateClientSecret(context, paths[0])
+			if err != nil {
+				writeClientError(w, err)
+				return
+			}
+
+			client, err := server.FindClient(context, paths[0])
+			if err != nil {
+				writeClientError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, toClientJSON(*client, secret))
+		case len(paths) == 1:
+			client, err := server.FindClient(context, paths[0])
+			if err != nil {
+				writeClientError(w, err)
+				return
+			}
+
+			switch req.Method {
+			case http.MethodGet:
+				writeJSON(w, http.StatusOK, toClientJSON(*client, ""))
+			case http.MethodPut:
+				var value clientJSON
+				if err := json.NewDecoder(req.Body).Decode(&value); err != nil {
+					writeError(w, http.StatusBadRequest, &Error{Code: "invalid_request", Description: err.Error()})
+					return
+				}
+
+				value.apply(client)
+				if err := server.UpdateClient(context, client); err != nil {
+					writeError(w, http.StatusBadRequest, err)
+					return
+				}
+				writeJSON(w, http.StatusOK, toClientJSON(*client, ""))
+			case http.MethodDelete:
+				if err := server.DeleteClient(context, client.ClientID); err != nil {
+					writeError(w, http.StatusInternalServerError, err)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			}
+		default:
+			http.NotFound(w, req)
+		}
+	})
+}
+
+func writeClientError(w http.ResponseWriter, err error) {
+	if err == ErrInvalidClient {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeError(w, http.StatusBadRequest, err)
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,157 @@
+package oauth_server
+
+import (
+	"net/http"
+
+	"github.com/qor/auth"
+	"github.com/qor/auth/api_key"
+)
+
+// EventTokenRevoked token revoked with revocation endpoint
+const EventTokenRevoked = "oauth_server.token_revoked"
+
+// introspect serve RFC 7662 token introspection endpoint, only confidential clients, like resource servers, could introspect tokens,
+// inactive, unknown tokens are responded with `{"active": false}`
+func (server Server) introspect(context *auth.Context) {
+	context.Request.ParseForm()
+
+	client, err := server.authenticateClient(context)
+	if err == nil && client.Public {
+		err = ErrUnauthorizedClient
+	}
+
+	if err != nil {
+		context.Writer.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		writeError(context.Writer, http.StatusUnauthorized, err)
+		return
+	}
+
+	var (
+		value = context.FormValue("token")
+		now   = context.Auth.Now()
+	)
+
+	if token, refresh := server.findToken(context, value); token != nil {
+		expiresAt := token.ExpiresAt
+		if refresh {
+			expiresAt = *token.RefreshExpiresAt
+		}
+
+		if token.RevokedAt == nil && expiresAt.After(now) {
+			result := map[string]interface{}{
+				"active":     true,
+				"scope":      token.Scopes,
+				"client_id":  token.ClientID,
+				"sub":        token.UserID,
+				"token_type": "Bearer",
+				"iat":        token.CreatedAt.Unix(),
+				"exp":        expiresAt.Unix(),
+			}
+
+			if refresh {
+				result["token_type"] = "refresh_token"
+			}
+
+			if server.Issuer != "" {
+				result["iss"] = server.Issuer
+			}
+			writeJSON(context.Writer, http.StatusOK, result)
+			return
+		}
+	} else if key := server.findAPIKey(context, value); key != nil && key.RevokedAt == nil && (key.ExpiresAt == nil || key.ExpiresAt.After(now)) {
+		result := map[string]interface{}{
+			"active":     true,
+			"scope":      key.Scopes,
+			"sub":        key.UserID,
+			"token_type": "api_key",
+			"iat":        key.CreatedAt.Unix(),
+		}
+
+		if key.ExpiresAt != nil {
+			result["exp"] = key.ExpiresAt.Unix()
+		}
+		writeJSON(context.Writer, http.StatusOK, result)
+		return
+	}
+
+	writeJSON(context.Writer, http.StatusOK, map[string]interface{}{"active": false})
+}
+
+// revoke serve RFC 7009 token revocation endpoint, revoking a refresh token or an access token revokes both of them,
+// clients could only revoke their own tokens, unknown tokens are responded with 200 as the spec requires
+func (server Server) revoke(context *auth.Context) {
+	context.Request.ParseForm()
+
+	client, err := server.authenticateClient(context)
+	if err != nil {
+		context.Writer.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		writeError(context.Writer, http.StatusUnauthorized, err)
+		return
+	}
+
+	var (
+		tx    = context.Auth.GetDB(context.Request)
+		value = context.FormValue("token")
+		now   = context.Auth.Now()
+	)
+
+	if token, _ := server.findToken(context, value); token != nil {
+		if token.ClientID != client.ClientID {
+			writeError(context.Writer, http.StatusBadRequest, ErrUnauthorizedClient)
+			return
+		}
+
+		if token.RevokedAt == nil {
+			if err := tx.Model(&Token{}).Where("id = ?", token.ID).Update("revoked_at", now).Error; err != nil {
+				writeError(context.Writer, http.StatusInternalServerError, err)
+				return
+			}
+			context.Auth.Publish(EventTokenRevoked, context, map[string]interface{}{"client_id": client.ClientID, "user_id": token.UserID})
+		}
+	} else if key := server.findAPIKey(context, value); key != nil && !client.Public && key.RevokedAt == nil {
+		// leaked API keys could be reported, revoked by confidential clients, like secret scanners
+		if err := tx.Model(&api_key.APIKey{}).Where("id = ?", key.ID).Update("revoked_at", now).Error; err != nil {
+			writeError(context.Writer, http.StatusInternalServerError, err)
+			return
+		}
+		context.Auth.Publish(EventTokenRevoked, context, map[string]interface{}{"client_id": client.ClientID, "user_id": key.UserID, "api_key": key.Name})
+	}
+
+	context.Writer.Header().Set("Cache-Control", "no-store")
+	context.Writer.WriteHeader(http.StatusOK)
+}
+
+// findToken find token with access token or refresh token, refresh reports the value is a refresh token
+func (server Server) findToken(context *auth.Context, value string) (token *Token, refresh bool) {
+	if value == "" {
+		return nil, false
+	}
+
+	var (
+		db     = context.Auth.GetDB(context.Request)
+		hashed = hashToken(value)
+		result Token
+	)
+
+	if db.Where("hashed_access_token = ?", hashed).First(&result).Error == nil {
+		return &result, false
+	}
+
+	if db.Where("hashed_refresh_token = ?", hashed).First(&result).Error == nil && result.RefreshExpiresAt != nil {
+		return &result, true
+	}
+	return nil, false
+}
+
+// findAPIKey find API key if Config.APIKeys is enabled
+func (server Server) findAPIKey(context *auth.Context, value string) *api_key.APIKey {
+	var key api_key.APIKey
+	if !server.APIKeys || value == "" {
+		return nil
+	}
+
+	if err := context.Auth.GetDB(context.Request).Where("hashed_key = ?", api_key.Hash(value)).First(&key).Error; err != nil {
+		return nil
+	}
+	return &key
+}
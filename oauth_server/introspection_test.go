@@ -0,0 +1,134 @@
+package oauth_server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qor/auth/oauth_server"
+)
+
+// introspect post token to introspection endpoint with client's credentials
+func (server *testServer) introspect(clientID, secret, token string) (int, map[string]interface{}) {
+	req := httptest.NewRequest("POST", "/auth/oauth/introspect", strings.NewReader(url.Values{"token": {token}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(clientID, secret)
+	w := httptest.NewRecorder()
+	server.handler.ServeHTTP(w, req)
+
+	var result map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&result)
+	return w.Code, result
+}
+
+// issue exchange an authorization code granted scopes for tokens
+func (server *testServer) issue(client *oauth_server.Client, secret string, scope string) map[string]interface{} {
+	code, _ := server.authorize(url.Values{"client_id": {client.ClientID}, "redirect_uri": {redirectURI}, "scope": {scope}})
+	status, result := server.token(url.Values{"grant_type": {"authorization_code"}, "client_id": {client.ClientID}, "client_secret": {secret}, "code": {code}, "redirect_uri": {redirectURI}})
+	if status != http.StatusOK {
+		server.t.Fatalf("expect code exchanged, got %v %v", status, result)
+	}
+	return result
+}
+
+func TestIntrospection(t *testing.T) {
+	server := setup(t)
+	wiki := &oauth_server.Client{Name: "Wiki", Scopes: oauth_server.ScopeOpenID}
+	wikiSecret := server.registerClient(wiki)
+	api := &oauth_server.Client{Name: "API"}
+	apiSecret := server.registerClient(api)
+	spa := &oauth_server.Client{Name: "SPA", Public: true}
+	server.registerClient(spa)
+
+	revoked := server.issue(wiki, wikiSecret, "")
+	if err := server.Server.RevokeTokens(server.context(), "1", wiki.ClientID); err != nil {
+		t.Fatal(err)
+	}
+
+	// access tokens expire in 1 hour
+	expired := server.issue(wiki, wikiSecret, "")
+	server.clock.Advance(2 * time.Hour)
+	active := server.issue(wiki, wikiSecret, oauth_server.ScopeOpenID)
+	if active["id_token"] == nil {
+		t.Fatalf("expect ID token issued, got %v", active)
+	}
+
+	tests := []struct {
+		name      string
+		clientID  string
+		secret    string
+		token     interface{}
+		status    int
+		active    bool
+		tokenType string
+	}{
+		{"access token", wiki.ClientID, wikiSecret, active["access_token"], http.StatusOK, true, "Bearer"},
+		{"access token introspected by resource server", api.ClientID, apiSecret, active["access_token"], http.StatusOK, true, "Bearer"},
+		{"refresh token", wiki.ClientID, wikiSecret, active["refresh_token"], http.StatusOK, true, "refresh_token"},
+		{"expired access token", wiki.ClientID, wikiSecret, expired["access_token"], http.StatusOK, false, ""},
+		{"revoked access token", wiki.ClientID, wikiSecret, revoked["access_token"], http.StatusOK, false, ""},
+		{"revoked refresh token", wiki.ClientID, wikiSecret, revoked["refresh_token"], http.StatusOK, false, ""},
+		{"ID token, signed for the client, not resource servers", api.ClientID, apiSecret, active["id_token"], http.StatusOK, false, ""},
+		{"unknown token", wiki.ClientID, wikiSecret, "unknown", http.StatusOK, false, ""},
+		{"blank token", wiki.ClientID, wikiSecret, "", http.StatusOK, false, ""},
+		{"wrong client secret", wiki.ClientID, apiSecret, active["access_token"], http.StatusUnauthorized, false, ""},
+		{"public client", spa.ClientID, "", active["access_token"], http.StatusUnauthorized, false, ""},
+	}
+
+	for _, test := range tests {
+		token, _ := test.token.(string)
+		status, result := server.introspect(test.clientID, test.secret, token)
+		if status != test.status {
+			t.Errorf("%v: expect status %v, got %v %v", test.name, test.status, status, result)
+			continue
+		}
+
+		if status != http.StatusOK {
+			if result["active"] != nil {
+				t.Errorf("%v: expect no introspection result for unauthorized client, got %v", test.name, result)
+			}
+			continue
+		}
+
+		if result["active"] != test.active {
+			t.Errorf("%v: expect active %v, got %v", test.name, test.active, result)
+			continue
+		}
+
+		if !test.active {
+			if len(result) != 1 {
+				t.Errorf("%v: expect nothing but active leaked for inactive token, got %v", test.name, result)
+			}
+			continue
+		}
+
+		// resource servers check the token is issued to the expected client with client_id
+		if result["token_type"] != test.tokenType || result["client_id"] != wiki.ClientID || result["sub"] != "1" || result["iss"] != server.Server.Issuer {
+			t.Errorf("%v: expect %v of wiki's user, got %v", test.name, test.tokenType, result)
+		}
+	}
+}
+
+func TestRevokeTokenOfAnotherClient(t *testing.T) {
+	server := setup(t)
+	wiki := &oauth_server.Client{Name: "Wiki"}
+	wikiSecret := server.registerClient(wiki)
+	api := &oauth_server.Client{Name: "API"}
+	apiSecret := server.registerClient(api)
+
+	issued := server.issue(wiki, wikiSecret, "")
+
+	req := httptest.NewRequest("POST", "/auth/oauth/revoke", strings.NewReader(url.Values{"token": {issued["access_token"].(string)}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(api.ClientID, apiSecret)
+	w := httptest.NewRecorder()
+	server.handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest || !server.isActive(issued["access_token"]) {
+		t.Errorf("expect token of another client not revoked, got status %v", w.Code)
+	}
+}
@@ -0,0 +1,214 @@
+package oauth_server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/qor/auth"
+)
+
+func init() {
+	auth.RegisterTables("oauth_clients", "oauth_authorization_codes", "oauth_tokens")
+	auth.RegisterMigration(auth.Migration{ID: "oauth_server/001_create_oauth_clients", Migrate: func(db *gorm.DB) error {
+		return db.AutoMigrate(&Client{}).Error
+	}})
+	auth.RegisterMigration(auth.Migration{ID: "oauth_server/002_create_oauth_authorization_codes", Migrate: func(db *gorm.DB) error {
+		return db.AutoMigrate(&AuthorizationCode{}).Error
+	}})
+	auth.RegisterMigration(auth.Migration{ID: "oauth_server/003_create_oauth_tokens", Migrate: func(db *gorm.DB) error {
+		return db.AutoMigrate(&Token{}).Error
+	}})
+	auth.RegisterMigration(auth.Migration{ID: "oauth_server/004_add_nonce_to_oauth_authorization_codes", Migrate: func(db *gorm.DB) error {
+		return db.AutoMigrate(&AuthorizationCode{}).Error
+	}})
+	auth.RegisterMigration(auth.Migration{ID: "oauth_server/005_add_grant_types_and_lifetimes_to_oauth_clients", Migrate: func(db *gorm.DB) error {
+		return db.AutoMigrate(&Client{}).Error
+	}})
+	auth.RegisterMigration(auth.Migration{ID: "oauth_server/006_add_authorization_code_id_to_oauth_tokens", Migrate: func(db *gorm.DB) error {
+		return db.AutoMigrate(&Token{}).Error
+	}})
+}
+
+const (
+	// GrantTypeAuthorizationCode authorization code grant
+	GrantTypeAuthorizationCode = "authorization_code"
+	// GrantTypeRefreshToken refresh token grant
+	GrantTypeRefreshToken = "refresh_token"
+)
+
+// GrantTypes supported grant types
+var GrantTypes = []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken}
+
+// Client OAuth client could request authorization of users
+type Client struct {
+	gorm.Model
+	ClientID string `gorm:"unique_index"`
+	// HashedSecret SHA-256 hash of client secret, blank for public clients
+	HashedSecret string
+	Name         string
+	// RedirectURIs allowed redirect URIs, separated by space, requested redirect_uri must match one of them exactly
+	RedirectURIs string `gorm:"type:text"`
+	// Scopes allowed scopes, separated by space
+	Scopes string `gorm:"type:text"`
+	// Public public clients, like SPAs, mobile apps, can't keep secrets, PKCE is required for them
+	Public bool
+	// GrantTypes allowed grant types, separated by space, blank means all GrantTypes are allowed
+	GrantTypes string
+	// AccessTokenExpiry, RefreshTokenExpiry overwrite server's token lifetimes for the client if not zero
+	AccessTokenExpiry  time.Duration
+	RefreshTokenExpiry time.Duration
+	// PreviousHashedSecret hash of the secret before the last rotation, still accepted until PreviousSecretExpiresAt, so clients could be redeployed without downtime
+	PreviousHashedSecret    string
+	PreviousSecretExpiresAt *time.Time
+}
+
+// TableName table name of OAuth clients
+func (Client) TableName() string {
+	return auth.TableName("oauth_clients")
+}
+
+// GetRedirectURIs get allowed redirect URIs
+func (client Client) GetRedirectURIs() []string {
+	return strings.Fields(client.RedirectURIs)
+}
+
+// GetScopes get allowed scopes
+func (client Client) GetScopes() []string {
+	return strings.Fields(client.Scopes)
+}
+
+// GetGrantTypes get allowed grant types
+func (client Client) GetGrantTypes() []string {
+	if grantTypes := strings.Fields(client.GrantTypes); len(grantTypes) > 0 {
+		return grantTypes
+	}
+	return GrantTypes
+}
+
+// AllowGrantType check grant type is allowed
+func (client Client) AllowGrantType(grantType string) bool {
+	for _, g := range client.GetGrantTypes() {
+		if g == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowRedirectURI check redirect URI is allowed
+func (client Client) AllowRedirectURI(redirectURI string) bool {
+	for _, uri := range client.GetRedirectURIs() {
+		if uri == redirectURI {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowScopes check all scopes are allowed
+func (client Client) AllowScopes(scopes []string) bool {
+	allowed := map[string]bool{}
+	for _, scope := range client.GetScopes() {
+		allowed[scope] = true
+	}
+
+	for _, scope := range scopes {
+		if !allowed[scope] {
+			return false
+		}
+	}
+	return true
+}
+
+// VerifySecret verify client secret
+func (client Client) VerifySecret(secret string) bool {
+	if client.HashedSecret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(client.HashedSecret), []byte(hashToken(secret))) == 1
+}
+
+// VerifyPreviousSecret verify client secret with the secret before the last rotation, it is valid until PreviousSecretExpiresAt
+func (client Client) VerifyPreviousSecret(secret string, now time.Time) bool {
+	if client.PreviousHashedSecret == "" || client.PreviousSecretExpiresAt == nil || !client.PreviousSecretExpiresAt.After(now) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(client.PreviousHashedSecret), []byte(hashToken(secret))) == 1
+}
+
+// AuthorizationCode authorization code issued to client, exchanged for tokens once
+type AuthorizationCode struct {
+	gorm.Model
+	// HashedCode SHA-256 hash of code
+	HashedCode  string `gorm:"unique_index"`
+	ClientID    string `gorm:"index"`
+	UserID      string
+	RedirectURI string `gorm:"type:text"`
+	Scopes      string `gorm:"type:text"`
+	// CodeChallenge, CodeChallengeMethod PKCE challenge, method is `S256` or `plain`
+	CodeChallenge       string
+	CodeChallengeMethod string
+	// Nonce OpenID Connect nonce, returned in ID token
+	Nonce     string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// TableName table name of authorization codes
+func (AuthorizationCode) TableName() string {
+	return auth.TableName("oauth_authorization_codes")
+}
+
+// GetScopes get authorized scopes
+func (code AuthorizationCode) GetScopes() []string {
+	return strings.Fields(code.Scopes)
+}
+
+// Token access token and refresh token issued to client, only SHA-256 hashes of them are saved
+type Token struct {
+	gorm.Model
+	HashedAccessToken  string `gorm:"unique_index"`
+	HashedRefreshToken string `gorm:"index"`
+	ClientID           string `gorm:"index"`
+	UserID             string `gorm:"index"`
+	Scopes             string `gorm:"type:text"`
+	// AuthorizationCodeID authorization code the token is issued from, refreshed tokens keep it, so all of them are revoked if the code is replayed
+	AuthorizationCodeID uint `gorm:"index"`
+	ExpiresAt           time.Time
+	RefreshExpiresAt    *time.Time
+	RevokedAt           *time.Time
+}
+
+// TableName table name of tokens
+func (Token) TableName() string {
+	return auth.TableName("oauth_tokens")
+}
+
+// GetScopes get granted scopes
+func (token Token) GetScopes() []string {
+	return strings.Fields(token.Scopes)
+}
+
+// IsActive check access token is not revoked nor expired at now
+func (token Token) IsActive(now time.Time) bool {
+	return token.RevokedAt == nil && token.ExpiresAt.After(now)
+}
+
+// HasScope check token has been granted the scope
+func (token Token) HasScope(scope string) bool {
+	for _, s := range token.GetScopes() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,742 @@
+// Package oauth_server OAuth 2.0 authorization server, let applications built on auth act as an OAuth provider themselves, supports authorization code grant with PKCE, refresh token grant,
+// and OpenID Connect provider mode, issuing ID tokens, serving discovery document, JWKS and userinfo endpoint
+package oauth_server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/qor/auth"
+	"github.com/qor/auth/consent"
+)
+
+const (
+	// EventAuthorized user authorized a client, authorization code issued
+	EventAuthorized = "oauth_server.authorized"
+	// EventTokenIssued tokens issued to a client
+	EventTokenIssued = "oauth_server.token_issued"
+	// EventTokensRevoked tokens issued from an authorization code are revoked, as the code or a rotated refresh token was reused, data has `authorization_code_id`, `reason`
+	EventTokensRevoked = "oauth_server.tokens_revoked"
+)
+
+var (
+	// ErrInvalidClient client not found, or client authentication failed
+	ErrInvalidClient = &Error{Code: "invalid_client", Description: "invalid client"}
+	// ErrInvalidGrant authorization code, refresh token is invalid, expired, used or issued to another client
+	ErrInvalidGrant = &Error{Code: "invalid_grant", Description: "invalid grant"}
+	// ErrInvalidScope requested scopes aren't allowed for client
+	ErrInvalidScope = &Error{Code: "invalid_scope", Description: "invalid scope"}
+	// ErrInvalidRedirectURI redirect_uri isn't registered for client
+	ErrInvalidRedirectURI = &Error{Code: "invalid_request", Description: "invalid redirect_uri"}
+	// ErrPKCERequired public clients must use PKCE
+	ErrPKCERequired = &Error{Code: "invalid_request", Description: "code_challenge required"}
+	// ErrUnsupportedResponseType only response type `code` is supported
+	ErrUnsupportedResponseType = &Error{Code: "unsupported_response_type", Description: "unsupported response_type"}
+	// ErrUnsupportedGrantType unsupported grant type
+	ErrUnsupportedGrantType = &Error{Code: "unsupported_grant_type", Description: "unsupported grant_type"}
+	// ErrAccessDenied user denied authorization
+	ErrAccessDenied = &Error{Code: "access_denied", Description: "access denied"}
+	// ErrInvalidToken access token is invalid, expired or revoked
+	ErrInvalidToken = &Error{Code: "invalid_token", Description: "invalid token"}
+	// ErrUnauthorizedClient client isn't allowed to use the grant type, or introspect, revoke the token
+	ErrUnauthorizedClient = &Error{Code: "unauthorized_client", Description: "unauthorized client"}
+	// ErrInvalidClientMetadata client's redirect URIs, grant types, scopes are invalid
+	ErrInvalidClientMetadata = &Error{Code: "invalid_client_metadata", Description: "invalid client metadata"}
+)
+
+// Error OAuth error, responded as `{"error": Code, "error_description": Description}`
+type Error struct {
+	Code        string
+	Description string
+}
+
+func (err *Error) Error() string {
+	return err.Description
+}
+
+// ErrorCode error code of OAuth error
+func (err *Error) ErrorCode() string {
+	return err.Code
+}
+
+// Config OAuth authorization server config
+type Config struct {
+	// Scopes known scopes, could be granted to clients
+	Scopes []consent.Scope
+	// Consent prompt users to consent requested scopes before issuing authorization codes, or users are never prompted, which is only suitable for first-party clients
+	Consent *consent.Provider
+	// LoginURL users haven't logged in are redirected to it with query `return_to`, default value is `{Auth Prefix}/login`
+	LoginURL string
+	// AuthorizationCodeExpiry default value is 10 minutes
+	AuthorizationCodeExpiry time.Duration
+	// AccessTokenExpiry default value is 1 hour
+	AccessTokenExpiry time.Duration
+	// RefreshTokenExpiry default value is 30 days
+	RefreshTokenExpiry time.Duration
+	// SecretRotationGracePeriod how long a client's previous secret is still accepted after rotating, default value is 24 hours
+	SecretRotationGracePeriod time.Duration
+	// APIKeys introspection, revocation endpoints accept users' API keys too, so resource servers could validate, kill them the same way as access tokens
+	APIKeys bool
+
+	// Issuer enable OpenID Connect provider mode with SigningKey, it is the URL of the server, like `https://example.com/auth/oauth`, discovery document is served at `{Issuer}/.well-known/openid-configuration`
+	Issuer string
+	// SigningKey key signing ID tokens, *rsa.PrivateKey or *ecdsa.PrivateKey
+	SigningKey interface{}
+	// KeyID key ID of SigningKey, set it to a new value after rotating keys
+	KeyID string
+	// IDTokenExpiry default value is 1 hour
+	IDTokenExpiry time.Duration
+	// UserClaims get user's claims for granted scopes, used in ID tokens and userinfo endpoint, default is UserClaims
+	UserClaims func(context *auth.Context, userID string, scopes []string) (map[string]interface{}, error)
+}
+
+// New initialize OAuth authorization server
+func New(config *Config) *Server {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if config.AuthorizationCodeExpiry == 0 {
+		config.AuthorizationCodeExpiry = 10 * time.Minute
+	}
+
+	if config.AccessTokenExpiry == 0 {
+		config.AccessTokenExpiry = time.Hour
+	}
+
+	if config.RefreshTokenExpiry == 0 {
+		config.RefreshTokenExpiry = 30 * 24 * time.Hour
+	}
+
+	if config.SecretRotationGracePeriod == 0 {
+		config.SecretRotationGracePeriod = 24 * time.Hour
+	}
+
+	if config.IDTokenExpiry == 0 {
+		config.IDTokenExpiry = time.Hour
+	}
+
+	if config.UserClaims == nil {
+		config.UserClaims = UserClaims
+	}
+
+	return &Server{Config: config}
+}
+
+// Server OAuth authorization server, register it as a provider
+//
+//	GET  {Auth Prefix}/oauth/authorize  authorization endpoint, issue authorization codes
+//	POST {Auth Prefix}/oauth/token      token endpoint, exchange authorization codes, refresh tokens for tokens
+//	POST {Auth Prefix}/oauth/introspect introspection endpoint (RFC 7662), confidential clients check tokens are active
+//	POST {Auth Prefix}/oauth/revoke     revocation endpoint (RFC 7009), clients revoke their tokens
+//
+// If OpenID Connect is enabled:
+//
+//	GET  {Auth Prefix}/oauth/.well-known/openid-configuration  discovery document
+//	GET  {Auth Prefix}/oauth/jwks                               public keys verifying ID tokens
+//	GET  {Auth Prefix}/oauth/userinfo                           claims of access token's user
+type Server struct {
+	*Config
+	Auth *auth.Auth
+}
+
+// GetName return provider name
+func (Server) GetName() string {
+	return "oauth"
+}
+
+// ConfigAuth config auth
+func (server *Server) ConfigAuth(Auth *auth.Auth) {
+	server.Auth = Auth
+
+	if server.Config.LoginURL == "" {
+		server.Config.LoginURL = Auth.AuthURL("login")
+	}
+
+	if server.Consent != nil && server.Consent.ClientName == nil {
+		server.Consent.ClientName = func(context *auth.Context, clientID string) string {
+			if client, err := server.FindClient(context, clientID); err == nil && client.Name != "" {
+				return client.Name
+			}
+			return clientID
+		}
+	}
+
+	// tokens are issued with granted scopes, revoke them when users revoke their grants on connected apps page
+	Auth.Subscribe(consent.EventConsentRevoked, func(event *auth.Event) {
+		userID, _ := event.Data["user_id"].(string)
+		clientID, _ := event.Data["client_id"].(string)
+		if event.Context != nil && userID != "" && clientID != "" {
+			server.RevokeTokens(event.Context, userID, clientID)
+		}
+	})
+}
+
+// Login OAuth server doesn't support login
+func (server Server) Login(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Logout OAuth server doesn't support logout
+func (server Server) Logout(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Register OAuth server doesn't support register
+func (server Server) Register(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Deregister OAuth server doesn't support deregister
+func (server Server) Deregister(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Callback OAuth server doesn't support callback
+func (server Server) Callback(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// ServeHTTP serve authorization, token, introspection, revocation endpoints
+func (server Server) ServeHTTP(context *auth.Context) {
+	var (
+		reqPath = strings.TrimPrefix(context.Request.URL.Path, context.Auth.URLPrefix)
+		paths   = strings.Split(reqPath, "/")
+	)
+
+	switch paths[1] {
+	case "authorize":
+		server.authorize(context)
+	case "token", "introspect", "revoke":
+		if context.Request.Method != http.MethodPost {
+			http.Error(context.Writer, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		switch paths[1] {
+		case "token":
+			server.token(context)
+		case "introspect":
+			server.introspect(context)
+		default:
+			server.revoke(context)
+		}
+	case ".well-known", "jwks", "userinfo":
+		if !server.OpenIDEnabled() || (paths[1] == ".well-known" && (len(paths) < 3 || paths[2] != "openid-configuration")) {
+			http.NotFound(context.Writer, context.Request)
+			return
+		}
+
+		switch paths[1] {
+		case ".well-known":
+			server.discovery(context)
+		case "jwks":
+			server.jwks(context)
+		default:
+			server.userinfo(context)
+		}
+	default:
+		http.NotFound(context.Writer, context.Request)
+	}
+}
+
+// CSRFExempt token, introspection, revocation, userinfo endpoints are authenticated with client credentials or access tokens, not cookies
+func (server Server) CSRFExempt(req *http.Request) bool {
+	switch strings.Split(strings.TrimPrefix(req.URL.Path, server.Auth.URLPrefix), "/")[1] {
+	case "token", "introspect", "revoke", "userinfo":
+		return true
+	}
+	return false
+}
+
+// RegisterClient register a client, generate client ID, and client secret for confidential clients, returns the secret, which is only saved hashed
+func (server Server) RegisterClient(context *auth.Context, client *Client) (secret string, err error) {
+	if err = server.ValidateClient(client); err != nil {
+		return
+	}
+
+	if client.ClientID == "" {
+		clientID, err := context.Auth.GenerateToken()
+		if err != nil {
+			return "", err
+		}
+		client.ClientID = clientID[:32]
+	}
+
+	if !client.Public {
+		if secret, err = context.Auth.GenerateToken(); err != nil {
+			return "", err
+		}
+		client.HashedSecret = hashToken(secret)
+	}
+
+	err = context.Auth.GetDB(context.Request).Create(client).Error
+	return
+}
+
+// FindClient find client with client ID
+func (server Server) FindClient(context *auth.Context, clientID string) (*Client, error) {
+	var client Client
+	if clientID == "" {
+		return nil, ErrInvalidClient
+	}
+
+	if err := context.Auth.GetReadDB(context.Request).Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		return nil, ErrInvalidClient
+	}
+	return &client, nil
+}
+
+// ValidateAccessToken validate access token, return its token record if it is active, resource servers could use it to authenticate requests:
+//
+//	token, err := OAuthServer.ValidateAccessToken(&auth.Context{Auth: Auth, Request: req}, oauth_server.BearerToken(req))
+//	if err != nil || !token.HasScope("profile") {
+//	  http.Error(w, "unauthorized", http.StatusUnauthorized)
+//	}
+func (server Server) ValidateAccessToken(context *auth.Context, accessToken string) (*Token, error) {
+	var token Token
+	if accessToken == "" {
+		return nil, ErrInvalidToken
+	}
+
+	if err := context.Auth.GetDB(context.Request).Where("hashed_access_token = ?", hashToken(accessToken)).First(&token).Error; err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if !token.IsActive(context.Auth.Now()) {
+		return nil, ErrInvalidToken
+	}
+	return &token, nil
+}
+
+// RevokeTokens revoke all active tokens issued to client for user
+func (server Server) RevokeTokens(context *auth.Context, userID string, clientID string) error {
+	return context.Auth.GetDB(context.Request).Model(&Token{}).Where("user_id = ? AND client_id = ? AND revoked_at IS NULL", userID, clientID).Update("revoked_at", context.Auth.Now()).Error
+}
+
+// BearerToken get bearer token from request's `Authorization` header
+func BearerToken(req *http.Request) string {
+	if value := req.Header.Get("Authorization"); len(value) > 7 && strings.EqualFold(value[:7], "Bearer ") {
+		return strings.TrimSpace(value[7:])
+	}
+	return ""
+}
+
+// authorizeRequest parsed authorization request
+type authorizeRequest struct {
+	client              *Client
+	redirectURI         string
+	state               string
+	scopes              []string
+	codeChallenge       string
+	codeChallengeMethod string
+	nonce               string
+}
+
+func (server Server) authorize(context *auth.Context) {
+	var (
+		req = context.Request
+		w   = context.Writer
+	)
+
+	req.ParseForm()
+	request, err := server.parseAuthorizeRequest(context)
+	if request == nil {
+		// never redirect to unverified redirect URIs
+		http.Error(w, context.TranslateError(err), http.StatusBadRequest)
+		return
+	}
+
+	if err == nil && context.FormValue("error") == ErrAccessDenied.Code {
+		err = ErrAccessDenied // denied on consent page
+	}
+
+	if err != nil {
+		server.redirectError(context, request, err)
+		return
+	}
+
+	claims, err := context.Auth.SessionStorer.Get(req)
+	if err != nil {
+		loginURL := url.URL{Path: server.LoginURL, RawQuery: url.Values{"return_to": []string{req.URL.RequestURI()}}.Encode()}
+		http.Redirect(w, req, loginURL.String(), http.StatusSeeOther)
+		return
+	}
+	context.Claims = claims
+
+	if server.Consent != nil && !server.Consent.Require(context, request.client.ClientID, request.scopes, req.URL.RequestURI()) {
+		return
+	}
+
+	code, err := context.Auth.GenerateToken()
+	if err != nil {
+		server.redirectError(context, request, &Error{Code: "server_error", Description: err.Error()})
+		return
+	}
+
+	authorizationCode := AuthorizationCode{
+		HashedCode:          hashToken(code),
+		ClientID:            request.client.ClientID,
+		UserID:              claims.GetUserID(),
+		RedirectURI:         request.redirectURI,
+		Scopes:              strings.Join(request.scopes, " "),
+		CodeChallenge:       request.codeChallenge,
+		CodeChallengeMethod: request.codeChallengeMethod,
+		Nonce:               request.nonce,
+		ExpiresAt:           context.Auth.Now().Add(server.AuthorizationCodeExpiry),
+	}
+
+	if err := context.Auth.GetDB(req).Create(&authorizationCode).Error; err != nil {
+		server.redirectError(context, request, &Error{Code: "server_error", Description: err.Error()})
+		return
+	}
+
+	context.Auth.Publish(EventAuthorized, context, map[string]interface{}{"client_id": request.client.ClientID, "scopes": request.scopes})
+	server.redirect(context, request, url.Values{"code": []string{code}})
+}
+
+// parseAuthorizeRequest parse authorization request, returns nil request if client or redirect URI is invalid, which means errors can't be redirected to client
+func (server Server) parseAuthorizeRequest(context *auth.Context) (*authorizeRequest, error) {
+	client, err := server.FindClient(context, context.FormValue("client_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	request := &authorizeRequest{client: client, redirectURI: context.FormValue("redirect_uri"), state: context.FormValue("state"), nonce: context.FormValue("nonce")}
+	if redirectURIs := client.GetRedirectURIs(); request.redirectURI == "" && len(redirectURIs) == 1 {
+		request.redirectURI = redirectURIs[0]
+	}
+
+	if !client.AllowRedirectURI(request.redirectURI) {
+		return nil, ErrInvalidRedirectURI
+	}
+
+	if context.FormValue("response_type") != "code" {
+		return request, ErrUnsupportedResponseType
+	}
+
+	if !client.AllowGrantType(GrantTypeAuthorizationCode) {
+		return request, ErrUnauthorizedClient
+	}
+
+	request.scopes = consent.ParseScopes(context.FormValue("scope"))
+	if !client.AllowScopes(request.scopes) || !server.knownScopes(request.scopes) {
+		return request, ErrInvalidScope
+	}
+
+	request.codeChallenge = context.FormValue("code_challenge")
+	request.codeChallengeMethod = context.FormValue("code_challenge_method")
+	if request.codeChallenge != "" && request.codeChallengeMethod == "" {
+		request.codeChallengeMethod = "plain"
+	}
+
+	if request.codeChallengeMethod != "" && request.codeChallengeMethod != "S256" && request.codeChallengeMethod != "plain" {
+		return request, &Error{Code: "invalid_request", Description: "unsupported code_challenge_method"}
+	}
+
+	if client.Public && request.codeChallenge == "" {
+		return request, ErrPKCERequired
+	}
+	return request, nil
+}
+
+func (server Server) knownScopes(scopes []string) bool {
+	if len(server.Scopes) == 0 {
+		return true
+	}
+
+	for _, name := range scopes {
+		known := name == ScopeOpenID && server.OpenIDEnabled()
+		for _, scope := range server.Scopes {
+			if scope.Name == name {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			return false
+		}
+	}
+	return true
+}
+
+func (server Server) redirect(context *auth.Context, request *authorizeRequest, params url.Values) {
+	redirectURL, _ := url.Parse(request.redirectURI)
+	query := redirectURL.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+
+	if request.state != "" {
+		query.Set("state", request.state)
+	}
+	redirectURL.RawQuery = query.Encode()
+	http.Redirect(context.Writer, context.Request, redirectURL.String(), http.StatusFound)
+}
+
+func (server Server) redirectError(context *auth.Context, request *authorizeRequest, err error) {
+	oauthErr := toError(err)
+	server.redirect(context, request, url.Values{"error": []string{oauthErr.Code}, "error_description": []string{oauthErr.Description}})
+}
+
+func (server Server) token(context *auth.Context) {
+	start := time.Now()
+	context.Request.ParseForm()
+
+	client, err := server.authenticateClient(context)
+	if err != nil {
+		context.Writer.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		writeError(context.Writer, http.StatusUnauthorized, err)
+		return
+	}
+
+	var (
+		result    map[string]interface{}
+		grantType = context.FormValue("grant_type")
+	)
+
+	switch {
+	case !client.AllowGrantType(grantType):
+		err = ErrUnauthorizedClient
+	case grantType == GrantTypeAuthorizationCode:
+		result, err = server.exchangeAuthorizationCode(context, client)
+	case grantType == GrantTypeRefreshToken:
+		result, err = server.refreshToken(context, client)
+	default:
+		err = ErrUnsupportedGrantType
+	}
+
+	if err != nil {
+		writeError(context.Writer, http.StatusBadRequest, err)
+		return
+	}
+
+	context.Auth.Publish(auth.EventTokenExchanged, context, map[string]interface{}{"client_id": client.ClientID, "grant_type": grantType, "duration": time.Since(start)})
+	writeJSON(context.Writer, http.StatusOK, result)
+}
+
+// authenticateClient authenticate client with HTTP basic auth or form values `client_id`, `client_secret`, public clients are only identified with client ID
+func (server Server) authenticateClient(context *auth.Context) (*Client, error) {
+	clientID, clientSecret, ok := context.Request.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = context.FormValue("client_id"), context.FormValue("client_secret")
+	}
+
+	client, err := server.FindClient(context, clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !client.Public && !client.VerifySecret(clientSecret) && !client.VerifyPreviousSecret(clientSecret, context.Auth.Now()) {
+		return nil, ErrInvalidClient
+	}
+	return client, nil
+}
+
+func (server Server) exchangeAuthorizationCode(context *auth.Context, client *Client) (map[string]interface{}, error) {
+	var (
+		tx                = context.Auth.GetDB(context.Request)
+		now               = context.Auth.Now()
+		authorizationCode AuthorizationCode
+	)
+
+	if err := tx.Where("hashed_code = ?", hashToken(context.FormValue("code"))).First(&authorizationCode).Error; err != nil {
+		return nil, ErrInvalidGrant
+	}
+
+	// a replayed code may have been stolen, revoke tokens issued from it, RFC 6749 section 4.1.2
+	if authorizationCode.UsedAt != nil {
+		if err := server.revokeCodeTokens(context, authorizationCode.ID, "authorization_code_replayed"); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidGrant
+	}
+
+	if authorizationCode.ClientID != client.ClientID || authorizationCode.RedirectURI != context.FormValue("redirect_uri") || !authorizationCode.ExpiresAt.After(now) {
+		return nil, ErrInvalidGrant
+	}
+
+	if !verifyCodeChallenge(authorizationCode.CodeChallenge, authorizationCode.CodeChallengeMethod, context.FormValue("code_verifier")) {
+		return nil, ErrInvalidGrant
+	}
+
+	// codes could be used only once, update it conditionally, so concurrent exchanges won't both succeed
+	if result := tx.Model(&AuthorizationCode{}).Where("id = ? AND used_at IS NULL", authorizationCode.ID).Update("used_at", now); result.Error != nil || result.RowsAffected != 1 {
+		return nil, ErrInvalidGrant
+	}
+
+	return server.issueToken(context, client, authorizationCode.ID, authorizationCode.UserID, authorizationCode.GetScopes(), authorizationCode.Nonce)
+}
+
+// revokeCodeTokens revoke tokens issued from authorization code, including refreshed ones
+func (server Server) revokeCodeTokens(context *auth.Context, codeID uint, reason string) error {
+	tx := context.Auth.GetDB(context.Request)
+	if err := tx.Model(&Token{}).Where("authorization_code_id = ? AND revoked_at IS NULL", codeID).Update("revoked_at", context.Auth.Now()).Error; err != nil {
+		return err
+	}
+
+	context.Auth.Publish(EventTokensRevoked, context, map[string]interface{}{"authorization_code_id": codeID, "reason": reason})
+	return nil
+}
+
+func (server Server) refreshToken(context *auth.Context, client *Client) (map[string]interface{}, error) {
+	var (
+		tx    = context.Auth.GetDB(context.Request)
+		now   = context.Auth.Now()
+		token Token
+	)
+
+	refreshToken := context.FormValue("refresh_token")
+	if refreshToken == "" {
+		return nil, ErrInvalidGrant
+	}
+
+	if err := tx.Where("hashed_refresh_token = ?", hashToken(refreshToken)).First(&token).Error; err != nil {
+		return nil, ErrInvalidGrant
+	}
+
+	if token.ClientID != client.ClientID || token.RefreshExpiresAt == nil || !token.RefreshExpiresAt.After(now) {
+		return nil, ErrInvalidGrant
+	}
+
+	// rotated refresh tokens are used only once, a reused one may have been stolen, revoke tokens refreshed from it too
+	if token.RevokedAt != nil {
+		if token.AuthorizationCodeID != 0 {
+			if err := server.revokeCodeTokens(context, token.AuthorizationCodeID, "refresh_token_reused"); err != nil {
+				return nil, err
+			}
+		}
+		return nil, ErrInvalidGrant
+	}
+
+	// refreshed tokens could narrow scopes, but can't add new ones
+	scopes := token.GetScopes()
+	if requested := consent.ParseScopes(context.FormValue("scope")); len(requested) > 0 {
+		for _, scope := range requested {
+			if !token.HasScope(scope) {
+				return nil, ErrInvalidScope
+			}
+		}
+		scopes = requested
+	}
+
+	// refresh tokens are rotated, old tokens are revoked
+	if result := tx.Model(&Token{}).Where("id = ? AND revoked_at IS NULL", token.ID).Update("revoked_at", now); result.Error != nil || result.RowsAffected != 1 {
+		return nil, ErrInvalidGrant
+	}
+
+	return server.issueToken(context, client, token.AuthorizationCodeID, token.UserID, scopes, "")
+}
+
+func (server Server) issueToken(context *auth.Context, client *Client, codeID uint, userID string, scopes []string, nonce string) (map[string]interface{}, error) {
+	var (
+		now                = context.Auth.Now()
+		accessTokenExpiry  = server.AccessTokenExpiry
+		refreshTokenExpiry = server.RefreshTokenExpiry
+	)
+
+	accessToken, err := context.Auth.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if client.AccessTokenExpiry != 0 {
+		accessTokenExpiry = client.AccessTokenExpiry
+	}
+
+	if client.RefreshTokenExpiry != 0 {
+		refreshTokenExpiry = client.RefreshTokenExpiry
+	}
+
+	token := Token{
+		HashedAccessToken:   hashToken(accessToken),
+		ClientID:            client.ClientID,
+		UserID:              userID,
+		Scopes:              strings.Join(scopes, " "),
+		AuthorizationCodeID: codeID,
+		ExpiresAt:           now.Add(accessTokenExpiry),
+	}
+
+	result := map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(accessTokenExpiry.Seconds()),
+		"scope":        token.Scopes,
+	}
+
+	// refresh tokens are only issued to clients allowed to use refresh token grant
+	if client.AllowGrantType(GrantTypeRefreshToken) {
+		refreshToken, err := context.Auth.GenerateToken()
+		if err != nil {
+			return nil, err
+		}
+		refreshAt := now.Add(refreshTokenExpiry)
+		token.HashedRefreshToken = hashToken(refreshToken)
+		token.RefreshExpiresAt = &refreshAt
+		result["refresh_token"] = refreshToken
+	}
+
+	if err := context.Auth.GetDB(context.Request).Create(&token).Error; err != nil {
+		return nil, err
+	}
+
+	if server.OpenIDEnabled() && token.HasScope(ScopeOpenID) {
+		idToken, err := server.idToken(context, client, userID, scopes, nonce, accessToken)
+		if err != nil {
+			return nil, err
+		}
+		result["id_token"] = idToken
+	}
+
+	context.Auth.Publish(EventTokenIssued, context, map[string]interface{}{"client_id": client.ClientID, "user_id": userID, "scopes": scopes})
+	return result, nil
+}
+
+// verifyCodeChallenge verify PKCE code verifier, requests without challenge are always valid
+func verifyCodeChallenge(challenge, method, verifier string) bool {
+	if challenge == "" {
+		return true
+	}
+
+	if method == "S256" {
+		sum := sha256.Sum256([]byte(verifier))
+		verifier = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	return verifier != "" && subtle.ConstantTimeCompare([]byte(challenge), []byte(verifier)) == 1
+}
+
+func toError(err error) *Error {
+	var oauthErr *Error
+	if errors.As(err, &oauthErr) {
+		return oauthErr
+	}
+	return &Error{Code: "server_error", Description: fmt.Sprint(err)}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	oauthErr := toError(err)
+	if oauthErr.Code == "server_error" {
+		status = http.StatusInternalServerError
+	}
+	writeJSON(w, status, map[string]string{"error": oauthErr.Code, "error_description": oauthErr.Description})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,258 @@
+package oauth_server_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/qor/auth"
+	"github.com/qor/auth/authtest"
+	"github.com/qor/auth/claims"
+	"github.com/qor/auth/oauth_server"
+	"github.com/qor/session/manager"
+)
+
+const redirectURI = "https://app.example.com/callback"
+
+type redirector struct{}
+
+func (redirector) Redirect(w http.ResponseWriter, req *http.Request, action string) {}
+
+type testServer struct {
+	t       *testing.T
+	Auth    *auth.Auth
+	Server  *oauth_server.Server
+	clock   *authtest.Clock
+	handler http.Handler
+	cookie  *http.Cookie
+}
+
+func setup(t *testing.T) *testServer {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := authtest.NewClock(time.Now())
+	Auth := auth.New(&auth.Config{DB: db, Redirector: redirector{}, Clock: clock.Now})
+	server := oauth_server.New(&oauth_server.Config{
+		Issuer:     "https://accounts.example.com/auth/oauth",
+		SigningKey: signingKey,
+		UserClaims: func(context *auth.Context, userID string, scopes []string) (map[string]interface{}, error) {
+			return map[string]interface{}{"sub": userID}, nil
+		},
+	})
+	Auth.RegisterProvider(server)
+	if err := auth.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testServer{
+		t:       t,
+		Auth:    Auth,
+		Server:  server,
+		clock:   clock,
+		handler: manager.SessionManager.Middleware(Auth.NewServeMux()),
+		cookie:  authtest.LoginAs(t, Auth, &claims.Claims{UserID: "1"}),
+	}
+}
+
+func (server *testServer) context() *auth.Context {
+	return &auth.Context{Auth: server.Auth, Request: httptest.NewRequest("POST", "/", nil)}
+}
+
+func (server *testServer) registerClient(client *oauth_server.Client) string {
+	client.RedirectURIs = redirectURI
+	secret, err := server.Server.RegisterClient(server.context(), client)
+	if err != nil {
+		server.t.Fatal(err)
+	}
+	return secret
+}
+
+// authorize request an authorization code, returns the code, or error code redirected to client
+func (server *testServer) authorize(params url.Values) (code string, errorCode string) {
+	params.Set("response_type", "code")
+	req := httptest.NewRequest("GET", "/auth/oauth/authorize?"+params.Encode(), nil)
+	req.AddCookie(server.cookie)
+	w := httptest.NewRecorder()
+	server.handler.ServeHTTP(w, req)
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || w.Code >= 400 {
+		return "", "status_" + http.StatusText(w.Code)
+	}
+	return location.Query().Get("code"), location.Query().Get("error")
+}
+
+// token post to token endpoint, returns status and response
+func (server *testServer) token(params url.Values) (int, map[string]interface{}) {
+	req := httptest.NewRequest("POST", "/auth/oauth/token", strings.NewReader(params.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	server.handler.ServeHTTP(w, req)
+
+	var result map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&result)
+	return w.Code, result
+}
+
+func (server *testServer) isActive(accessToken interface{}) bool {
+	value, _ := accessToken.(string)
+	_, err := server.Server.ValidateAccessToken(server.context(), value)
+	return err == nil
+}
+
+func challengeOf(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestPKCE(t *testing.T) {
+	server := setup(t)
+	client := &oauth_server.Client{Name: "SPA", Public: true}
+	server.registerClient(client)
+
+	if _, errorCode := server.authorize(url.Values{"client_id": {client.ClientID}, "redirect_uri": {redirectURI}}); errorCode != oauth_server.ErrPKCERequired.Code {
+		t.Errorf("expect public client without PKCE rejected, got %v", errorCode)
+	}
+
+	verifier := "a-long-random-code-verifier-of-the-client-0123456789"
+	code, _ := server.authorize(url.Values{"client_id": {client.ClientID}, "redirect_uri": {redirectURI}, "code_challenge": {challengeOf(verifier)}, "code_challenge_method": {"S256"}})
+	if code == "" {
+		t.Fatal("expect authorization code issued")
+	}
+
+	exchange := url.Values{"grant_type": {"authorization_code"}, "client_id": {client.ClientID}, "code": {code}, "redirect_uri": {redirectURI}}
+
+	exchange.Set("code_verifier", "another-verifier")
+	if status, result := server.token(exchange); status != http.StatusBadRequest || result["error"] != oauth_server.ErrInvalidGrant.Code {
+		t.Errorf("expect mismatched S256 code verifier rejected, got %v %v", status, result)
+	}
+
+	exchange.Set("code_verifier", verifier)
+	if status, result := server.token(exchange); status != http.StatusOK || result["access_token"] == nil {
+		t.Errorf("expect code exchanged with code verifier, got %v %v", status, result)
+	}
+}
+
+func TestRedirectURIMustMatchExactly(t *testing.T) {
+	server := setup(t)
+	client := &oauth_server.Client{Name: "Wiki"}
+	secret := server.registerClient(client)
+
+	for _, uri := range []string{redirectURI + "/", redirectURI + "?next=/", "https://app.example.com/callback/../evil", "https://APP.example.com/callback", "https://evil.example.com/callback"} {
+		if _, errorCode := server.authorize(url.Values{"client_id": {client.ClientID}, "redirect_uri": {uri}}); errorCode != "status_"+http.StatusText(http.StatusBadRequest) {
+			t.Errorf("expect unregistered redirect_uri %v rejected without redirecting, got %v", uri, errorCode)
+		}
+	}
+
+	code, _ := server.authorize(url.Values{"client_id": {client.ClientID}, "redirect_uri": {redirectURI}})
+	if code == "" {
+		t.Fatal("expect authorization code issued")
+	}
+
+	exchange := url.Values{"grant_type": {"authorization_code"}, "client_id": {client.ClientID}, "client_secret": {secret}, "code": {code}, "redirect_uri": {redirectURI + "/"}}
+	if status, _ := server.token(exchange); status != http.StatusBadRequest {
+		t.Errorf("expect code exchanged with another redirect_uri rejected, got %v", status)
+	}
+}
+
+func TestRedirectURISchemes(t *testing.T) {
+	server := setup(t)
+
+	for uri, valid := range map[string]bool{
+		"https://app.example.com/callback": true,
+		"http://127.0.0.1:8080/callback":   true,
+		"http://[::1]/callback":            true,
+		"http://localhost:3000/callback":   true,
+		"http://app.example.com/callback":  false,
+		"http://127.0.0.1.evil.com/":       false,
+		"javascript:alert(1)":              false,
+		"com.example.app:/callback":        false,
+		"https://app.example.com/#token":   false,
+		"/callback":                        false,
+	} {
+		if err := server.Server.ValidateClient(&oauth_server.Client{RedirectURIs: uri}); (err == nil) != valid {
+			t.Errorf("redirect_uri %v: expect valid %v, got %v", uri, valid, err)
+		}
+	}
+}
+
+func TestReplayedCodeRevokesTokens(t *testing.T) {
+	server := setup(t)
+	client := &oauth_server.Client{Name: "Wiki"}
+	secret := server.registerClient(client)
+
+	code, _ := server.authorize(url.Values{"client_id": {client.ClientID}, "redirect_uri": {redirectURI}})
+	exchange := url.Values{"grant_type": {"authorization_code"}, "client_id": {client.ClientID}, "client_secret": {secret}, "code": {code}, "redirect_uri": {redirectURI}}
+
+	status, issued := server.token(exchange)
+	if status != http.StatusOK {
+		t.Fatalf("expect code exchanged, got %v %v", status, issued)
+	}
+
+	status, refreshed := server.token(url.Values{"grant_type": {"refresh_token"}, "client_id": {client.ClientID}, "client_secret": {secret}, "refresh_token": {issued["refresh_token"].(string)}})
+	if status != http.StatusOK || !server.isActive(refreshed["access_token"]) {
+		t.Fatalf("expect token refreshed, got %v %v", status, refreshed)
+	}
+
+	if status, result := server.token(exchange); status != http.StatusBadRequest || result["error"] != oauth_server.ErrInvalidGrant.Code {
+		t.Errorf("expect replayed code rejected, got %v %v", status, result)
+	}
+
+	if server.isActive(issued["access_token"]) || server.isActive(refreshed["access_token"]) {
+		t.Errorf("expect tokens issued from replayed code revoked")
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	server := setup(t)
+	client := &oauth_server.Client{Name: "Wiki"}
+	secret := server.registerClient(client)
+
+	code, _ := server.authorize(url.Values{"client_id": {client.ClientID}, "redirect_uri": {redirectURI}})
+	_, issued := server.token(url.Values{"grant_type": {"authorization_code"}, "client_id": {client.ClientID}, "client_secret": {secret}, "code": {code}, "redirect_uri": {redirectURI}})
+
+	refresh := url.Values{"grant_type": {"refresh_token"}, "client_id": {client.ClientID}, "client_secret": {secret}, "refresh_token": {issued["refresh_token"].(string)}}
+	status, refreshed := server.token(refresh)
+	if status != http.StatusOK || refreshed["refresh_token"] == nil || refreshed["refresh_token"] == issued["refresh_token"] {
+		t.Fatalf("expect refresh token rotated, got %v %v", status, refreshed)
+	}
+
+	if server.isActive(issued["access_token"]) {
+		t.Errorf("expect access token of rotated refresh token revoked")
+	}
+
+	// reusing the rotated refresh token revokes the new one too
+	if status, result := server.token(refresh); status != http.StatusBadRequest || result["error"] != oauth_server.ErrInvalidGrant.Code {
+		t.Errorf("expect rotated refresh token rejected, got %v %v", status, result)
+	}
+
+	if server.isActive(refreshed["access_token"]) {
+		t.Errorf("expect tokens refreshed from reused refresh token revoked")
+	}
+
+	refresh.Set("refresh_token", refreshed["refresh_token"].(string))
+	if status, _ := server.token(refresh); status != http.StatusBadRequest {
+		t.Errorf("expect revoked refresh token rejected, got %v", status)
+	}
+}
@@ -0,0 +1,196 @@
+package oauth_server
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/qor/auth"
+	"github.com/qor/auth/claims"
+	"github.com/qor/qor/utils"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// ScopeOpenID scope requesting ID token, ID tokens are issued only if OpenID Connect is enabled with Config.Issuer, Config.SigningKey
+const ScopeOpenID = "openid"
+
+// OpenIDEnabled check OpenID Connect provider mode is enabled
+func (server Server) OpenIDEnabled() bool {
+	return server.Issuer != "" && server.SigningKey != nil
+}
+
+// UserClaims get standard claims of user for granted scopes, `profile`: name, given_name, family_name, picture, `email`: email, `phone`: phone_number,
+// values are read from user model's fields `Name`, `FirstName`, `LastName`, `Image`, `Email`, `Phone`
+func UserClaims(context *auth.Context, userID string, scopes []string) (map[string]interface{}, error) {
+	result := map[string]interface{}{"sub": userID}
+
+	user, err := context.Auth.UserStorer.Get(&claims.Claims{UserID: userID}, context)
+	if err != nil {
+		return nil, err
+	}
+
+	value := utils.Indirect(reflect.ValueOf(user))
+	set := func(claim, field string) {
+		if value.Kind() != reflect.Struct {
+			return
+		}
+		if f := value.FieldByName(field); f.IsValid() && f.Kind() == reflect.String && f.String() != "" {
+			result[claim] = f.String()
+		}
+	}
+
+	for _, scope := range scopes {
+		switch scope {
+		case "profile":
+			set("name", "Name")
+			set("given_name", "FirstName")
+			set("family_name", "LastName")
+			set("picture", "Image")
+		case "email":
+			set("email", "Email")
+		case "phone":
+			set("phone_number", "Phone")
+		}
+	}
+	return result, nil
+}
+
+// signingAlgorithm get signing algorithm of SigningKey, RS256 for RSA keys, ES256, ES384, ES512 for ECDSA keys
+func (server Server) signingAlgorithm() jose.SignatureAlgorithm {
+	if key, ok := server.SigningKey.(*ecdsa.PrivateKey); ok {
+		switch key.Curve.Params().BitSize {
+		case 384:
+			return jose.ES384
+		case 521:
+			return jose.ES512
+		}
+		return jose.ES256
+	}
+	return jose.RS256
+}
+
+func (server Server) publicKey() interface{} {
+	switch key := server.SigningKey.(type) {
+	case *rsa.PrivateKey:
+		return &key.PublicKey
+	case *ecdsa.PrivateKey:
+		return &key.PublicKey
+	}
+	return nil
+}
+
+// idToken issue ID token for user
+func (server Server) idToken(context *auth.Context, client *Client, userID string, scopes []string, nonce string, accessToken string) (string, error) {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: server.signingAlgorithm(), Key: server.SigningKey},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", server.KeyID),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	userClaims, err := server.Config.UserClaims(context, userID, scopes)
+	if err != nil {
+		return "", err
+	}
+
+	now := context.Auth.Now()
+	userClaims["iss"] = server.Issuer
+	userClaims["sub"] = userID
+	userClaims["aud"] = client.ClientID
+	userClaims["iat"] = jwt.NewNumericDate(now)
+	userClaims["exp"] = jwt.NewNumericDate(now.Add(server.IDTokenExpiry))
+	userClaims["at_hash"] = server.tokenHash(accessToken)
+	if nonce != "" {
+		userClaims["nonce"] = nonce
+	}
+
+	return jwt.Signed(signer).Claims(userClaims).CompactSerialize()
+}
+
+// tokenHash `at_hash` of access token, left half of the token's hash, hashed with the hash algorithm of signing algorithm
+func (server Server) tokenHash(token string) string {
+	var h hash.Hash
+	switch server.signingAlgorithm() {
+	case jose.ES384:
+		h = sha512.New384()
+	case jose.ES512:
+		h = sha512.New()
+	default:
+		h = sha256.New()
+	}
+
+	h.Write([]byte(token))
+	sum := h.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+// discovery serve OpenID provider metadata at `{Issuer}/.well-known/openid-configuration`
+func (server Server) discovery(context *auth.Context) {
+	endpoint := func(name string) string {
+		return strings.TrimSuffix(server.Issuer, "/") + "/" + name
+	}
+
+	scopes := []string{ScopeOpenID}
+	for _, scope := range server.Scopes {
+		if scope.Name != ScopeOpenID {
+			scopes = append(scopes, scope.Name)
+		}
+	}
+
+	writeJSON(context.Writer, http.StatusOK, map[string]interface{}{
+		"issuer":                                server.Issuer,
+		"authorization_endpoint":                endpoint("authorize"),
+		"token_endpoint":                        endpoint("token"),
+		"userinfo_endpoint":                     endpoint("userinfo"),
+		"introspection_endpoint":                endpoint("introspect"),
+		"revocation_endpoint":                   endpoint("revoke"),
+		"jwks_uri":                              endpoint("jwks"),
+		"scopes_supported":                      scopes,
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 GrantTypes,
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{string(server.signingAlgorithm())},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256", "plain"},
+		"claims_supported":                      []string{"sub", "iss", "aud", "exp", "iat", "nonce", "name", "given_name", "family_name", "picture", "email", "phone_number"},
+	})
+}
+
+// jwks serve public key of SigningKey
+func (server Server) jwks(context *auth.Context) {
+	writeJSON(context.Writer, http.StatusOK, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{
+		Key:       server.publicKey(),
+		KeyID:     server.KeyID,
+		Algorithm: string(server.signingAlgorithm()),
+		Use:       "sig",
+	}}})
+}
+
+// userinfo serve claims of access token's user, the token must be granted scope `openid`
+func (server Server) userinfo(context *auth.Context) {
+	token, err := server.ValidateAccessToken(context, BearerToken(context.Request))
+	if err == nil && !token.HasScope(ScopeOpenID) {
+		err = &Error{Code: "insufficient_scope", Description: "insufficient scope"}
+	}
+
+	if err != nil {
+		context.Writer.Header().Set("WWW-Authenticate", `Bearer error="`+toError(err).Code+`"`)
+		writeError(context.Writer, http.StatusUnauthorized, err)
+		return
+	}
+
+	result, err := server.Config.UserClaims(context, token.UserID, token.GetScopes())
+	if err != nil {
+		writeError(context.Writer, http.StatusUnauthorized, ErrInvalidToken)
+		return
+	}
+	writeJSON(context.Writer, http.StatusOK, result)
+}
@@ -0,0 +1,24 @@
+package organization
+
+import "errors"
+
+var (
+	// ErrOrganizationNotFound organization not found error
+	ErrOrganizationNotFound = errors.New("organization not found")
+	// ErrNotMember user is not a member of the organization error
+	ErrNotMember = errors.New("not a member of the organization")
+	// ErrPermissionDenied user doesn't have permission to manage the organization error
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrInvalidInvitation invitation not found or already responded error
+	ErrInvalidInvitation = errors.New("invalid invitation")
+	// ErrInvitationExpired invitation expired error
+	ErrInvitationExpired = errors.New("invitation expired")
+	// ErrLastOwner organization must have at least one owner error
+	ErrLastOwner = errors.New("organization must have at least one owner")
+	// ErrAlreadyMember user is already a member of the organization error, use ChangeRole to change its role
+	ErrAlreadyMember = errors.New("already a member of the organization")
+	// ErrInvalidRole role isn't one of provider's Roles error
+	ErrInvalidRole = errors.New("invalid organization role")
+	// ErrInvitationEmailMismatch current user's email isn't the invited email error
+	ErrInvitationEmailMismatch = errors.New("invitation was sent to another email")
+)
@@ -0,0 +1,238 @@
+package organization
+
+import (
+	"html/template"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"github.com/qor/auth"
+	"github.com/qor/mailer"
+	"github.com/qor/qor/utils"
+)
+
+const (
+	// EventInvitationCreated published after invitation created
+	EventInvitationCreated = "organization.invitation.created"
+	// EventInvitationAccepted published after invitation accepted
+	EventInvitationAccepted = "organization.invitation.accepted"
+	// EventInvitationDeclined published after invitation declined
+	EventInvitationDeclined = "organization.invitation.declined"
+	// EventInvitationRevoked published after invitation revoked
+	EventInvitationRevoked = "organization.invitation.revoked"
+	// EventInvitationExpired published when responding an expired invitation
+	EventInvitationExpired = "organization.invitation.expired"
+	// EventMemberAdded published after member added to organization
+	EventMemberAdded = "organization.member.added"
+	// EventMemberRoleChanged published after member's role changed
+	EventMemberRoleChanged = "organization.member.role_changed"
+	// EventMemberRemoved published after member removed from organization
+	EventMemberRemoved = "organization.member.removed"
+)
+
+// InvitationMailSubject invitation mail's subject, it is translated to request's locale
+var InvitationMailSubject = "auth.mailers.organization.invitation.subject"
+
+// DefaultInvitationMailer default invitation mailer
+var DefaultInvitationMailer = func(invitation *Invitation, context *auth.Context) error {
+	return context.Auth.Mailer.Send(
+		mailer.Email{
+			TO:      []mail.Address{{Address: invitation.Email}},
+			Subject: context.T(InvitationMailSubject),
+		}, context.MailTemplate("auth/organization/invitation", context, template.FuncMap{
+			"invitation": func() *Invitation {
+				return invitation
+			},
+			"invitation_url": func() string {
+				invitationURL := utils.GetAbsURL(context.Request)
+				invitationURL.Path = context.Auth.AuthURL("organization/invitation")
+				invitationURL.RawQuery = url.Values{"token": []string{invitation.Token}}.Encode()
+				return invitationURL.String()
+			},
+		}),
+	)
+}
+
+// Invite invite email to current organization, current user need to be organization's owner or admin
+func (provider Provider) Invite(context *auth.Context, email string, role string) (*Invitation, error) {
+	membership, err := provider.managerMembership(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if role == "" {
+		role = provider.Config.DefaultRole
+	}
+
+	if !provider.IsValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+
+	if role == RoleOwner && !membership.HasRole(RoleOwner) {
+		return nil, ErrPermissionDenied
+	}
+
+	token, err := context.Auth.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := context.Auth.Now().Add(provider.Config.InvitationExpiry)
+
+	invitation := &Invitation{
+		OrganizationID: membership.OrganizationID,
+		Email:          email,
+		Role:           role,
+		Token:          token,
+		HashedToken:    hashToken(token),
+		State:          InvitationPending,
+		InvitedBy:      membership.UserID,
+		ExpiresAt:      &expiresAt,
+	}
+
+	if err := context.Auth.GetDB(context.Request).Create(invitation).Error; err != nil {
+		return nil, err
+	}
+	invitation.Organization = membership.Organization
+
+	if err := provider.Config.InvitationMailer(invitation, context); err != nil {
+		return invitation, err
+	}
+
+	context.Auth.Publish(EventInvitationCreated, context, invitationEventData(invitation))
+	return invitation, nil
+}
+
+// GetInvitation get pending invitation with token, it is found with token's hash, so lookups don't compare secrets
+func (provider Provider) GetInvitation(context *auth.Context, token string) (*Invitation, error) {
+	var invitation Invitation
+
+	if token == "" {
+		return nil, ErrInvalidInvitation
+	}
+
+	if context.Auth.GetDB(context.Request).Preload("Organization").Where("hashed_token = ? AND state = ?", hashToken(token), InvitationPending).First(&invitation).RecordNotFound() {
+		return nil, ErrInvalidInvitation
+	}
+	invitation.Token = token
+
+	if invitation.IsExpired(context.Auth.Now()) {
+		context.Auth.Publish(EventInvitationExpired, context, invitationEventData(&invitation))
+		return nil, ErrInvitationExpired
+	}
+
+	return &invitation, nil
+}
+
+// GetInvitations get pending invitations of current organization
+func (provider Provider) GetInvitations(context *auth.Context) (invitations []Invitation, err error) {
+	membership, err := provider.managerMembership(context)
+	if err != nil {
+		return nil, err
+	}
+
+	err = context.Auth.GetDB(context.Request).Where("organization_id = ? AND state = ?", membership.OrganizationID, InvitationPending).Find(&invitations).Error
+	return
+}
+
+// AcceptInvitation current user accept invitation, will add it to the organization, current user's email need to be the invited email,
+// invitation is marked accepted and membership is added in a transaction, so an invitation could only be accepted once
+func (provider Provider) AcceptInvitation(context *auth.Context, token string) (*Membership, error) {
+	currentClaims, err := getClaims(context)
+	if err != nil || currentClaims.UserID == "" {
+		return nil, auth.ErrUnauthorized
+	}
+
+	invitation, err := provider.GetInvitation(context, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if email := context.Auth.GetEmail(context, currentClaims); email == "" || !strings.EqualFold(strings.TrimSpace(email), strings.TrimSpace(invitation.Email)) {
+		return nil, ErrInvitationEmailMismatch
+	}
+
+	var membership *Membership
+	if err := context.Auth.Transaction(context, func(context *auth.Context) (err error) {
+		if err = provider.respondInvitation(context, invitation, InvitationAccepted); err == nil {
+			membership, err = provider.AddMember(context, invitation.OrganizationID, currentClaims.UserID, invitation.Role)
+		}
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	context.Auth.Publish(EventInvitationAccepted, context, invitationEventData(invitation))
+	return membership, nil
+}
+
+// DeclineInvitation decline invitation
+func (provider Provider) DeclineInvitation(context *auth.Context, token string) error {
+	invitation, err := provider.GetInvitation(context, token)
+	if err != nil {
+		return err
+	}
+
+	if err := provider.respondInvitation(context, invitation, InvitationDeclined); err != nil {
+		return err
+	}
+	context.Auth.Publish(EventInvitationDeclined, context, invitationEventData(invitation))
+	return nil
+}
+
+// RevokeInvitation revoke pending invitation of current organization, current user need to be organization's owner or admin
+func (provider Provider) RevokeInvitation(context *auth.Context, invitationID string) error {
+	var invitation Invitation
+
+	membership, err := provider.managerMembership(context)
+	if err != nil {
+		return err
+	}
+
+	if context.Auth.GetDB(context.Request).Where("id = ? AND organization_id = ? AND state = ?", invitationID, membership.OrganizationID, InvitationPending).First(&invitation).RecordNotFound() {
+		return ErrInvalidInvitation
+	}
+
+	if err := provider.respondInvitation(context, &invitation, InvitationRevoked); err != nil {
+		return err
+	}
+	context.Auth.Publish(EventInvitationRevoked, context, invitationEventData(&invitation))
+	return nil
+}
+
+// respondInvitation change pending invitation's state, returns ErrInvalidInvitation if it has been responded, like accepted by a concurrent request
+func (provider Provider) respondInvitation(context *auth.Context, invitation *Invitation, state string) error {
+	now := context.Auth.Now()
+	result := context.Auth.GetDB(context.Request).Model(&Invitation{}).Where("id = ? AND state = ?", invitation.ID, InvitationPending).Updates(map[string]interface{}{"state": state, "responded_at": now})
+	if result.Error != nil {
+		return result.Error
+	} else if result.RowsAffected == 0 {
+		return ErrInvalidInvitation
+	}
+
+	invitation.State = state
+	invitation.RespondedAt = &now
+	return nil
+}
+
+// managerMembership get current user's membership of current organization, which need to be owner or admin
+func (provider Provider) managerMembership(context *auth.Context) (*Membership, error) {
+	membership, err := provider.CurrentMembership(context)
+	if err != nil {
+		return nil, err
+	}
+
+	if !membership.HasRole(RoleOwner, RoleAdmin) {
+		return nil, ErrPermissionDenied
+	}
+	return membership, nil
+}
+
+func invitationEventData(invitation *Invitation) map[string]interface{} {
+	return map[string]interface{}{
+		"organization_id": invitation.OrganizationID,
+		"invitation_id":   invitation.ID,
+		"email":           invitation.Email,
+		"role":            invitation.Role,
+	}
+}
@@ -0,0 +1,126 @@
+package organization
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/qor/auth"
+)
+
+func init() {
+	auth.RegisterTables("organizations", "memberships", "invitations")
+	auth.RegisterMigration(auth.Migration{ID: "organization/001_create_organizations", Migrate: func(db *gorm.DB) error {
+		return db.AutoMigrate(&Organization{}, &Membership{}, &Invitation{}).Error
+	}})
+	auth.RegisterMigration(auth.Migration{ID: "organization/002_hash_invitation_tokens", Migrate: migrateHashedTokens})
+}
+
+const (
+	// RoleOwner owner of an organization, could manage everything of it
+	RoleOwner = "owner"
+	// RoleAdmin admin of an organization, could manage its members
+	RoleAdmin = "admin"
+	// RoleMember normal member of an organization
+	RoleMember = "member"
+)
+
+// Organization organization model, also used as team
+type Organization struct {
+	gorm.Model
+	Name string
+	Slug string `gorm:"unique_index"`
+}
+
+// GetID get organization's ID as string, which is used in claims
+func (organization Organization) GetID() string {
+	return fmt.Sprint(organization.ID)
+}
+
+// Membership user's membership of an organization
+type Membership struct {
+	gorm.Model
+	OrganizationID uint   `gorm:"index"`
+	UserID         string `gorm:"index"`
+	Role           string
+	Organization   Organization
+}
+
+// HasRole check membership has any of the roles
+func (membership Membership) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if membership.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// InvitationPending invitation is waiting for response
+	InvitationPending = "pending"
+	// InvitationAccepted invitation has been accepted
+	InvitationAccepted = "accepted"
+	// InvitationDeclined invitation has been declined
+	InvitationDeclined = "declined"
+	// InvitationRevoked invitation has been revoked by organization's admin
+	InvitationRevoked = "revoked"
+)
+
+// Invitation invitation to join an organization, sent by email, only SHA-256 hash of its token is saved, Token is set on invitations just created or found with token
+type Invitation struct {
+	gorm.Model
+	OrganizationID uint `gorm:"index"`
+	Email          string
+	Role           string
+	Token          string `gorm:"-"`
+	HashedToken    string `gorm:"unique_index" json:"-"`
+	State          string
+	InvitedBy      string
+	ExpiresAt      *time.Time
+	RespondedAt    *time.Time
+	Organization   Organization
+}
+
+// IsExpired check invitation is expired at now, like `invitation.IsExpired(Auth.Now())`
+func (invitation Invitation) IsExpired(now time.Time) bool {
+	return invitation.ExpiresAt != nil && invitation.ExpiresAt.Before(now)
+}
+
+// migrateHashedTokens replace saved invitation tokens with their hashes, the unique index is created after existing rows are hashed
+func migrateHashedTokens(db *gorm.DB) error {
+	var (
+		table = db.NewScope(&Invitation{}).TableName()
+		rows  []struct {
+			ID    uint
+			Token string
+		}
+	)
+
+	if err := db.Table(table).AutoMigrate(&struct{ HashedToken string }{}).Error; err != nil {
+		return err
+	}
+
+	// tables created before have plain tokens with unique index, sqlite's HasColumn would match `hashed_token`
+	if db.Dialect().HasIndex(table, "uix_"+table+"_token") {
+		if err := db.Table(table).Select("id, token").Where("token IS NOT NULL AND token <> ''").Scan(&rows).Error; err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			if err := db.Table(table).Where("id = ?", row.ID).UpdateColumns(map[string]interface{}{"hashed_token": hashToken(row.Token), "token": gorm.Expr("NULL")}).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return db.AutoMigrate(&Invitation{}).Error
+}
+
+// hashToken hash invitation token, use it to find invitation with `hashed_token`
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,463 @@
+package organization
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/qor/auth"
+	"github.com/qor/auth/claims"
+	"github.com/qor/responder"
+)
+
+// Config organization provider config
+type Config struct {
+	// DefaultRole role used when add a member without role, default value is `member`
+	DefaultRole string
+	// Roles roles could be granted to members, default value is `owner`, `admin`, `member`
+	Roles []string
+	// InvitationExpiry how long an invitation is valid, default value is 7 days
+	InvitationExpiry time.Duration
+	// InvitationMailer send invitation email, default value is DefaultInvitationMailer
+	InvitationMailer func(invitation *Invitation, context *auth.Context) error
+}
+
+// New initialize organization provider
+func New(config *Config) *Provider {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if config.DefaultRole == "" {
+		config.DefaultRole = RoleMember
+	}
+
+	if len(config.Roles) == 0 {
+		config.Roles = []string{RoleOwner, RoleAdmin, RoleMember}
+	}
+
+	if config.InvitationExpiry == 0 {
+		config.InvitationExpiry = 7 * 24 * time.Hour
+	}
+
+	if config.InvitationMailer == nil {
+		config.InvitationMailer = DefaultInvitationMailer
+	}
+
+	return &Provider{Config: config}
+}
+
+// Provider organization provider, mount organization endpoints like `{Auth Prefix}/organization/switch` into Auth
+type Provider struct {
+	*Config
+	Auth *auth.Auth
+}
+
+// GetName return provider name
+func (Provider) GetName() string {
+	return "organization"
+}
+
+// ConfigAuth config auth
+func (provider *Provider) ConfigAuth(auth *auth.Auth) {
+	provider.Auth = auth
+}
+
+// Login organization provider doesn't support login
+func (provider Provider) Login(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Logout organization provider doesn't support logout
+func (provider Provider) Logout(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Register organization provider doesn't support register
+func (provider Provider) Register(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Deregister organization provider doesn't support deregister
+func (provider Provider) Deregister(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Callback organization provider doesn't support callback
+func (provider Provider) Callback(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// ServeHTTP serve organization endpoints
+//
+//	POST {Auth Prefix}/organization/switch             switch current organization with form value `organization_id`
+//	GET  {Auth Prefix}/organization/list               list organizations of current user
+//	GET  {Auth Prefix}/organization/current            get current organization membership
+//	POST {Auth Prefix}/organization/invite             invite `email` with `role` to current organization
+//	GET  {Auth Prefix}/organization/invitations        list pending invitations of current organization
+//	GET  {Auth Prefix}/organization/invitation         show invitation with `token`
+//	POST {Auth Prefix}/organization/accept             accept invitation with `token`
+//	POST {Auth Prefix}/organization/decline            decline invitation with `token`
+//	POST {Auth Prefix}/organization/revoke_invitation  revoke invitation with `invitation_id`
+//	POST {Auth Prefix}/organization/change_role        change role of member `user_id` to `role`
+//	POST {Auth Prefix}/organization/remove_member      remove member `user_id` from current organization
+func (provider Provider) ServeHTTP(context *auth.Context) {
+	var (
+		req     = context.Request
+		w       = context.Writer
+		reqPath = strings.TrimPrefix(req.URL.Path, context.Auth.URLPrefix)
+		paths   = strings.Split(reqPath, "/")
+	)
+
+	if len(paths) < 2 {
+		http.NotFound(w, req)
+		return
+	}
+
+	req.ParseForm()
+
+	switch paths[1] {
+	case "list", "current", "invitations", "invitation":
+	default:
+		if req.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+	}
+
+	switch paths[1] {
+	case "switch":
+		membership, err := provider.Switch(context, context.FormValue("organization_id"))
+		respond(context, "switch_organization", membership, err)
+	case "list":
+		currentClaims, err := getClaims(context)
+		if err != nil {
+			respondError(context, auth.ErrUnauthorized)
+			return
+		}
+
+		memberships, err := provider.GetMemberships(context, currentClaims.UserID)
+		respondJSON(context, memberships, err)
+	case "current":
+		membership, err := provider.CurrentMembership(context)
+		respondJSON(context, membership, err)
+	case "invite":
+		invitation, err := provider.Invite(context, context.FormValue("email"), context.FormValue("role"))
+		respond(context, "invite_member", invitation, err)
+	case "invitations":
+		invitations, err := provider.GetInvitations(context)
+		respondJSON(context, invitations, err)
+	case "invitation":
+		invitation, err := provider.GetInvitation(context, context.FormValue("token"))
+		if err != nil {
+			respondError(context, err)
+			return
+		}
+
+		responder.With("html", func() {
+			context.Execute("auth/organization/invitation", template.FuncMap{
+				"invitation": func() *Invitation { return invitation },
+			})
+		}).With([]string{"json"}, func() {
+			writeJSON(w, invitation)
+		}).Respond(req)
+	case "accept":
+		membership, err := provider.AcceptInvitation(context, context.FormValue("token"))
+		respond(context, "accept_invitation", membership, err)
+	case "decline":
+		err := provider.DeclineInvitation(context, context.FormValue("token"))
+		respond(context, "decline_invitation", nil, err)
+	case "revoke_invitation":
+		err := provider.RevokeInvitation(context, context.FormValue("invitation_id"))
+		respond(context, "revoke_invitation", nil, err)
+	case "change_role":
+		membership, err := provider.ChangeRole(context, context.FormValue("user_id"), context.FormValue("role"))
+		respond(context, "change_member_role", membership, err)
+	case "remove_member":
+		err := provider.RemoveMember(context, context.FormValue("user_id"))
+		respond(context, "remove_member", nil, err)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// Create create organization, and add the owner as its member with role `owner`
+func (provider Provider) Create(context *auth.Context, organization *Organization, ownerID string) error {
+	tx := context.Auth.GetDB(context.Request).Begin()
+
+	if err := tx.Create(organization).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	membership := Membership{OrganizationID: organization.ID, UserID: ownerID, Role: RoleOwner}
+	if err := tx.Create(&membership).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// AddMember add user to organization, will use DefaultRole if role is blank, returns ErrAlreadyMember if user is a member already, its role isn't changed
+func (provider Provider) AddMember(context *auth.Context, organizationID uint, userID string, role string) (*Membership, error) {
+	var (
+		tx         = context.Auth.GetDB(context.Request)
+		membership Membership
+	)
+
+	if role == "" {
+		role = provider.Config.DefaultRole
+	}
+
+	if !provider.IsValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+
+	if tx.First(&Organization{}, organizationID).RecordNotFound() {
+		return nil, ErrOrganizationNotFound
+	}
+
+	if !tx.Where("organization_id = ? AND user_id = ?", organizationID, userID).First(&Membership{}).RecordNotFound() {
+		return nil, ErrAlreadyMember
+	}
+
+	membership = Membership{OrganizationID: organizationID, UserID: userID, Role: role}
+	if err := tx.Create(&membership).Error; err != nil {
+		return nil, err
+	}
+
+	context.Auth.Publish(EventMemberAdded, context, membershipEventData(&membership))
+	return &membership, nil
+}
+
+// IsValidRole check role is one of Config's Roles
+func (provider Provider) IsValidRole(role string) bool {
+	for _, r := range provider.Config.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangeRole change member's role of current organization, current user need to be organization's owner or admin, only owner could grant or revoke role `owner`
+func (provider Provider) ChangeRole(context *auth.Context, userID string, role string) (*Membership, error) {
+	if !provider.IsValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+
+	manager, err := provider.managerMembership(context)
+	if err != nil {
+		return nil, err
+	}
+
+	membership, err := provider.GetMembership(context, manager.Organization.GetID(), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if (role == RoleOwner || membership.HasRole(RoleOwner)) && !manager.HasRole(RoleOwner) {
+		return nil, ErrPermissionDenied
+	}
+
+	oldRole := membership.Role
+	if err := context.Auth.Transaction(context, func(context *auth.Context) error {
+		tx := context.Auth.GetDB(context.Request)
+		if role != RoleOwner {
+			if err := checkLastOwner(tx, membership); err != nil {
+				return err
+			}
+		}
+		return tx.Model(membership).Update("role", role).Error
+	}); err != nil {
+		return nil, err
+	}
+
+	data := membershipEventData(membership)
+	data["previous_role"] = oldRole
+	context.Auth.Publish(EventMemberRoleChanged, context, data)
+	return membership, nil
+}
+
+// RemoveMember remove member from current organization, current user need to be organization's owner or admin, or the member itself
+func (provider Provider) RemoveMember(context *auth.Context, userID string) error {
+	currentMembership, err := provider.CurrentMembership(context)
+	if err != nil {
+		return err
+	}
+
+	membership, err := provider.GetMembership(context, currentMembership.Organization.GetID(), userID)
+	if err != nil {
+		return err
+	}
+
+	if membership.UserID != currentMembership.UserID {
+		if !currentMembership.HasRole(RoleOwner, RoleAdmin) || (membership.HasRole(RoleOwner) && !currentMembership.HasRole(RoleOwner)) {
+			return ErrPermissionDenied
+		}
+	}
+
+	if err := context.Auth.Transaction(context, func(context *auth.Context) error {
+		tx := context.Auth.GetDB(context.Request)
+		if err := checkLastOwner(tx, membership); err != nil {
+			return err
+		}
+		return tx.Delete(membership).Error
+	}); err != nil {
+		return err
+	}
+
+	context.Auth.Publish(EventMemberRemoved, context, membershipEventData(membership))
+	return nil
+}
+
+// checkLastOwner returns ErrLastOwner if membership is organization's only owner, owners are locked with `SELECT ... FOR UPDATE` in the transaction,
+// so concurrent demotions or removals of owners are serialized, and can't leave the organization without owners. sqlite doesn't support it, but it locks the whole database for writes
+func checkLastOwner(tx *gorm.DB, membership *Membership) error {
+	var (
+		query  = tx.Where("organization_id = ? AND role = ?", membership.OrganizationID, RoleOwner)
+		owners []Membership
+	)
+
+	if tx.Dialect().GetName() != "sqlite3" {
+		query = query.Set("gorm:query_option", "FOR UPDATE")
+	}
+
+	if err := query.Find(&owners).Error; err != nil {
+		return err
+	}
+
+	for _, owner := range owners {
+		if owner.ID == membership.ID && len(owners) <= 1 {
+			return ErrLastOwner
+		}
+	}
+	return nil
+}
+
+func membershipEventData(membership *Membership) map[string]interface{} {
+	return map[string]interface{}{
+		"organization_id": membership.OrganizationID,
+		"user_id":         membership.UserID,
+		"role":            membership.Role,
+	}
+}
+
+// GetMembership get user's membership of an organization
+func (provider Provider) GetMembership(context *auth.Context, organizationID string, userID string) (*Membership, error) {
+	var (
+		tx         = context.Auth.GetDB(context.Request)
+		membership Membership
+	)
+
+	if organizationID == "" || userID == "" {
+		return nil, ErrNotMember
+	}
+
+	if tx.Preload("Organization").Where("organization_id = ? AND user_id = ?", organizationID, userID).First(&membership).RecordNotFound() {
+		return nil, ErrNotMember
+	}
+	return &membership, nil
+}
+
+// MemberRole get user's current role of an organization from database, could be used as authority.TenantHook's membership to recheck claims
+func (provider Provider) MemberRole(req *http.Request, organizationID string, userID string) (string, error) {
+	membership, err := provider.GetMembership(&auth.Context{Auth: provider.Auth, Request: req}, organizationID, userID)
+	if err != nil {
+		return "", err
+	}
+	return membership.Role, nil
+}
+
+// GetMemberships get all memberships of an user
+func (provider Provider) GetMemberships(context *auth.Context, userID string) (memberships []Membership, err error) {
+	tx := context.Auth.GetDB(context.Request)
+	err = tx.Preload("Organization").Where("user_id = ?", userID).Find(&memberships).Error
+	return
+}
+
+// Switch switch current user's organization, save organization id, role into session's claims
+func (provider Provider) Switch(context *auth.Context, organizationID string) (*Membership, error) {
+	currentClaims, err := getClaims(context)
+	if err != nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	membership, err := provider.GetMembership(context, organizationID, currentClaims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	currentClaims.OrganizationID = membership.Organization.GetID()
+	currentClaims.OrganizationRole = membership.Role
+
+	if err := context.Auth.SessionStorer.Update(context.Writer, context.Request, currentClaims); err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+// CurrentMembership get current user's membership of current organization saved in claims
+func (provider Provider) CurrentMembership(context *auth.Context) (*Membership, error) {
+	currentClaims, err := getClaims(context)
+	if err != nil {
+		return nil, auth.ErrUnauthorized
+	}
+
+	return provider.GetMembership(context, currentClaims.OrganizationID, currentClaims.UserID)
+}
+
+func getClaims(context *auth.Context) (*claims.Claims, error) {
+	if context.Claims != nil {
+		return context.Claims, nil
+	}
+	return context.Auth.SessionStorer.Get(context.Request)
+}
+
+// respond redirect with action for html request, write result for json request
+func respond(context *auth.Context, action string, result interface{}, err error) {
+	if err != nil {
+		respondError(context, err)
+		return
+	}
+
+	responder.With("html", func() {
+		context.Auth.Redirector.Redirect(context.Writer, context.Request, action)
+	}).With([]string{"json"}, func() {
+		writeJSON(context.Writer, result)
+	}).Respond(context.Request)
+}
+
+func respondJSON(context *auth.Context, result interface{}, err error) {
+	if err != nil {
+		respondError(context, err)
+		return
+	}
+	writeJSON(context.Writer, result)
+}
+
+func respondError(context *auth.Context, err error) {
+	status := http.StatusUnprocessableEntity
+	switch err {
+	case auth.ErrUnauthorized:
+		status = http.StatusUnauthorized
+	case ErrNotMember, ErrPermissionDenied, ErrInvitationEmailMismatch:
+		status = http.StatusForbidden
+	case ErrOrganizationNotFound, ErrInvalidInvitation:
+		status = http.StatusNotFound
+	case ErrInvitationExpired:
+		status = http.StatusGone
+	case ErrAlreadyMember:
+		status = http.StatusConflict
+	}
+	http.Error(context.Writer, context.TranslateError(err), status)
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,94 @@
+package organization_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/qor/auth"
+	"github.com/qor/auth/claims"
+	"github.com/qor/auth/organization"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+type redirector struct{}
+
+func (redirector) Redirect(w http.ResponseWriter, req *http.Request, action string) {}
+
+func setup(t *testing.T) (*auth.Auth, *organization.Provider, *organization.Organization) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	Auth := auth.New(&auth.Config{DB: db, Redirector: redirector{}})
+	provider := organization.New(&organization.Config{
+		InvitationMailer: func(*organization.Invitation, *auth.Context) error { return nil },
+	})
+	Auth.RegisterProvider(provider)
+	if err := auth.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	org := &organization.Organization{Name: "Acme", Slug: "acme"}
+	if err := provider.Create(newContext(Auth, "1", ""), org, "1"); err != nil {
+		t.Fatal(err)
+	}
+	return Auth, provider, org
+}
+
+func newContext(Auth *auth.Auth, userID string, organizationID string) *auth.Context {
+	return &auth.Context{
+		Auth:    Auth,
+		Claims:  &claims.Claims{UserID: userID, OrganizationID: organizationID, Claims: jwt.Claims{ID: "user" + userID + "@example.com"}},
+		Request: httptest.NewRequest("POST", "/", nil),
+		Writer:  httptest.NewRecorder(),
+	}
+}
+
+func TestAcceptInvitationKeepsRoleOfExistingMember(t *testing.T) {
+	Auth, provider, org := setup(t)
+	ownerContext := newContext(Auth, "1", org.GetID())
+
+	invitation, err := provider.Invite(ownerContext, "user1@example.com", organization.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := provider.AcceptInvitation(ownerContext, invitation.Token); err != organization.ErrAlreadyMember {
+		t.Errorf("expect accepting invitation as a member rejected, got %v", err)
+	}
+
+	if role, err := provider.MemberRole(ownerContext.Request, org.GetID(), "1"); err != nil || role != organization.RoleOwner {
+		t.Errorf("expect owner keeps its role, got %v, %v", role, err)
+	}
+}
+
+func TestLastOwnerCouldNotBeDemotedOrRemoved(t *testing.T) {
+	Auth, provider, org := setup(t)
+	ownerContext := newContext(Auth, "1", org.GetID())
+
+	if _, err := provider.ChangeRole(ownerContext, "1", organization.RoleMember); err != organization.ErrLastOwner {
+		t.Errorf("expect last owner couldn't be demoted, got %v", err)
+	}
+
+	if err := provider.RemoveMember(ownerContext, "1"); err != organization.ErrLastOwner {
+		t.Errorf("expect last owner couldn't be removed, got %v", err)
+	}
+
+	if _, err := provider.AddMember(ownerContext, org.ID, "2", organization.RoleOwner); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := provider.ChangeRole(ownerContext, "1", organization.RoleMember); err != nil {
+		t.Errorf("expect owner demoted when there is another owner, got %v", err)
+	}
+
+	if err := provider.RemoveMember(newContext(Auth, "2", org.GetID()), "2"); err != organization.ErrLastOwner {
+		t.Errorf("expect new last owner couldn't be removed, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+// Package otpauth renders otpauth:// provisioning URIs of TOTP secrets as QR codes, for MFA enrollment pages
+package otpauth
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"rsc.io/qr"
+)
+
+// Key TOTP key, refer https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+type Key struct {
+	Issuer      string
+	AccountName string
+	// Secret base32 encoded secret
+	Secret string
+	// Algorithm default value is `SHA1`
+	Algorithm string
+	// Digits default value is 6
+	Digits int
+	// Period default value is 30 seconds
+	Period int
+}
+
+// URI otpauth:// provisioning URI of the key
+func (key Key) URI() string {
+	params := url.Values{"secret": []string{key.Secret}}
+	if key.Issuer != "" {
+		params.Set("issuer", key.Issuer)
+	}
+
+	if key.Algorithm != "" {
+		params.Set("algorithm", key.Algorithm)
+	}
+
+	if key.Digits != 0 {
+		params.Set("digits", strconv.Itoa(key.Digits))
+	}
+
+	if key.Period != 0 {
+		params.Set("period", strconv.Itoa(key.Period))
+	}
+
+	label := key.AccountName
+	if key.Issuer != "" {
+		label = key.Issuer + ":" + key.AccountName
+	}
+
+	return (&url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + label, RawQuery: params.Encode()}).String()
+}
+
+// Scale image pixels per QR module, default value is 6
+var Scale = 6
+
+// PNG render uri as QR code PNG image
+func PNG(uri string) ([]byte, error) {
+	code, err := qr.Encode(uri, qr.M)
+	if err != nil {
+		return nil, err
+	}
+
+	code.Scale = Scale
+	return code.PNG(), nil
+}
+
+// SVG render uri as QR code SVG image
+func SVG(uri string) (string, error) {
+	code, err := qr.Encode(uri, qr.M)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		buf bytes.Buffer
+		// 4 modules quiet zone
+		size = code.Size + 8
+	)
+
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" shape-rendering="crispEdges">`, size, size, size*Scale, size*Scale)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, size, size)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x+4, y+4)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.String(), nil
+}
+
+// DataURL render uri as QR code PNG data URL, could be used as inline image's src
+func DataURL(uri string) (template.URL, error) {
+	content, err := PNG(uri)
+	if err != nil {
+		return "", err
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(content)), nil
+}
+
+// FuncMap view helpers, used in views like `<img src="{{otpauth_qr_code .URI}}">` or `{{otpauth_qr_svg .URI}}`
+var FuncMap = template.FuncMap{
+	"otpauth_qr_code": DataURL,
+	"otpauth_qr_svg": func(uri string) (template.HTML, error) {
+		svg, err := SVG(uri)
+		return template.HTML(svg), err
+	},
+}
+
+// Handler serve QR code of the provisioning URI got from getURI, as PNG, or SVG with query `?format=svg`, responses are never cached, as they contain the secret
+func Handler(getURI func(req *http.Request) (string, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		uri, err := getURI(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		if req.URL.Query().Get("format") == "svg" {
+			svg, err := SVG(uri)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "image/svg+xml")
+			w.Write([]byte(svg))
+			return
+		}
+
+		content, err := PNG(uri)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(content)
+	})
+}
@@ -0,0 +1,230 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/qor/auth"
+	"github.com/qor/auth/auth_identity"
+)
+
+// ErrUnsupportedModel only auth_identity.AuthIdentity is supported
+var ErrUnsupportedModel = errors.New("postgres identity store only supports *auth_identity.AuthIdentity")
+
+const columns = "id, created_at, updated_at, provider, uid, encrypted_password, user_id, confirmed_at, token"
+
+// Config postgres identity store config
+type Config struct {
+	// Pool pgx connection pool, pgx prepares statements and caches them for each connection by default, so queries are parsed/planned only once per connection
+	Pool *pgxpool.Pool
+	// Table auth identities table, could be schema qualified, like `auth.auth_identities`, default value is Auth's table name of `auth_identities`, with TableNames, TablePrefix, TableSchema applied, same as gorm's
+	Table string
+}
+
+// New initialize postgres identity store, panics if config is invalid, use NewWithError to handle the error
+func New(config *Config) *IdentityStore {
+	store, err := NewWithError(config)
+	if err != nil {
+		panic(err)
+	}
+	return store
+}
+
+// NewWithError initialize postgres identity store, which uses raw SQL with pgx instead of gorm, for services don't use gorm and want fewer allocations per login,
+// returns a *auth.ConfigError if config is invalid
+//
+//	pool, _ := pgxpool.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+//	store, err := postgres.NewWithError(&postgres.Config{Pool: pool})
+//	Auth := auth.New(&auth.Config{IdentityStore: store})
+//
+// queries are sent with Pool, so they don't take part in `Auth.Transaction`, and aren't sent to Auth's ReplicaDB
+func NewWithError(config *Config) (*IdentityStore, error) {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &IdentityStore{Config: config}, nil
+}
+
+// Validate validate config
+func (config *Config) Validate() error {
+	configErr := &auth.ConfigError{Name: "postgres"}
+	if config.Pool == nil {
+		configErr.Add("Pool must be specified")
+	}
+	return configErr.Err()
+}
+
+// IdentityStore postgres identity store, saves auth identities with its own Pool, so they are saved out of `Auth.Transaction`,
+// like registrations, rolled back transactions won't roll back identities saved by it
+type IdentityStore struct {
+	*Config
+	once    sync.Once
+	queries queries
+}
+
+type queries struct {
+	findByProviderUID string
+	findByUserID      string
+	insert            string
+	update            string
+	updateUserID      string
+	updateToken       string
+	delete            string
+	count             string
+}
+
+// sql get queries, table name is resolved with Auth's config at first query, as the store is initialized before Auth
+func (store *IdentityStore) sql(context *auth.Context) *queries {
+	store.once.Do(func() {
+		name := store.Config.Table
+		if name == "" {
+			name, _ = context.Auth.Config.TableName("auth_identities")
+		}
+
+		table := pgx.Identifier(strings.Split(name, ".")).Sanitize()
+		store.queries = queries{
+			findByProviderUID: fmt.Sprintf("SELECT %v FROM %v WHERE provider = $1 AND uid = $2 AND deleted_at IS NULL LIMIT 1", columns, table),
+			findByUserID:      fmt.Sprintf("SELECT %v FROM %v WHERE user_id = $1 AND deleted_at IS NULL ORDER BY id", columns, table),
+			insert:            fmt.Sprintf("INSERT INTO %v (created_at, updated_at, provider, uid, encrypted_password, user_id, confirmed_at, token) VALUES ($1, $1, $2, $3, $4, $5, $6, $7) RETURNING id", table),
+			update:            fmt.Sprintf("UPDATE %v SET updated_at = $2, provider = $3, uid = $4, encrypted_password = $5, user_id = $6, confirmed_at = $7, token = $8 WHERE id = $1", table),
+			updateUserID:      fmt.Sprintf("UPDATE %v SET updated_at = $2, user_id = $3 WHERE id = $1", table),
+			updateToken:       fmt.Sprintf("UPDATE %v SET updated_at = $2, token = $3 WHERE id = $1", table),
+			delete:            fmt.Sprintf("DELETE FROM %v WHERE id = $1", table),
+			count:             fmt.Sprintf("SELECT COUNT(*) FROM %v WHERE deleted_at IS NULL", table),
+		}
+	})
+	return &store.queries
+}
+
+var _ auth.IdentityStoreInterface = &IdentityStore{}
+
+// FindByProviderUID find auth identity with provider and UID
+func (store *IdentityStore) FindByProviderUID(context *auth.Context, provider string, uid string) (interface{}, error) {
+	identity, err := scanIdentity(store.Pool.QueryRow(context.Request.Context(), store.sql(context).findByProviderUID, provider, uid))
+	if err == pgx.ErrNoRows {
+		return nil, auth.ErrInvalidAccount
+	}
+	return identity, err
+}
+
+// FindByUserID find auth identities linked to user
+func (store *IdentityStore) FindByUserID(context *auth.Context, userID string) (identities []interface{}, err error) {
+	rows, err := store.Pool.Query(context.Request.Context(), store.sql(context).findByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		identity, err := scanIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+// Create save a new auth identity
+func (store *IdentityStore) Create(context *auth.Context, identity interface{}) error {
+	authIdentity, ok := identity.(*auth_identity.AuthIdentity)
+	if !ok {
+		return ErrUnsupportedModel
+	}
+
+	now := context.Auth.Now()
+	authIdentity.CreatedAt, authIdentity.UpdatedAt = now, now
+	return store.Pool.QueryRow(context.Request.Context(), store.sql(context).insert,
+		now, authIdentity.Provider, authIdentity.UID, authIdentity.EncryptedPassword, authIdentity.UserID, authIdentity.ConfirmedAt, authIdentity.Token,
+	).Scan(&authIdentity.ID)
+}
+
+// Update save changed auth identity
+func (store *IdentityStore) Update(context *auth.Context, identity interface{}) error {
+	authIdentity, ok := identity.(*auth_identity.AuthIdentity)
+	if !ok {
+		return ErrUnsupportedModel
+	}
+
+	authIdentity.UpdatedAt = context.Auth.Now()
+	_, err := store.Pool.Exec(context.Request.Context(), store.sql(context).update,
+		authIdentity.ID, authIdentity.UpdatedAt, authIdentity.Provider, authIdentity.UID, authIdentity.EncryptedPassword, authIdentity.UserID, authIdentity.ConfirmedAt, authIdentity.Token,
+	)
+	return err
+}
+
+// Link link auth identity to user
+func (store *IdentityStore) Link(context *auth.Context, identity interface{}, userID string) error {
+	authIdentity, ok := identity.(*auth_identity.AuthIdentity)
+	if !ok {
+		return ErrUnsupportedModel
+	}
+
+	authIdentity.UserID, authIdentity.UpdatedAt = userID, context.Auth.Now()
+	_, err := store.Pool.Exec(context.Request.Context(), store.sql(context).updateUserID, authIdentity.ID, authIdentity.UpdatedAt, userID)
+	return err
+}
+
+// UpdateToken update auth identity's token
+func (store *IdentityStore) UpdateToken(context *auth.Context, identity interface{}, token string) error {
+	authIdentity, ok := identity.(*auth_identity.AuthIdentity)
+	if !ok {
+		return ErrUnsupportedModel
+	}
+
+	authIdentity.Token, authIdentity.UpdatedAt = token, context.Auth.Now()
+	_, err := store.Pool.Exec(context.Request.Context(), store.sql(context).updateToken, authIdentity.ID, authIdentity.UpdatedAt, token)
+	return err
+}
+
+// Delete delete auth identity permanently, same as gorm identity store
+func (store *IdentityStore) Delete(context *auth.Context, identity interface{}) error {
+	authIdentity, ok := identity.(*auth_identity.AuthIdentity)
+	if !ok {
+		return ErrUnsupportedModel
+	}
+
+	_, err := store.Pool.Exec(context.Request.Context(), store.sql(context).delete, authIdentity.ID)
+	return err
+}
+
+// Count count all auth identities
+func (store *IdentityStore) Count(context *auth.Context) (count int, err error) {
+	err = store.Pool.QueryRow(context.Request.Context(), store.sql(context).count).Scan(&count)
+	return
+}
+
+func scanIdentity(row pgx.Row) (*auth_identity.AuthIdentity, error) {
+	var (
+		identity                         auth_identity.AuthIdentity
+		encryptedPassword, userID, token *string
+	)
+
+	err := row.Scan(
+		&identity.ID, &identity.CreatedAt, &identity.UpdatedAt,
+		&identity.Provider, &identity.UID, &encryptedPassword, &userID, &identity.ConfirmedAt, &token,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	identity.EncryptedPassword = stringValue(encryptedPassword)
+	identity.UserID = stringValue(userID)
+	identity.Token = stringValue(token)
+	return &identity, nil
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -15,22 +15,47 @@ type Provider interface {
 	ServeHTTP(*Context)
 }
 
-// RegisterProvider register auth provider
+// ProviderValidator providers implement it to check Auth's config they depend on, like TrackSessions, before registered
+type ProviderValidator interface {
+	ValidateAuth(*Auth) error
+}
+
+// RegisterProvider register auth provider, panics if Auth's config is invalid for the provider, use RegisterProviderWithError to handle the error
 func (auth *Auth) RegisterProvider(provider Provider) {
+	if err := auth.RegisterProviderWithError(provider); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterProviderWithError register auth provider, returns a *ConfigError lists all problems if provider implements ProviderValidator and Auth's config is invalid for it
+func (auth *Auth) RegisterProviderWithError(provider Provider) error {
+	if validator, ok := provider.(ProviderValidator); ok {
+		if err := validator.ValidateAuth(auth); err != nil {
+			return err
+		}
+	}
+
+	auth.providersMutex.Lock()
+	defer auth.providersMutex.Unlock()
+
 	name := provider.GetName()
 	for _, p := range auth.providers {
 		if p.GetName() == name {
 			fmt.Printf("warning: auth provider %v already registered", name)
-			return
+			return nil
 		}
 	}
 
 	provider.ConfigAuth(auth)
 	auth.providers = append(auth.providers, provider)
+	return nil
 }
 
 // GetProvider get provider with name
 func (auth *Auth) GetProvider(name string) Provider {
+	auth.providersMutex.RLock()
+	defer auth.providersMutex.RUnlock()
+
 	for _, provider := range auth.providers {
 		if provider.GetName() == name {
 			return provider
@@ -41,6 +66,9 @@ func (auth *Auth) GetProvider(name string) Provider {
 
 // GetProviders return registered providers
 func (auth *Auth) GetProviders() (providers []Provider) {
+	auth.providersMutex.RLock()
+	defer auth.providersMutex.RUnlock()
+
 	for _, provider := range auth.providers {
 		providers = append(providers, provider)
 	}
@@ -0,0 +1,184 @@
+// Package devlogin developer auto-login provider, shows a picker of seeded users and logs in as any of them instantly, for local development only
+package devlogin
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"reflect"
+
+	"github.com/qor/auth"
+	"github.com/qor/auth/claims"
+	"github.com/qor/qor/utils"
+)
+
+// Config devlogin provider config
+type Config struct {
+	// Enabled devlogin provider responds 404 for all requests unless it is enabled explicitly, NEVER enable it in production, like:
+	//	devlogin.New(&devlogin.Config{Enabled: os.Getenv("APP_ENV") == "development"})
+	Enabled bool
+	// Limit max number of users shown in picker, default value is 50
+	Limit int
+	// Users get users shown in picker, default is first `Limit` records of Auth's UserModel
+	Users func(context *auth.Context) ([]User, error)
+}
+
+// User user shown in picker
+type User struct {
+	ID    string
+	Label string
+}
+
+// New initialize devlogin provider
+func New(config *Config) *Provider {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if config.Limit == 0 {
+		config.Limit = 50
+	}
+
+	provider := &Provider{Config: config}
+	if config.Users == nil {
+		config.Users = provider.findUsers
+	}
+	return provider
+}
+
+// Provider devlogin provider
+//
+//	GET  {Auth Prefix}/devlogin/login     show user picker
+//	POST {Auth Prefix}/devlogin/callback  login as user with form value `user_id`
+type Provider struct {
+	*Config
+	Auth *auth.Auth
+}
+
+// GetName return provider name
+func (Provider) GetName() string {
+	return "devlogin"
+}
+
+// ConfigAuth config auth
+func (provider *Provider) ConfigAuth(auth *auth.Auth) {
+	provider.Auth = auth
+}
+
+// Login show user picker
+func (provider Provider) Login(context *auth.Context) {
+	if !provider.Enabled {
+		http.NotFound(context.Writer, context.Request)
+		return
+	}
+
+	users, err := provider.Users(context)
+	if err != nil {
+		http.Error(context.Writer, context.TranslateError(err), http.StatusInternalServerError)
+		return
+	}
+
+	context.Execute("auth/devlogin/login", template.FuncMap{
+		"dev_users": func() []User { return users },
+	})
+}
+
+// Logout logout
+func (provider Provider) Logout(context *auth.Context) {
+	if !provider.Enabled {
+		http.NotFound(context.Writer, context.Request)
+		return
+	}
+	context.Auth.LogoutHandler(context)
+}
+
+// Register devlogin provider doesn't support register, seed users instead
+func (provider Provider) Register(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Deregister devlogin provider doesn't support deregister
+func (provider Provider) Deregister(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Callback login as picked user
+func (provider Provider) Callback(context *auth.Context) {
+	if !provider.Enabled {
+		http.NotFound(context.Writer, context.Request)
+		return
+	}
+
+	if context.Request.Method != http.MethodPost {
+		http.Error(context.Writer, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	context.Auth.LoginHandler(context, provider.authorize)
+}
+
+// ServeHTTP devlogin provider doesn't have other endpoints
+func (provider Provider) ServeHTTP(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+func (provider Provider) authorize(context *auth.Context) (*claims.Claims, error) {
+	context.Request.ParseForm()
+	userID := context.FormValue("user_id")
+	if userID == "" {
+		return nil, auth.ErrInvalidAccount
+	}
+
+	users, err := provider.Users(context)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		if user.ID == userID {
+			return &claims.Claims{Provider: provider.GetName(), UserID: userID}, nil
+		}
+	}
+	return nil, auth.ErrInvalidAccount
+}
+
+// findUsers find users from Auth's UserModel, labelled with their `Name`, `Email` fields if exist
+func (provider Provider) findUsers(context *auth.Context) ([]User, error) {
+	if context.Auth.Config.UserModel == nil {
+		return nil, nil
+	}
+
+	var (
+		db      = context.Auth.GetReadDB(context.Request)
+		records = reflect.New(reflect.SliceOf(reflect.TypeOf(context.Auth.Config.UserModel)))
+	)
+
+	if err := db.Limit(provider.Limit).Find(records.Interface()).Error; err != nil {
+		return nil, err
+	}
+
+	var users []User
+	for i := 0; i < records.Elem().Len(); i++ {
+		record := records.Elem().Index(i).Addr().Interface()
+		user := User{ID: fmt.Sprint(db.NewScope(record).PrimaryKeyValue())}
+
+		value := utils.Indirect(reflect.ValueOf(record))
+		name, email := stringField(value, "Name"), stringField(value, "Email")
+		switch {
+		case name != "" && email != "":
+			user.Label = fmt.Sprintf("%v <%v>", name, email)
+		case name != "" || email != "":
+			user.Label = name + email
+		default:
+			user.Label = user.ID
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func stringField(value reflect.Value, name string) string {
+	if field := value.FieldByName(name); field.IsValid() && field.Kind() == reflect.String {
+		return field.String()
+	}
+	return ""
+}
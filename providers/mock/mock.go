@@ -0,0 +1,194 @@
+// Package mock mock provider for application tests, login/callback flows could be exercised with programmable users and failures, without real OAuth credentials or network
+package mock
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+
+	"github.com/qor/auth"
+	"github.com/qor/auth/claims"
+	"github.com/qor/qor/utils"
+)
+
+// Config mock provider config
+type Config struct {
+	// Name provider name, default value is `mock`, set it to a real provider's name, like `github`, to replace it in tests
+	Name string
+	// Users users could login, matched with form value `uid`
+	Users []auth.Schema
+}
+
+// New initialize mock provider
+func New(config *Config) *Provider {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if config.Name == "" {
+		config.Name = "mock"
+	}
+
+	return &Provider{Config: config}
+}
+
+// Provider mock provider, behaves like an OAuth provider, login redirects to callback, callback logs in user with form value `uid`, auth identity and user are created if not exist
+//
+//	GET {Auth Prefix}/mock/login?uid=alice     redirect to callback
+//	GET {Auth Prefix}/mock/callback?uid=alice  login as alice
+//	GET {Auth Prefix}/mock/callback?error=access_denied  simulate user denied authorization
+type Provider struct {
+	*Config
+	Auth *auth.Auth
+
+	mutex    sync.Mutex
+	failWith error
+	failNext []error
+}
+
+// GetName return provider name
+func (provider *Provider) GetName() string {
+	return provider.Config.Name
+}
+
+// ConfigAuth config auth
+func (provider *Provider) ConfigAuth(auth *auth.Auth) {
+	provider.Auth = auth
+}
+
+// AddUser add users could login
+func (provider *Provider) AddUser(users ...auth.Schema) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	provider.Config.Users = append(provider.Config.Users, users...)
+}
+
+// FailWith make all following logins, registrations fail with err until Reset
+func (provider *Provider) FailWith(err error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	provider.failWith = err
+}
+
+// FailNext make next login or registration fail with err, could be called multiple times to queue failures
+func (provider *Provider) FailNext(err error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	provider.failNext = append(provider.failNext, err)
+}
+
+// Reset clear programmed failures
+func (provider *Provider) Reset() {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	provider.failWith, provider.failNext = nil, nil
+}
+
+// Login redirect to callback, like OAuth providers redirect to authorize page
+func (provider *Provider) Login(context *auth.Context) {
+	callbackURL := url.URL{Path: context.Auth.AuthURL(provider.GetName() + "/callback"), RawQuery: context.Request.URL.RawQuery}
+	http.Redirect(context.Writer, context.Request, callbackURL.String(), http.StatusFound)
+}
+
+// Logout logout
+func (provider *Provider) Logout(context *auth.Context) {
+	context.Auth.LogoutHandler(context)
+}
+
+// Register register user with form value `uid`
+func (provider *Provider) Register(context *auth.Context) {
+	context.Auth.RegisterHandler(context, provider.authorize)
+}
+
+// Deregister mock provider doesn't support deregister
+func (provider *Provider) Deregister(context *auth.Context) {
+	context.Auth.DeregisterHandler(context)
+}
+
+// Callback login user with form value `uid`
+func (provider *Provider) Callback(context *auth.Context) {
+	context.Auth.LoginHandler(context, provider.authorize)
+}
+
+// ServeHTTP mock provider doesn't have other endpoints
+func (provider *Provider) ServeHTTP(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+func (provider *Provider) authorize(context *auth.Context) (*claims.Claims, error) {
+	if err := provider.failure(); err != nil {
+		return nil, err
+	}
+
+	req := context.Request
+	req.ParseForm()
+	if req.Form.Get("error") != "" {
+		return nil, auth.ErrUnauthorized
+	}
+
+	schema, ok := provider.findUser(req.Form.Get("uid"))
+	if !ok {
+		return nil, auth.ErrInvalidAccount
+	}
+
+	if identity, err := context.Auth.IdentityStore.FindByProviderUID(context, schema.Provider, schema.UID); err == nil {
+		return toClaims(identity), nil
+	} else if err != auth.ErrInvalidAccount {
+		return nil, err
+	}
+
+	var result interface{}
+	err := context.Auth.Transaction(context, func(context *auth.Context) error {
+		_, userID, err := context.Auth.UserStorer.Save(&schema, context)
+		if err != nil {
+			return err
+		}
+
+		identity := context.Auth.NewAuthIdentity()
+		value := utils.Indirect(reflect.ValueOf(identity))
+		value.FieldByName("Provider").SetString(schema.Provider)
+		value.FieldByName("UID").SetString(schema.UID)
+		value.FieldByName("UserID").SetString(userID)
+
+		result, _, err = auth.FindOrCreateIdentity(context, identity)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return toClaims(result), nil
+}
+
+func (provider *Provider) failure() error {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if len(provider.failNext) > 0 {
+		err := provider.failNext[0]
+		provider.failNext = provider.failNext[1:]
+		return err
+	}
+	return provider.failWith
+}
+
+func (provider *Provider) findUser(uid string) (auth.Schema, bool) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	for _, user := range provider.Config.Users {
+		if uid != "" && user.UID == uid {
+			user.Provider = provider.Config.Name
+			return user, true
+		}
+	}
+	return auth.Schema{}, false
+}
+
+func toClaims(identity interface{}) *claims.Claims {
+	if claimer, ok := identity.(claims.ClaimerInterface); ok {
+		return claimer.ToClaims()
+	}
+	return &claims.Claims{}
+}
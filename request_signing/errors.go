@@ -0,0 +1,48 @@
+package request_signing
+
+const (
+	// ErrorCodeMissingSignature error code of ErrMissingSignature
+	ErrorCodeMissingSignature = "missing_request_signature"
+	// ErrorCodeInvalidSignature error code of ErrInvalidSignature
+	ErrorCodeInvalidSignature = "invalid_request_signature"
+	// ErrorCodeRequestExpired error code of ErrRequestExpired
+	ErrorCodeRequestExpired = "request_signature_expired"
+	// ErrorCodeReplayedRequest error code of ErrReplayedRequest
+	ErrorCodeReplayedRequest = "replayed_request"
+	// ErrorCodeUnknownKey error code of ErrUnknownKey
+	ErrorCodeUnknownKey = "unknown_signing_key"
+	// ErrorCodeBodyTooLarge error code of ErrBodyTooLarge
+	ErrorCodeBodyTooLarge = "request_body_too_large"
+	// ErrorCodeNameRequired error code of ErrNameRequired
+	ErrorCodeNameRequired = "signing_key_name_required"
+	// ErrorCodeForbidden error code of ErrForbidden
+	ErrorCodeForbidden = "forbidden"
+)
+
+// signingError request signing's error, with its own error code
+type signingError struct {
+	code    string
+	message string
+}
+
+func (err signingError) Error() string     { return err.message }
+func (err signingError) ErrorCode() string { return err.code }
+
+var (
+	// ErrMissingSignature request doesn't have key ID, timestamp, nonce or signature header
+	ErrMissingSignature error = signingError{code: ErrorCodeMissingSignature, message: "missing request signature"}
+	// ErrInvalidSignature signature doesn't match the request
+	ErrInvalidSignature error = signingError{code: ErrorCodeInvalidSignature, message: "invalid request signature"}
+	// ErrRequestExpired request's timestamp is out of MaxSkew
+	ErrRequestExpired error = signingError{code: ErrorCodeRequestExpired, message: "request signature expired"}
+	// ErrReplayedRequest request's nonce is already used
+	ErrReplayedRequest error = signingError{code: ErrorCodeReplayedRequest, message: "replayed request"}
+	// ErrUnknownKey signing key not found, revoked or expired
+	ErrUnknownKey error = signingError{code: ErrorCodeUnknownKey, message: "unknown signing key"}
+	// ErrBodyTooLarge request's body is larger than MaxBodySize
+	ErrBodyTooLarge error = signingError{code: ErrorCodeBodyTooLarge, message: "request body too large"}
+	// ErrNameRequired signing key's name is blank
+	ErrNameRequired error = signingError{code: ErrorCodeNameRequired, message: "signing key name required"}
+	// ErrForbidden current user isn't authorized to manage signing keys
+	ErrForbidden error = signingError{code: ErrorCodeForbidden, message: "forbidden"}
+)
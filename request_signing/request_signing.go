@@ -0,0 +1,590 @@
+package request_signing
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/qor/auth"
+	"github.com/qor/auth/cache"
+	"github.com/qor/auth/cache/memory"
+	"github.com/qor/auth/claims"
+)
+
+func init() {
+	auth.RegisterTables("signing_keys")
+	auth.RegisterMigration(auth.Migration{ID: "request_signing/001_create_signing_keys", Migrate: func(db *gorm.DB) error {
+		return db.AutoMigrate(&SigningKey{}).Error
+	}})
+}
+
+const (
+	// HeaderKeyID header has signing key's ID
+	HeaderKeyID = "X-Auth-Key-Id"
+	// HeaderTimestamp header has request's unix timestamp in seconds
+	HeaderTimestamp = "X-Auth-Timestamp"
+	// HeaderNonce header has request's random nonce, requests with used nonces are rejected
+	HeaderNonce = "X-Auth-Nonce"
+	// HeaderSignature header has hex encoded HMAC-SHA256 of StringToSign
+	HeaderSignature = "X-Auth-Signature"
+)
+
+const (
+	// EventAuthenticated published after a signed request authenticated, data has `key_id`
+	EventAuthenticated = "request_signing.authenticated"
+	// EventRejected published after a signed request rejected, data has `key_id`, `error`
+	EventRejected = "request_signing.rejected"
+	// EventKeyCreated published after signing key created
+	EventKeyCreated = "request_signing.key_created"
+	// EventKeyRotated published after signing key's secret rotated
+	EventKeyRotated = "request_signing.key_rotated"
+	// EventKeyRevoked published after signing key revoked
+	EventKeyRevoked = "request_signing.key_revoked"
+)
+
+// SigningKey key used by an internal service to sign requests, it acts as UserID if set, Secret is saved encrypted if Config's EncryptionKey is set
+type SigningKey struct {
+	gorm.Model
+	KeyID  string `gorm:"unique_index"`
+	Name   string
+	UserID string `gorm:"index"`
+	Secret string `json:"-"`
+	// PreviousSecret secret before rotated, accepted until PreviousSecretExpiresAt, so services could be redeployed with the new secret
+	PreviousSecret          string `json:"-"`
+	PreviousSecretExpiresAt *time.Time
+	ExpiresAt               *time.Time
+	LastUsedAt              *time.Time
+	RevokedAt               *time.Time
+}
+
+// IsActive check key is not revoked nor expired
+func (key SigningKey) IsActive(now time.Time) bool {
+	return key.RevokedAt == nil && (key.ExpiresAt == nil || key.ExpiresAt.After(now))
+}
+
+// Config request signing provider config
+type Config struct {
+	// MaxSkew max difference between request's timestamp and current time, default value is 5 minutes
+	MaxSkew time.Duration
+	// MaxBodySize max size of signed request's body, default value is 10MB
+	MaxBodySize int64
+	// Cache store used nonces, default value is in-memory cache, use a shared one like redis if you have multiple instances
+	Cache cache.Interface
+	// EncryptionKey encrypt secrets saved in database with AES-GCM, 16, 24 or 32 bytes, like resolved with secrets package, secrets are saved as plain text if blank
+	EncryptionKey []byte
+	// RotationGrace how long previous secret is accepted after rotated, default value is 24 hours
+	RotationGrace time.Duration
+	// Authorize authorize key management endpoints, like checking current user is admin with Authority, endpoints are forbidden if blank
+	Authorize func(context *auth.Context) bool
+}
+
+// New initialize request signing provider, register it with `Auth.RegisterProvider`, then authenticate services' requests with Middleware
+func New(config *Config) *Provider {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if config.MaxSkew == 0 {
+		config.MaxSkew = 5 * time.Minute
+	}
+
+	if config.MaxBodySize == 0 {
+		config.MaxBodySize = 10 << 20
+	}
+
+	if config.Cache == nil {
+		config.Cache = memory.New()
+	}
+
+	if config.RotationGrace == 0 {
+		config.RotationGrace = 24 * time.Hour
+	}
+
+	return &Provider{Config: config}
+}
+
+// Provider request signing provider, authenticate service-to-service requests signed with HMAC, and manage signing keys
+type Provider struct {
+	*Config
+	Auth *auth.Auth
+}
+
+// GetName return provider name
+func (Provider) GetName() string {
+	return "request_signing"
+}
+
+// ConfigAuth config auth
+func (provider *Provider) ConfigAuth(Auth *auth.Auth) {
+	provider.Auth = Auth
+}
+
+// Login request signing provider doesn't support login
+func (provider Provider) Login(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Logout request signing provider doesn't support logout
+func (provider Provider) Logout(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Register request signing provider doesn't support register
+func (provider Provider) Register(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Deregister request signing provider doesn't support deregister
+func (provider Provider) Deregister(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Callback request signing provider doesn't support callback
+func (provider Provider) Callback(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// CSRFExempt signed requests aren't validated, like requests authenticated with `Authorization` header, as browsers couldn't send the header cross-site
+func (provider Provider) CSRFExempt(req *http.Request) bool {
+	return req.Header.Get(HeaderSignature) != ""
+}
+
+// ServeHTTP serve key management endpoints, authorized with Config's Authorize, secrets are only responded when created or rotated
+//
+//	GET  {Auth Prefix}/request_signing/keys         list keys
+//	POST {Auth Prefix}/request_signing/keys         create key with `name`, `user_id`
+//	POST {Auth Prefix}/request_signing/rotate       rotate secret of `key_id`
+//	POST {Auth Prefix}/request_signing/revoke       revoke `key_id`
+func (provider Provider) ServeHTTP(context *auth.Context) {
+	var (
+		req     = context.Request
+		w       = context.Writer
+		reqPath = strings.TrimPrefix(req.URL.Path, context.Auth.URLPrefix)
+		paths   = strings.Split(reqPath, "/")
+	)
+
+	if provider.Authorize == nil || !provider.Authorize(context) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden", "error_description": context.TranslateError(ErrForbidden)})
+		return
+	}
+
+	req.ParseForm()
+	if !(paths[1] == "keys" && req.Method == http.MethodGet) && req.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var (
+		key    *SigningKey
+		secret string
+		err    error
+	)
+
+	switch paths[1] {
+	case "keys":
+		if req.Method == http.MethodGet {
+			keys, err := provider.ListKeys(context)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "error", "error_description": context.TranslateError(err)})
+				return
+			}
+			writeJSON(w, http.StatusOK, keys)
+			return
+		}
+		key, secret, err = provider.CreateKey(context, context.FormValue("name"), context.FormValue("user_id"))
+	case "rotate":
+		key, secret, err = provider.RotateKey(context, context.FormValue("key_id"))
+	case "revoke":
+		if err = provider.RevokeKey(context, context.FormValue("key_id")); err == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	default:
+		http.NotFound(w, req)
+		return
+	}
+
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": auth.ErrorCode(err), "error_description": context.TranslateError(err)})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"key": key, "secret": secret})
+}
+
+// CreateKey create signing key for service with name, it acts as user of userID if not blank, returns the secret, which couldn't be got again
+func (provider Provider) CreateKey(context *auth.Context, name string, userID string) (*SigningKey, string, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, "", ErrNameRequired
+	}
+
+	secret, err := context.Auth.GenerateToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	keyID, err := context.Auth.GenerateToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	encrypted, err := provider.encrypt(secret)
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &SigningKey{KeyID: "sk_" + keyID[:24], Name: name, UserID: userID, Secret: encrypted}
+	if err := context.Auth.GetDB(context.Request).Create(key).Error; err != nil {
+		return nil, "", err
+	}
+
+	context.Auth.Publish(EventKeyCreated, context, map[string]interface{}{"key_id": key.KeyID, "name": name, "user_id": userID})
+	return key, secret, nil
+}
+
+// RotateKey generate new secret of key, previous secret is accepted for RotationGrace, returns the new secret
+func (provider Provider) RotateKey(context *auth.Context, keyID string) (*SigningKey, string, error) {
+	key, err := provider.GetKey(context, keyID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := context.Auth.Now()
+	if !key.IsActive(now) {
+		return nil, "", ErrUnknownKey
+	}
+
+	secret, err := context.Auth.GenerateToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	encrypted, err := provider.encrypt(secret)
+	if err != nil {
+		return nil, "", err
+	}
+
+	expiresAt := now.Add(provider.RotationGrace)
+	if err := context.Auth.GetDB(context.Request).Model(key).Updates(map[string]interface{}{
+		"secret":                     encrypted,
+		"previous_secret":            key.Secret,
+		"previous_secret_expires_at": expiresAt,
+	}).Error; err != nil {
+		return nil, "", err
+	}
+
+	context.Auth.Publish(EventKeyRotated, context, map[string]interface{}{"key_id": key.KeyID})
+	return key, secret, nil
+}
+
+// RevokeKey revoke key, requests signed with it are rejected
+func (provider Provider) RevokeKey(context *auth.Context, keyID string) error {
+	key, err := provider.GetKey(context, keyID)
+	if err != nil {
+		return err
+	}
+
+	if err := context.Auth.GetDB(context.Request).Model(key).Update("revoked_at", context.Auth.Now()).Error; err != nil {
+		return err
+	}
+
+	context.Auth.Publish(EventKeyRevoked, context, map[string]interface{}{"key_id": key.KeyID})
+	return nil
+}
+
+// GetKey get key with key ID
+func (provider Provider) GetKey(context *auth.Context, keyID string) (*SigningKey, error) {
+	var key SigningKey
+	if keyID == "" {
+		return nil, ErrUnknownKey
+	}
+
+	if err := context.Auth.GetDB(context.Request).Where("key_id = ?", keyID).First(&key).Error; err != nil {
+		return nil, ErrUnknownKey
+	}
+	return &key, nil
+}
+
+// ListKeys list keys, latest first
+func (provider Provider) ListKeys(context *auth.Context) ([]SigningKey, error) {
+	var keys []SigningKey
+	err := context.Auth.GetReadDB(context.Request).Order("id DESC").Find(&keys).Error
+	return keys, err
+}
+
+// Middleware authenticate signed requests, requests without valid signature are rejected with 401,
+// authenticated requests have current user of key's UserID, or the key if it doesn't act as an user, get it with `Auth.GetCurrentUser(req)`
+func (provider *Provider) Middleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		context := &auth.Context{Auth: provider.Auth, Provider: provider, Request: req, Writer: w}
+
+		key, err := provider.Authenticate(context)
+		if err != nil {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": auth.ErrorCode(err), "error_description": context.TranslateError(err)})
+			return
+		}
+
+		var currentUser interface{} = key
+		if key.UserID != "" {
+			if user, err := provider.Auth.UserStorer.Get(context.Claims, context); err == nil && user != nil {
+				currentUser = user
+			}
+		}
+
+		handler.ServeHTTP(w, req.WithContext(contextWithUser(req.Context(), currentUser)))
+	})
+}
+
+// Authenticate verify request's signature, returns the key signed it, context's Claims is set to claims of the key, request's body is read and restored
+func (provider *Provider) Authenticate(context *auth.Context) (*SigningKey, error) {
+	var (
+		req   = context.Request
+		keyID = req.Header.Get(HeaderKeyID)
+	)
+
+	key, err := provider.authenticate(context)
+	if err != nil {
+		context.Auth.Publish(EventRejected, context, map[string]interface{}{"key_id": keyID, "error": err})
+		return nil, err
+	}
+
+	context.Claims = &claims.Claims{Provider: provider.GetName(), UserID: key.UserID}
+	context.Claims.ID = key.KeyID
+	context.Auth.Publish(EventAuthenticated, context, map[string]interface{}{"key_id": key.KeyID})
+	return key, nil
+}
+
+func (provider *Provider) authenticate(context *auth.Context) (*SigningKey, error) {
+	var (
+		req       = context.Request
+		now       = context.Auth.Now()
+		nonce     = req.Header.Get(HeaderNonce)
+		signature = req.Header.Get(HeaderSignature)
+	)
+
+	if req.Header.Get(HeaderKeyID) == "" || signature == "" || nonce == "" {
+		return nil, ErrMissingSignature
+	}
+
+	timestamp, err := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return nil, ErrMissingSignature
+	}
+
+	if skew := now.Sub(time.Unix(timestamp, 0)); skew > provider.MaxSkew || skew < -provider.MaxSkew {
+		return nil, ErrRequestExpired
+	}
+
+	key, err := provider.GetKey(context, req.Header.Get(HeaderKeyID))
+	if err != nil || !key.IsActive(now) {
+		return nil, ErrUnknownKey
+	}
+
+	body, err := readBody(req, provider.MaxBodySize)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		stringToSign = StringToSign(req, body)
+		valid        bool
+	)
+
+	for _, encrypted := range []string{key.Secret, key.PreviousSecret} {
+		if encrypted == "" || (encrypted == key.PreviousSecret && (key.PreviousSecretExpiresAt == nil || !now.Before(*key.PreviousSecretExpiresAt))) {
+			continue
+		}
+
+		secret, err := provider.decrypt(encrypted)
+		if err != nil {
+			return nil, err
+		}
+
+		if hmac.Equal([]byte(sign(secret, stringToSign)), []byte(strings.ToLower(signature))) {
+			valid = true
+			break
+		}
+	}
+
+	if !valid {
+		return nil, ErrInvalidSignature
+	}
+
+	// nonces are remembered longer than accepted timestamps, so replayed requests are always rejected, concurrent copies of a request race on SetNX, only one of them is accepted,
+	// requests are rejected if nonces couldn't be saved
+	if ok, err := provider.Cache.SetNX("request_signing:nonce:"+key.KeyID+":"+nonce, true, 2*provider.MaxSkew); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrReplayedRequest
+	}
+
+	// update last used time at most once a minute, so busy services don't write database for each request
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > time.Minute {
+		context.Auth.GetDB(req).Model(key).UpdateColumn("last_used_at", now)
+	}
+	return key, nil
+}
+
+// StringToSign string signed with HMAC-SHA256, method, path with query, timestamp, nonce, and hex encoded SHA-256 of body, separated by new line
+func StringToSign(req *http.Request, body []byte) string {
+	target := req.URL.EscapedPath()
+	if req.URL.RawQuery != "" {
+		target += "?" + req.URL.RawQuery
+	}
+
+	bodySum := sha256.Sum256(body)
+	return strings.Join([]string{
+		req.Method,
+		target,
+		req.Header.Get(HeaderTimestamp),
+		req.Header.Get(HeaderNonce),
+		hex.EncodeToString(bodySum[:]),
+	}, "\n")
+}
+
+// Sign sign request with key, sets key ID, timestamp, nonce and signature headers, request's body is read and restored
+//
+//	req, _ := http.NewRequest("POST", "https://billing.internal/invoices", body)
+//	request_signing.Sign(req, os.Getenv("SIGNING_KEY_ID"), os.Getenv("SIGNING_SECRET"))
+func Sign(req *http.Request, keyID string, secret string) error {
+	body, err := readBody(req, -1)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+
+	req.Header.Set(HeaderKeyID, keyID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Unix(), 10))
+	req.Header.Set(HeaderNonce, hex.EncodeToString(nonce))
+	req.Header.Set(HeaderSignature, sign(secret, StringToSign(req, body)))
+	return nil
+}
+
+// Transport http.RoundTripper signs requests with key, used to build clients of internal services, like `&http.Client{Transport: &request_signing.Transport{KeyID: id, Secret: secret}}`
+type Transport struct {
+	KeyID  string
+	Secret string
+	// Base transport sends signed requests, default value is http.DefaultTransport
+	Base http.RoundTripper
+}
+
+// RoundTrip sign request and send it with Base transport, request is cloned, so it isn't changed
+func (transport *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	signed := req.Clone(req.Context())
+	if err := Sign(signed, transport.KeyID, transport.Secret); err != nil {
+		return nil, err
+	}
+
+	base := transport.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(signed)
+}
+
+func sign(secret string, stringToSign string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(stringToSign))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// readBody read request's body, and restore it, so handlers could read it again, maxSize < 0 means no limit
+func readBody(req *http.Request, maxSize int64) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	reader := io.Reader(req.Body)
+	if maxSize >= 0 {
+		reader = io.LimitReader(req.Body, maxSize+1)
+	}
+
+	body, err := ioutil.ReadAll(reader)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if maxSize >= 0 && int64(len(body)) > maxSize {
+		return nil, ErrBodyTooLarge
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// encrypt encrypt secret with EncryptionKey, returns secret itself if EncryptionKey is blank
+func (provider Provider) encrypt(secret string) (string, error) {
+	if len(provider.EncryptionKey) == 0 {
+		return secret, nil
+	}
+
+	gcm, err := provider.gcm()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+// decrypt decrypt secret encrypted with EncryptionKey
+func (provider Provider) decrypt(encrypted string) (string, error) {
+	if len(provider.EncryptionKey) == 0 {
+		return encrypted, nil
+	}
+
+	gcm, err := provider.gcm()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encrypted)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", ErrInvalidSignature
+	}
+
+	secret, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+	return string(secret), nil
+}
+
+func (provider Provider) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(provider.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func contextWithUser(ctx context.Context, user interface{}) context.Context {
+	return context.WithValue(ctx, auth.CurrentUser, user)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
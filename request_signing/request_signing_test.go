@@ -0,0 +1,116 @@
+package request_signing_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/qor/auth"
+	"github.com/qor/auth/cache"
+	"github.com/qor/auth/request_signing"
+)
+
+type redirector struct{}
+
+func (redirector) Redirect(w http.ResponseWriter, req *http.Request, action string) {}
+
+// unavailableCache cache store always fails, like redis is down
+type unavailableCache struct{}
+
+var errUnavailable = errors.New("cache unavailable")
+
+func (unavailableCache) Get(key string, result interface{}) error { return errUnavailable }
+func (unavailableCache) Set(key string, value interface{}, ttl time.Duration) error {
+	return errUnavailable
+}
+func (unavailableCache) SetNX(key string, value interface{}, ttl time.Duration) (bool, error) {
+	return false, errUnavailable
+}
+func (unavailableCache) Increment(key string, ttl time.Duration) (int64, error) {
+	return 0, errUnavailable
+}
+func (unavailableCache) Delete(keys ...string) error { return errUnavailable }
+
+var _ cache.Interface = unavailableCache{}
+
+func setup(t *testing.T, config *request_signing.Config) (*auth.Auth, *request_signing.Provider, *http.Request) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	Auth := auth.New(&auth.Config{DB: db, Redirector: redirector{}})
+	provider := request_signing.New(config)
+	Auth.RegisterProvider(provider)
+	if err := auth.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	key, secret, err := provider.CreateKey(&auth.Context{Auth: Auth, Request: httptest.NewRequest("POST", "/", nil)}, "billing", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/invoices?page=1", nil)
+	if err := request_signing.Sign(req, key.KeyID, secret); err != nil {
+		t.Fatal(err)
+	}
+	return Auth, provider, req
+}
+
+func authenticate(Auth *auth.Auth, provider *request_signing.Provider, signed *http.Request) error {
+	req := httptest.NewRequest(signed.Method, signed.URL.String(), nil)
+	req.Header = signed.Header.Clone()
+	_, err := provider.Authenticate(&auth.Context{Auth: Auth, Request: req})
+	return err
+}
+
+func TestConcurrentReplayedRequests(t *testing.T) {
+	Auth, provider, req := setup(t, nil)
+
+	var (
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		accepted int
+		replayed int
+	)
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := authenticate(Auth, provider, req)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			switch err {
+			case nil:
+				accepted++
+			case request_signing.ErrReplayedRequest:
+				replayed++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if accepted != 1 || replayed != 19 {
+		t.Errorf("expect 1 accepted, 19 replayed requests, got %v accepted, %v replayed", accepted, replayed)
+	}
+}
+
+func TestRejectRequestsIfNonceCouldNotBeSaved(t *testing.T) {
+	Auth, provider, req := setup(t, &request_signing.Config{Cache: unavailableCache{}})
+
+	if err := authenticate(Auth, provider, req); err != errUnavailable {
+		t.Errorf("expect request rejected with cache error, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ReturnToCookieName cookie saves validated `return_to` URL during login flows, so it survives redirects to identity providers
+const ReturnToCookieName = "_auth_return_to"
+
+// returnToExpiry how long saved `return_to` URL is kept, login flows taking longer fall back to Redirector
+const returnToExpiry = 30 * time.Minute
+
+// IsLocalPath check pth is a path of current site, like `/orders?page=2`, URLs like `//evil.com`, `/\evil.com` are not
+func IsLocalPath(pth string) bool {
+	if !strings.HasPrefix(pth, "/") || strings.HasPrefix(pth, "//") || strings.HasPrefix(pth, "/\\") {
+		return false
+	}
+	return !strings.ContainsAny(pth, "\r\n\t")
+}
+
+// IsSafeReturnTo check users could be redirected to returnTo after logged in, it should be a local path, a URL of request's host, or a http(s) URL whose host is in Config.ReturnToHosts
+func (auth *Auth) IsSafeReturnTo(req *http.Request, returnTo string) bool {
+	if IsLocalPath(returnTo) {
+		return true
+	}
+
+	u, err := url.Parse(returnTo)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.User != nil || u.Host == "" || strings.ContainsAny(returnTo, "\\\r\n\t") {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if strings.EqualFold(u.Host, req.Host) {
+		return true
+	}
+
+	for _, allowed := range auth.Config.ReturnToHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReturnTo get request's validated `return_to` URL, from form value `return_to`, or cookie saved by SaveReturnTo, returns blank string if it's missing or unsafe
+func (context Context) ReturnTo() string {
+	if returnTo := context.Request.FormValue("return_to"); returnTo != "" && context.Auth.IsSafeReturnTo(context.Request, returnTo) {
+		return returnTo
+	}
+
+	if cookie, err := context.Auth.GetCookie(context.Request, ReturnToCookieName); err == nil {
+		if returnTo, err := url.QueryUnescape(cookie.Value); err == nil && context.Auth.IsSafeReturnTo(context.Request, returnTo) {
+			return returnTo
+		}
+	}
+	return ""
+}
+
+// SaveReturnTo save request's validated `return_to` URL into cookie, users will be redirected to it after logged in, unsafe URLs are ignored
+func (context Context) SaveReturnTo() {
+	returnTo := context.Request.URL.Query().Get("return_to")
+	if returnTo == "" || !context.Auth.IsSafeReturnTo(context.Request, returnTo) {
+		return
+	}
+
+	context.Auth.SetCookie(context.Writer, context.Request, &http.Cookie{
+		Name:     ReturnToCookieName,
+		Value:    url.QueryEscape(returnTo),
+		Path:     "/",
+		Expires:  context.Auth.Now().Add(returnToExpiry),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearReturnTo delete cookie saved by SaveReturnTo
+func (context Context) clearReturnTo() {
+	if _, err := context.Auth.GetCookie(context.Request, ReturnToCookieName); err == nil {
+		context.Auth.DeleteCookie(context.Writer, context.Request, ReturnToCookieName)
+	}
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/qor/auth/cache"
+	"github.com/qor/auth/user_role"
+)
+
+// RoleStorerInterface role storer interface, defined how to get/save user's roles
+type RoleStorerInterface interface {
+	Get(userID string, context *Context) (roles []string, err error)
+	Add(userID string, role string, context *Context) error
+	Remove(userID string, role string, context *Context) error
+}
+
+// RoleStorer default role storer, save roles into database with model user_role.UserRole
+type RoleStorer struct {
+}
+
+var _ RoleStorerInterface = RoleStorer{}
+
+// Get get user's roles
+func (RoleStorer) Get(userID string, context *Context) (roles []string, err error) {
+	var tx = context.Auth.GetReadDB(context.Request)
+	err = tx.Model(&user_role.UserRole{}).Where("user_id = ?", userID).Pluck("role", &roles).Error
+	return
+}
+
+// Add grant role to user
+func (RoleStorer) Add(userID string, role string, context *Context) error {
+	var tx = context.Auth.GetDB(context.Request)
+	return tx.Where(user_role.UserRole{UserID: userID, Role: role}).FirstOrCreate(&user_role.UserRole{}).Error
+}
+
+// Remove revoke role from user
+func (RoleStorer) Remove(userID string, role string, context *Context) error {
+	var tx = context.Auth.GetDB(context.Request)
+	return tx.Where("user_id = ? AND role = ?", userID, role).Delete(&user_role.UserRole{}).Error
+}
+
+// GetCurrentRoles get current user's roles from request
+func (auth *Auth) GetCurrentRoles(req *http.Request) []string {
+	claims, err := auth.SessionStorer.Get(req)
+	if err == nil {
+		context := &Context{Auth: auth, Claims: claims, Request: req}
+		if roles, err := auth.RoleStorer.Get(claims.GetUserID(), context); err == nil {
+			return roles
+		}
+	}
+	return nil
+}
+
+// DefaultRolesCacheTTL default ttl of cached roles, roles changed without the storer, like in another service, are picked up after it
+const DefaultRolesCacheTTL = 10 * time.Minute
+
+// NewCachedRoleStorer initialize a role storer that caches roles get from storer, roles are cached for ttl, a zero ttl means DefaultRolesCacheTTL
+func NewCachedRoleStorer(storer RoleStorerInterface, cacheStore cache.Interface, ttl time.Duration) *CachedRoleStorer {
+	return &CachedRoleStorer{RoleStorerInterface: storer, Cache: cacheStore, TTL: ttl}
+}
+
+// CachedRoleStorer role storer caches roles, so roles won't be loaded from database for every request, cache is invalidated when roles changed with it, for multiple instances, use a shared cache store like redis
+type CachedRoleStorer struct {
+	RoleStorerInterface
+	Cache cache.Interface
+	// TTL ttl of cached roles, cached roles always expire, a zero value means DefaultRolesCacheTTL
+	TTL time.Duration
+}
+
+var _ RoleStorerInterface = &CachedRoleStorer{}
+
+// Get get user's roles from cache, load them from storer with primary DB if not cached, so replication lag won't be cached,
+// requests in a transaction bypass the cache, so uncommitted roles are neither cached nor hidden by cached ones
+func (storer *CachedRoleStorer) Get(userID string, context *Context) (roles []string, err error) {
+	if context.Request != nil && context.Request.Context().Value(transactionKey{}) != nil {
+		return storer.RoleStorerInterface.Get(userID, context)
+	}
+
+	if err = storer.Cache.Get(rolesCacheKey(userID), &roles); err == nil {
+		return roles, nil
+	}
+
+	primary := *context
+	if primary.Request != nil {
+		primary.Request = UsePrimaryDB(primary.Request)
+	}
+
+	if roles, err = storer.RoleStorerInterface.Get(userID, &primary); err == nil {
+		storer.Cache.Set(rolesCacheKey(userID), roles, storer.ttl())
+	}
+	return roles, err
+}
+
+// Add grant role to user, and invalidate its cached roles after the change is committed
+func (storer *CachedRoleStorer) Add(userID string, role string, context *Context) error {
+	defer storer.invalidateAfterCommit(context, userID)
+	return storer.RoleStorerInterface.Add(userID, role, context)
+}
+
+// Remove revoke role from user, and invalidate its cached roles after the change is committed
+func (storer *CachedRoleStorer) Remove(userID string, role string, context *Context) error {
+	defer storer.invalidateAfterCommit(context, userID)
+	return storer.RoleStorerInterface.Remove(userID, role, context)
+}
+
+// invalidateAfterCommit roles refilled by other requests before commit are the old ones, so invalidate them after the transaction is committed
+func (storer *CachedRoleStorer) invalidateAfterCommit(context *Context, userID string) {
+	if context.Request == nil {
+		storer.Invalidate(userID)
+		return
+	}
+	AfterCommit(context.Request, func() { storer.Invalidate(userID) })
+}
+
+func (storer *CachedRoleStorer) ttl() time.Duration {
+	if storer.TTL <= 0 {
+		return DefaultRolesCacheTTL
+	}
+	return storer.TTL
+}
+
+// Invalidate invalidate user's cached roles, call it if roles changed without the storer
+func (storer *CachedRoleStorer) Invalidate(userIDs ...string) error {
+	keys := make([]string, len(userIDs))
+	for idx, userID := range userIDs {
+		keys[idx] = rolesCacheKey(userID)
+	}
+	return storer.Cache.Delete(keys...)
+}
+
+func rolesCacheKey(userID string) string {
+	return "auth:roles:" + userID
+}
@@ -0,0 +1,78 @@
+package auth_test
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/qor/auth"
+	"github.com/qor/auth/cache/memory"
+)
+
+func TestCachedRolesInvalidatedAfterCommit(t *testing.T) {
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "auth.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	storer := auth.NewCachedRoleStorer(auth.RoleStorer{}, memory.New(), 0)
+	Auth := auth.New(&auth.Config{DB: db, Redirector: redirector{}, RoleStorer: storer})
+	if err := auth.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		context = &auth.Context{Auth: Auth, Request: httptest.NewRequest("POST", "/", nil)}
+		other   = &auth.Context{Auth: Auth, Request: httptest.NewRequest("GET", "/", nil)}
+	)
+
+	if err := Auth.Transaction(context, func(context *auth.Context) error {
+		if err := storer.Add("1", "admin", context); err != nil {
+			return err
+		}
+
+		// another request refills the cache before commit, with the committed roles
+		if roles, err := storer.Get("1", other); err != nil || len(roles) != 0 {
+			t.Errorf("expect no committed roles before commit, got %v, %v", roles, err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if roles, err := storer.Get("1", other); err != nil || !reflect.DeepEqual(roles, []string{"admin"}) {
+		t.Errorf("expect granted role after commit, got %v, %v", roles, err)
+	}
+}
+
+func TestCachedRolesRefilledFromPrimaryDB(t *testing.T) {
+	var dbs []*gorm.DB
+	for _, name := range []string{"primary.db", "replica.db"} {
+		db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		if err := auth.Migrate(db); err != nil {
+			t.Fatal(err)
+		}
+		dbs = append(dbs, db)
+	}
+
+	// the replica hasn't received the granted role yet
+	storer := auth.NewCachedRoleStorer(auth.RoleStorer{}, memory.New(), 0)
+	Auth := auth.New(&auth.Config{DB: dbs[0], ReplicaDB: dbs[1], Redirector: redirector{}, RoleStorer: storer})
+	context := &auth.Context{Auth: Auth, Request: httptest.NewRequest("GET", "/", nil)}
+
+	if err := storer.Add("1", "admin", context); err != nil {
+		t.Fatal(err)
+	}
+
+	if roles, err := storer.Get("1", context); err != nil || !reflect.DeepEqual(roles, []string{"admin"}) {
+		t.Errorf("expect roles refilled from primary DB, got %v, %v", roles, err)
+	}
+}
@@ -0,0 +1,364 @@
+// Package saml_idp SAML identity provider, let legacy enterprise service providers SSO against accounts managed by auth,
+// assertions are signed with configured key, attribute statements are read from user model
+package saml_idp
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	"github.com/qor/auth"
+	"github.com/qor/qor/utils"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+// EventAssertionIssued assertion issued to a service provider
+const EventAssertionIssued = "saml_idp.assertion_issued"
+
+// postBindingCSP allow inline scripts of crewjam/saml's auto-submitted HTTP-POST binding form with their hashes
+const postBindingCSP = "default-src 'none'; script-src 'sha256-brNTulgsxV2wMk5ozs/cma8EvBZY10/LIgBE56oln/Q=' 'sha256-0KSuy0EAVzpXke64lXJG5GP5RL+7sw85r01dtYDpeeI='; frame-ancestors 'none'"
+
+// Config SAML identity provider config
+type Config struct {
+	// BaseURL your application's URL, like `https://example.com`, used to generate entity ID, SSO URL
+	BaseURL string
+	// Key, Certificate key pair signing assertions, Certificate is published in metadata
+	Key         crypto.Signer
+	Certificate *x509.Certificate
+	// ServiceProviders metadata XML of service providers allowed to SSO, more could be added with AddServiceProvider
+	ServiceProviders [][]byte
+	// LoginURL users haven't logged in are redirected to it with query `return_to`, default value is `{Auth Prefix}/login`
+	LoginURL string
+	// SessionDuration valid duration of issued assertions' sessions, default value is 8 hours
+	SessionDuration time.Duration
+	// Attributes add custom attributes to assertions for user, `uid`, `mail`, `cn`, `givenName`, `sn`, `eduPersonAffiliation` (roles) are added by default
+	Attributes func(context *auth.Context, user interface{}, serviceProvider *saml.EntityDescriptor) []saml.Attribute
+}
+
+// New initialize SAML identity provider, panics if config is invalid, use NewWithError to handle the error
+func New(config *Config) *Provider {
+	provider, err := NewWithError(config)
+	if err != nil {
+		panic(err)
+	}
+	return provider
+}
+
+// NewWithError initialize SAML identity provider, returns a *auth.ConfigError lists all problems if config is invalid
+func NewWithError(config *Config) (*Provider, error) {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if config.SessionDuration == 0 {
+		config.SessionDuration = 8 * time.Hour
+	}
+
+	provider := &Provider{Config: config, serviceProviders: map[string]*saml.EntityDescriptor{}}
+	for _, metadata := range config.ServiceProviders {
+		if err := provider.AddServiceProvider(metadata); err != nil {
+			return nil, err
+		}
+	}
+	return provider, nil
+}
+
+// Validate check config, returns a *auth.ConfigError lists all problems, or nil if config is valid
+func (config *Config) Validate() error {
+	configErr := &auth.ConfigError{Name: "saml_idp"}
+
+	if config.Key == nil {
+		configErr.Add("Key must be specified")
+	}
+
+	if config.Certificate == nil {
+		configErr.Add("Certificate must be specified")
+	}
+
+	if baseURL, err := url.Parse(config.BaseURL); err != nil || baseURL.Scheme == "" || baseURL.Host == "" {
+		configErr.Add("BaseURL %q should be an absolute URL, like https://example.com", config.BaseURL)
+	}
+
+	for idx, metadata := range config.ServiceProviders {
+		if _, err := samlsp.ParseMetadata(metadata); err != nil {
+			configErr.Add("ServiceProviders[%v] has invalid metadata: %v", idx, err)
+		}
+	}
+	return configErr.Err()
+}
+
+// Provider SAML identity provider, register it as a provider
+//
+//	GET      {Auth Prefix}/saml/metadata                     identity provider's metadata
+//	GET/POST {Auth Prefix}/saml/sso                          SSO endpoint, supports HTTP-Redirect, HTTP-POST bindings
+//	GET      {Auth Prefix}/saml/idp_initiated?sp={entity ID}  IdP-initiated SSO to service provider
+type Provider struct {
+	*Config
+	Auth             *auth.Auth
+	IdentityProvider *saml.IdentityProvider
+
+	mutex            sync.RWMutex
+	serviceProviders map[string]*saml.EntityDescriptor
+}
+
+// GetName return provider name
+func (*Provider) GetName() string {
+	return "saml"
+}
+
+// ValidateAuth validate config again when registered, as it could be changed after initialized
+func (provider *Provider) ValidateAuth(Auth *auth.Auth) error {
+	return provider.Config.Validate()
+}
+
+// ConfigAuth config auth
+func (provider *Provider) ConfigAuth(Auth *auth.Auth) {
+	provider.Auth = Auth
+
+	if provider.Config.LoginURL == "" {
+		provider.Config.LoginURL = Auth.AuthURL("login")
+	}
+
+	// BaseURL has been checked by ValidateAuth
+	baseURL, _ := url.Parse(strings.TrimSuffix(provider.BaseURL, "/"))
+
+	metadataURL, ssoURL := *baseURL, *baseURL
+	metadataURL.Path += Auth.AuthURL("saml/metadata")
+	ssoURL.Path += Auth.AuthURL("saml/sso")
+
+	provider.IdentityProvider = &saml.IdentityProvider{
+		Key:                     provider.Key,
+		Signer:                  provider.Key,
+		Certificate:             provider.Certificate,
+		MetadataURL:             metadataURL,
+		SSOURL:                  ssoURL,
+		ServiceProviderProvider: serviceProviderProvider{provider},
+		SessionProvider:         sessionProvider{provider},
+		AssertionMaker:          assertionMaker{provider},
+		SignatureMethod:         signatureMethod(provider.Key),
+	}
+}
+
+// AddServiceProvider allow service provider with its metadata XML to SSO
+func (provider *Provider) AddServiceProvider(metadata []byte) error {
+	descriptor, err := samlsp.ParseMetadata(metadata)
+	if err != nil {
+		return err
+	}
+
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	provider.serviceProviders[descriptor.EntityID] = descriptor
+	return nil
+}
+
+// RemoveServiceProvider remove service provider with its entity ID
+func (provider *Provider) RemoveServiceProvider(entityID string) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	delete(provider.serviceProviders, entityID)
+}
+
+// Login SAML identity provider doesn't support login
+func (provider *Provider) Login(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Logout SAML identity provider doesn't support logout
+func (provider *Provider) Logout(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Register SAML identity provider doesn't support register
+func (provider *Provider) Register(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Deregister SAML identity provider doesn't support deregister
+func (provider *Provider) Deregister(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Callback SAML identity provider doesn't support callback
+func (provider *Provider) Callback(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// ServeHTTP serve metadata, SSO endpoints
+func (provider *Provider) ServeHTTP(context *auth.Context) {
+	var (
+		req     = context.Request
+		w       = context.Writer
+		reqPath = strings.TrimPrefix(req.URL.Path, context.Auth.URLPrefix)
+		paths   = strings.Split(reqPath, "/")
+	)
+
+	// responses are posted to service providers with auto-submitted forms
+	if (paths[1] == "sso" || paths[1] == "idp_initiated") && w.Header().Get("Content-Security-Policy") != "" {
+		w.Header().Set("Content-Security-Policy", postBindingCSP)
+	}
+
+	switch paths[1] {
+	case "metadata":
+		provider.IdentityProvider.ServeMetadata(w, req)
+	case "sso":
+		provider.IdentityProvider.ServeSSO(w, req)
+	case "idp_initiated":
+		entityID := req.URL.Query().Get("sp")
+		if _, err := (serviceProviderProvider{provider}).GetServiceProvider(req, entityID); err != nil {
+			http.NotFound(w, req)
+			return
+		}
+		provider.IdentityProvider.ServeIDPInitiated(w, req, entityID, req.URL.Query().Get("RelayState"))
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// CSRFExempt SSO endpoint receives authn requests posted by service providers with HTTP-POST binding
+func (provider *Provider) CSRFExempt(req *http.Request) bool {
+	return strings.Split(strings.TrimPrefix(req.URL.Path, provider.Auth.URLPrefix), "/")[1] == "sso"
+}
+
+type serviceProviderProvider struct {
+	*Provider
+}
+
+// GetServiceProvider get registered service provider's metadata
+func (provider serviceProviderProvider) GetServiceProvider(req *http.Request, entityID string) (*saml.EntityDescriptor, error) {
+	provider.mutex.RLock()
+	defer provider.mutex.RUnlock()
+
+	if descriptor, ok := provider.serviceProviders[entityID]; ok {
+		return descriptor, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+type sessionProvider struct {
+	*Provider
+}
+
+// GetSession get session of current user, redirect to login page if not logged in, the SAML request is kept in return URL, so SSO continues after logged in
+func (provider sessionProvider) GetSession(w http.ResponseWriter, req *http.Request, authnRequest *saml.IdpAuthnRequest) *saml.Session {
+	context := &auth.Context{Auth: provider.Auth, Request: req, Writer: w}
+
+	claims, err := provider.Auth.SessionStorer.Get(req)
+	if err != nil {
+		returnTo := req.URL.RequestURI()
+		if authnRequest.RequestBuffer != nil {
+			returnTo = provider.Auth.AuthURL("saml/sso") + "?" + redirectBindingQuery(authnRequest).Encode()
+		}
+
+		loginURL := url.URL{Path: provider.LoginURL, RawQuery: url.Values{"return_to": []string{returnTo}}.Encode()}
+		http.Redirect(w, req, loginURL.String(), http.StatusSeeOther)
+		return nil
+	}
+	context.Claims = claims
+
+	user, err := provider.Auth.UserStorer.Get(claims, context)
+	if err != nil {
+		http.Error(w, context.TranslateError(auth.ErrInvalidAccount), http.StatusForbidden)
+		return nil
+	}
+
+	sessionID, err := provider.Auth.GenerateToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil
+	}
+
+	now := provider.Auth.Now()
+	session := &saml.Session{
+		ID:         sessionID,
+		CreateTime: now,
+		ExpireTime: now.Add(provider.SessionDuration),
+		Index:      claims.SessionID,
+		UserName:   claims.GetUserID(),
+		UserEmail:  provider.Auth.GetEmail(context, claims),
+	}
+
+	if session.Index == "" {
+		session.Index = session.ID
+	}
+
+	value := utils.Indirect(reflect.ValueOf(user))
+	session.UserCommonName = stringField(value, "Name")
+	session.UserGivenName = stringField(value, "FirstName")
+	session.UserSurname = stringField(value, "LastName")
+
+	if roles, err := provider.Auth.RoleStorer.Get(claims.GetUserID(), context); err == nil {
+		session.Groups = roles
+	}
+
+	if provider.Attributes != nil {
+		session.CustomAttributes = provider.Attributes(context, user, authnRequest.ServiceProviderMetadata)
+	}
+
+	data := map[string]interface{}{"user_id": claims.GetUserID()}
+	if authnRequest.ServiceProviderMetadata != nil {
+		data["service_provider"] = authnRequest.ServiceProviderMetadata.EntityID
+	}
+	provider.Auth.Publish(EventAssertionIssued, context, data)
+	return session
+}
+
+type assertionMaker struct {
+	*Provider
+}
+
+// MakeAssertion make assertion with crewjam/saml's default assertion maker, use email as NameID if exists
+func (maker assertionMaker) MakeAssertion(req *saml.IdpAuthnRequest, session *saml.Session) error {
+	if session.UserEmail != "" {
+		session.NameID, session.NameIDFormat = session.UserEmail, string(saml.EmailAddressNameIDFormat)
+	} else {
+		session.NameID, session.NameIDFormat = session.UserName, string(saml.PersistentNameIDFormat)
+	}
+	return saml.DefaultAssertionMaker{}.MakeAssertion(req, session)
+}
+
+// redirectBindingQuery encode SAML request with HTTP-Redirect binding
+func redirectBindingQuery(req *saml.IdpAuthnRequest) url.Values {
+	var buf bytes.Buffer
+	writer, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	writer.Write(req.RequestBuffer)
+	writer.Close()
+
+	query := url.Values{"SAMLRequest": []string{base64.StdEncoding.EncodeToString(buf.Bytes())}}
+	if req.RelayState != "" {
+		query.Set("RelayState", req.RelayState)
+	}
+	return query
+}
+
+func signatureMethod(key crypto.Signer) string {
+	if _, ok := key.Public().(*ecdsa.PublicKey); ok {
+		return dsig.ECDSASHA256SignatureMethod
+	}
+	return dsig.RSASHA256SignatureMethod
+}
+
+func stringField(value reflect.Value, name string) string {
+	if value.Kind() == reflect.Struct {
+		if field := value.FieldByName(name); field.IsValid() && field.Kind() == reflect.String {
+			return field.String()
+		}
+	}
+	return ""
+}
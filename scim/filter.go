@@ -0,0 +1,242 @@
+package scim
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// filter compiled filter, a SQL condition with its arguments
+type filter struct {
+	sql  string
+	args []interface{}
+}
+
+// column resolve attribute path to column, returns false if the attribute isn't filterable
+type columnResolver func(attribute string) (column string, ok bool)
+
+// parseFilter parse SCIM filter like `userName eq "bjensen" and (active eq true or emails.value co "@example.com")` into SQL condition,
+// supports operators `eq`, `ne`, `co`, `sw`, `ew`, `gt`, `ge`, `lt`, `le`, `pr`, logical operators `and`, `or`, `not`, and parentheses,
+// string comparisons are case insensitive
+func parseFilter(value string, resolve columnResolver) (*filter, error) {
+	tokens, err := tokenizeFilter(value)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &filterParser{tokens: tokens, resolve: resolve}
+	result, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if parser.pos != len(parser.tokens) {
+		return nil, ErrInvalidFilter
+	}
+	return result, nil
+}
+
+type filterToken struct {
+	value  string
+	quoted bool
+}
+
+func tokenizeFilter(value string) ([]filterToken, error) {
+	var (
+		tokens []filterToken
+		runes  = []rune(value)
+	)
+
+	for idx := 0; idx < len(runes); {
+		switch r := runes[idx]; {
+		case unicode.IsSpace(r):
+			idx++
+		case r == '(' || r == ')':
+			tokens = append(tokens, filterToken{value: string(r)})
+			idx++
+		case r == '"':
+			var (
+				str     strings.Builder
+				escaped bool
+				closed  bool
+			)
+
+			for idx++; idx < len(runes); idx++ {
+				if escaped {
+					str.WriteRune(runes[idx])
+					escaped = false
+				} else if runes[idx] == '\\' {
+					escaped = true
+				} else if runes[idx] == '"' {
+					closed = true
+					idx++
+					break
+				} else {
+					str.WriteRune(runes[idx])
+				}
+			}
+
+			if !closed {
+				return nil, ErrInvalidFilter
+			}
+			tokens = append(tokens, filterToken{value: str.String(), quoted: true})
+		default:
+			start := idx
+			for idx < len(runes) && !unicode.IsSpace(runes[idx]) && runes[idx] != '(' && runes[idx] != ')' {
+				idx++
+			}
+			tokens = append(tokens, filterToken{value: string(runes[start:idx])})
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens  []filterToken
+	pos     int
+	resolve columnResolver
+}
+
+func (parser *filterParser) peek(keyword string) bool {
+	return parser.pos < len(parser.tokens) && !parser.tokens[parser.pos].quoted && strings.EqualFold(parser.tokens[parser.pos].value, keyword)
+}
+
+func (parser *filterParser) next() (filterToken, bool) {
+	if parser.pos >= len(parser.tokens) {
+		return filterToken{}, false
+	}
+	parser.pos++
+	return parser.tokens[parser.pos-1], true
+}
+
+func (parser *filterParser) parseOr() (*filter, error) {
+	return parser.parseLogical("or", parser.parseAnd)
+}
+
+func (parser *filterParser) parseAnd() (*filter, error) {
+	return parser.parseLogical("and", parser.parseUnary)
+}
+
+func (parser *filterParser) parseLogical(operator string, parseOperand func() (*filter, error)) (*filter, error) {
+	left, err := parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	for parser.peek(operator) {
+		parser.pos++
+		right, err := parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		left = &filter{sql: "(" + left.sql + " " + strings.ToUpper(operator) + " " + right.sql + ")", args: append(left.args, right.args...)}
+	}
+	return left, nil
+}
+
+func (parser *filterParser) parseUnary() (*filter, error) {
+	if parser.peek("not") {
+		parser.pos++
+		operand, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &filter{sql: "NOT " + operand.sql, args: operand.args}, nil
+	}
+
+	if parser.peek("(") {
+		parser.pos++
+		result, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if !parser.peek(")") {
+			return nil, ErrInvalidFilter
+		}
+		parser.pos++
+		return &filter{sql: "(" + result.sql + ")", args: result.args}, nil
+	}
+
+	return parser.parseComparison()
+}
+
+func (parser *filterParser) parseComparison() (*filter, error) {
+	attribute, ok := parser.next()
+	if !ok || attribute.quoted {
+		return nil, ErrInvalidFilter
+	}
+
+	column, ok := parser.resolve(strings.ToLower(attribute.value))
+	if !ok {
+		return nil, ErrInvalidFilter
+	}
+
+	operator, ok := parser.next()
+	if !ok || operator.quoted {
+		return nil, ErrInvalidFilter
+	}
+
+	op := strings.ToLower(operator.value)
+	if op == "pr" {
+		return &filter{sql: "(" + column + " IS NOT NULL AND " + column + " <> '')"}, nil
+	}
+
+	token, ok := parser.next()
+	if !ok {
+		return nil, ErrInvalidFilter
+	}
+
+	var value interface{} = token.value
+	if !token.quoted {
+		switch strings.ToLower(token.value) {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		default:
+			number, err := strconv.ParseFloat(token.value, 64)
+			if err != nil {
+				return nil, ErrInvalidFilter
+			}
+			value = number
+		}
+	}
+
+	str, isString := value.(string)
+	if isString {
+		column, str = "LOWER("+column+")", strings.ToLower(str)
+		value = str
+	}
+
+	like := func(pattern string) (*filter, error) {
+		if !isString {
+			return nil, ErrInvalidFilter
+		}
+		// `!` is the escape character, as backslash is an escape character of MySQL's strings already
+		replacer := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+		return &filter{sql: column + " LIKE ? ESCAPE '!'", args: []interface{}{strings.Replace(pattern, "*", replacer.Replace(str), 1)}}, nil
+	}
+
+	switch op {
+	case "eq":
+		return &filter{sql: column + " = ?", args: []interface{}{value}}, nil
+	case "ne":
+		return &filter{sql: column + " <> ?", args: []interface{}{value}}, nil
+	case "co":
+		return like("%*%")
+	case "sw":
+		return like("*%")
+	case "ew":
+		return like("%*")
+	case "gt":
+		return &filter{sql: column + " > ?", args: []interface{}{value}}, nil
+	case "ge":
+		return &filter{sql: column + " >= ?", args: []interface{}{value}}, nil
+	case "lt":
+		return &filter{sql: column + " < ?", args: []interface{}{value}}, nil
+	case "le":
+		return &filter{sql: column + " <= ?", args: []interface{}{value}}, nil
+	}
+	return nil, ErrInvalidFilter
+}
@@ -0,0 +1,123 @@
+package scim_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/qor/auth"
+	"github.com/qor/auth/scim"
+)
+
+type redirector struct{}
+
+func (redirector) Redirect(w http.ResponseWriter, req *http.Request, action string) {}
+
+type user struct {
+	gorm.Model
+	Name  string
+	Email string
+}
+
+func setup(t *testing.T) (*scim.Provider, *auth.Context) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	Auth := auth.New(&auth.Config{DB: db, Redirector: redirector{}, UserModel: &user{}, TrackSessions: true})
+	provider := scim.New(&scim.Config{Token: "token"})
+	Auth.RegisterProvider(provider)
+	if err := auth.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	db.AutoMigrate(&user{})
+
+	return provider, &auth.Context{Auth: Auth, Request: httptest.NewRequest("POST", "/", nil)}
+}
+
+func TestFilterUsers(t *testing.T) {
+	provider, context := setup(t)
+
+	active, inactive := true, false
+	for _, resource := range []scim.UserResource{
+		{UserName: "bjensen", Emails: []scim.Email{{Value: "bjensen@example.com"}}, Active: &active},
+		{UserName: "jsmith", Emails: []scim.Email{{Value: "jsmith@example.org"}}, Active: &inactive},
+		{UserName: "100%_off", Active: &active},
+		{UserName: "100abcoff", Active: &active},
+		{UserName: `say "hi"!`, Active: &active},
+	} {
+		if _, err := provider.CreateUser(context, &resource); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{`userName eq "bjensen"`, []string{"bjensen"}},
+		{`userName eq "BJensen"`, []string{"bjensen"}},
+		{`userName ne "bjensen"`, []string{"100%_off", "100abcoff", "jsmith", `say "hi"!`}},
+		{`emails.value co "@example.com"`, []string{"bjensen"}},
+		{`emails co "example"`, []string{"bjensen", "jsmith"}},
+		{`userName sw "j"`, []string{"jsmith"}},
+		{`userName ew "off"`, []string{"100%_off", "100abcoff"}},
+		{`emails pr`, []string{"bjensen", "jsmith"}},
+		{`active eq false`, []string{"jsmith"}},
+		{`userName sw "b" and active eq true`, []string{"bjensen"}},
+		{`userName eq "bjensen" or userName eq "jsmith"`, []string{"bjensen", "jsmith"}},
+		{`active eq true and (emails co "example" or userName sw "100")`, []string{"100%_off", "100abcoff", "bjensen"}},
+		{`not (active eq true)`, []string{"jsmith"}},
+		{`userName eq "say \"hi\"!"`, []string{`say "hi"!`}},
+		// wildcards in values are matched literally
+		{`userName co "%_"`, []string{"100%_off"}},
+		{`userName sw "100%"`, []string{"100%_off"}},
+		{`userName co "_"`, []string{"100%_off"}},
+		{`userName ew "!"`, []string{`say "hi"!`}},
+		{`userName co "%"`, []string{"100%_off"}},
+	}
+
+	for _, test := range tests {
+		result, err := provider.ListUsers(context, test.filter, 1, 100)
+		if err != nil {
+			t.Errorf("filter %v: %v", test.filter, err)
+			continue
+		}
+
+		got := []string{}
+		for _, resource := range result.Resources {
+			got = append(got, resource.(*scim.UserResource).UserName)
+		}
+		sort.Strings(got)
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("filter %v: expect %v, got %v", test.filter, test.want, got)
+		}
+	}
+}
+
+func TestInvalidFilters(t *testing.T) {
+	provider, context := setup(t)
+
+	for _, filter := range []string{
+		`userName eq`,
+		`userName eq "bjensen`,
+		`password eq "secret"`,
+		`userName like "b"`,
+		`(userName eq "bjensen"`,
+		`userName eq "bjensen" and`,
+		`active co true`,
+		`"userName" eq "bjensen"`,
+	} {
+		if _, err := provider.ListUsers(context, filter, 1, 100); err != scim.ErrInvalidFilter {
+			t.Errorf("filter %v: expect ErrInvalidFilter, got %v", filter, err)
+		}
+	}
+}
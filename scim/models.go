@@ -0,0 +1,71 @@
+package scim
+
+import (
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+	"github.com/qor/auth"
+)
+
+func init() {
+	auth.RegisterTables("scim_users", "scim_groups", "scim_group_members")
+	auth.RegisterMigration(auth.Migration{ID: "scim/001_create_scim_users", Migrate: func(db *gorm.DB) error {
+		return db.AutoMigrate(&User{}, &Group{}, &GroupMember{}).Error
+	}})
+}
+
+const (
+	// EventUserProvisioned user provisioned by identity provider
+	EventUserProvisioned = "scim.user_provisioned"
+	// EventUserUpdated provisioned user updated by identity provider
+	EventUserUpdated = "scim.user_updated"
+	// EventUserDeprovisioned provisioned user deactivated or deleted by identity provider, subscribe it to remove user's data if needed
+	EventUserDeprovisioned = "scim.user_deprovisioned"
+)
+
+// User provisioned user, linked to user of Auth's UserModel with UserID, deleted users are soft deleted, so they couldn't login anymore
+type User struct {
+	gorm.Model
+	UserID      string `gorm:"unique_index"`
+	UserName    string `gorm:"unique_index"`
+	ExternalID  string `gorm:"index"`
+	DisplayName string
+	GivenName   string
+	FamilyName  string
+	Email       string `gorm:"index"`
+	Active      bool
+}
+
+// TableName table name of provisioned users
+func (User) TableName() string {
+	return auth.TableName("scim_users")
+}
+
+// Group provisioned group, members are saved with GroupMember
+type Group struct {
+	gorm.Model
+	DisplayName string `gorm:"unique_index"`
+	ExternalID  string `gorm:"index"`
+}
+
+// TableName table name of provisioned groups
+func (Group) TableName() string {
+	return auth.TableName("scim_groups")
+}
+
+// GetID get group's ID as string, which is the SCIM resource ID
+func (group Group) GetID() string {
+	return fmt.Sprint(group.ID)
+}
+
+// GroupMember member of provisioned group
+type GroupMember struct {
+	gorm.Model
+	GroupID uint   `gorm:"unique_index:uix_scim_group_members_group_id_user_id"`
+	UserID  string `gorm:"unique_index:uix_scim_group_members_group_id_user_id"`
+}
+
+// TableName table name of group members
+func (GroupMember) TableName() string {
+	return auth.TableName("scim_group_members")
+}
@@ -0,0 +1,225 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// applyPatch apply patch operations to resource, the resource is converted to JSON object, patched, then converted back,
+// paths like `active`, `name.givenName`, `emails[type eq "work"].value`, `members[value eq "2819c223"]` are supported,
+// attribute names, operation names are case insensitive
+func applyPatch(resource interface{}, operations []PatchOperation) error {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return err
+	}
+
+	object := map[string]interface{}{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+
+	for _, operation := range operations {
+		if err := patchObject(object, operation); err != nil {
+			return err
+		}
+	}
+
+	if data, err = json.Marshal(object); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, resource)
+}
+
+// patchPath parsed path, `attribute[filterAttribute eq "filterValue"].subAttribute`
+type patchPath struct {
+	attribute       string
+	filterAttribute string
+	filterValue     string
+	subAttribute    string
+}
+
+func parsePatchPath(value string) (path patchPath, err error) {
+	// strip schema URN prefix, like `urn:ietf:params:scim:schemas:core:2.0:User:name.givenName`
+	if strings.HasPrefix(strings.ToLower(value), "urn:") {
+		value = value[strings.LastIndex(value, ":")+1:]
+	}
+
+	if start := strings.Index(value, "["); start >= 0 {
+		end := strings.Index(value, "]")
+		if end < start {
+			return path, ErrInvalidPath
+		}
+
+		fields := strings.SplitN(strings.TrimSpace(value[start+1:end]), " ", 3)
+		if len(fields) != 3 || !strings.EqualFold(fields[1], "eq") {
+			return path, ErrInvalidPath
+		}
+
+		path.attribute = value[:start]
+		path.filterAttribute = fields[0]
+		path.filterValue = strings.Trim(fields[2], `"`)
+		path.subAttribute = strings.TrimPrefix(value[end+1:], ".")
+		return path, nil
+	}
+
+	parts := strings.SplitN(value, ".", 2)
+	path.attribute = parts[0]
+	if len(parts) == 2 {
+		path.subAttribute = parts[1]
+	}
+	return path, nil
+}
+
+func patchObject(object map[string]interface{}, operation PatchOperation) error {
+	op := strings.ToLower(operation.Op)
+	if op != "add" && op != "replace" && op != "remove" {
+		return &Error{Status: 400, ScimType: "invalidSyntax", Detail: fmt.Sprintf("unsupported op %v", operation.Op)}
+	}
+
+	if operation.Path == "" {
+		values, ok := operation.Value.(map[string]interface{})
+		if !ok || op == "remove" {
+			return ErrNoTarget
+		}
+
+		// attributes without path, like Azure AD's `{"op": "replace", "value": {"active": false, "name.givenName": "Barbara"}}`
+		for key, value := range values {
+			if err := patchObject(object, PatchOperation{Op: op, Path: key, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	path, err := parsePatchPath(operation.Path)
+	if err != nil {
+		return err
+	}
+
+	key := findKey(object, path.attribute)
+	if path.filterAttribute != "" {
+		var (
+			elements, _ = object[key].([]interface{})
+			results     []interface{}
+			matched     bool
+		)
+
+		for _, element := range elements {
+			item, ok := element.(map[string]interface{})
+			if !ok || !strings.EqualFold(fmt.Sprint(item[findKey(item, path.filterAttribute)]), path.filterValue) {
+				results = append(results, element)
+				continue
+			}
+			matched = true
+
+			switch {
+			case op == "remove" && path.subAttribute == "":
+				// remove matched element
+			case op == "remove":
+				delete(item, findKey(item, path.subAttribute))
+				results = append(results, item)
+			case path.subAttribute == "":
+				if value, ok := operation.Value.(map[string]interface{}); ok {
+					results = append(results, value)
+				}
+			default:
+				item[findKey(item, path.subAttribute)] = operation.Value
+				results = append(results, item)
+			}
+		}
+
+		// add the element if not found, like adding `emails[type eq "work"].value` to user without work email
+		if !matched && op != "remove" {
+			item := map[string]interface{}{path.filterAttribute: path.filterValue}
+			if path.subAttribute != "" {
+				item[path.subAttribute] = operation.Value
+			} else if value, ok := operation.Value.(map[string]interface{}); ok {
+				for k, v := range value {
+					item[k] = v
+				}
+			}
+			results = append(results, item)
+		}
+		object[key] = results
+		return nil
+	}
+
+	// some identity providers send booleans as strings, like `"active": "False"`
+	if value, ok := operation.Value.(string); ok && (strings.EqualFold(value, "true") || strings.EqualFold(value, "false")) && strings.EqualFold(path.attribute, "active") {
+		operation.Value = strings.EqualFold(value, "true")
+	}
+
+	if path.subAttribute != "" {
+		child, ok := object[key].(map[string]interface{})
+		if !ok {
+			if op == "remove" {
+				return nil
+			}
+			child = map[string]interface{}{}
+			object[key] = child
+		}
+		return patchObject(child, PatchOperation{Op: op, Path: path.subAttribute, Value: operation.Value})
+	}
+
+	existing, isMultiValued := object[key].([]interface{})
+	switch op {
+	case "remove":
+		values, ok := operation.Value.([]interface{})
+		if !ok || !isMultiValued {
+			delete(object, key)
+			return nil
+		}
+
+		// remove listed elements of multi-valued attribute, like `{"op": "remove", "path": "members", "value": [{"value": "2819c223"}]}`
+		var results []interface{}
+		for _, element := range existing {
+			if !containsValue(values, element) {
+				results = append(results, element)
+			}
+		}
+		object[key] = results
+	case "add":
+		if values, ok := operation.Value.([]interface{}); ok && (isMultiValued || object[key] == nil) {
+			for _, value := range values {
+				if !containsValue(existing, value) {
+					existing = append(existing, value)
+				}
+			}
+			object[key] = existing
+			return nil
+		}
+		object[key] = operation.Value
+	default:
+		object[key] = operation.Value
+	}
+	return nil
+}
+
+// findKey find key of object case insensitively, return name if not found
+func findKey(object map[string]interface{}, name string) string {
+	for key := range object {
+		if strings.EqualFold(key, name) {
+			return key
+		}
+	}
+	return name
+}
+
+// containsValue check elements contain value, elements are compared with their `value` attribute if they are objects
+func containsValue(elements []interface{}, value interface{}) bool {
+	valueOf := func(element interface{}) string {
+		if item, ok := element.(map[string]interface{}); ok {
+			return fmt.Sprint(item[findKey(item, "value")])
+		}
+		return fmt.Sprint(element)
+	}
+
+	for _, element := range elements {
+		if valueOf(element) == valueOf(value) {
+			return true
+		}
+	}
+	return false
+}
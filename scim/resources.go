@@ -0,0 +1,167 @@
+package scim
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// SchemaUser core user schema
+	SchemaUser = "urn:ietf:params:scim:schemas:core:2.0:User"
+	// SchemaGroup core group schema
+	SchemaGroup = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	// SchemaListResponse list response schema
+	SchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	// SchemaPatchOp patch operation schema
+	SchemaPatchOp = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	// SchemaError error schema
+	SchemaError = "urn:ietf:params:scim:api:messages:2.0:Error"
+	// SchemaServiceProviderConfig service provider config schema
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	// SchemaResourceType resource type schema
+	SchemaResourceType = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+)
+
+// UserResource SCIM user resource
+type UserResource struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	UserName    string   `json:"userName"`
+	Name        *Name    `json:"name,omitempty"`
+	DisplayName string   `json:"displayName,omitempty"`
+	Emails      []Email  `json:"emails,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+	Groups      []Member `json:"groups,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// Name user's name
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// Email user's email
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// GroupResource SCIM group resource
+type GroupResource struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	DisplayName string   `json:"displayName"`
+	Members     []Member `json:"members,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// Member group's member, or user's group
+type Member struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// Meta resource's metadata
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
+}
+
+// ListResponse SCIM list response
+type ListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+// PatchRequest SCIM patch request
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation SCIM patch operation, op is `add`, `replace` or `remove`
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// PrimaryEmail get user's primary email, or the first email if none is primary
+func (resource UserResource) PrimaryEmail() string {
+	for _, email := range resource.Emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+
+	if len(resource.Emails) > 0 {
+		return resource.Emails[0].Value
+	}
+	return ""
+}
+
+// apply copy resource's attributes to provisioned user
+func (resource UserResource) apply(user *User) {
+	user.UserName = resource.UserName
+	user.ExternalID = resource.ExternalID
+	user.DisplayName = resource.DisplayName
+	user.Email = resource.PrimaryEmail()
+	user.GivenName, user.FamilyName = "", ""
+	if resource.Name != nil {
+		user.GivenName, user.FamilyName = resource.Name.GivenName, resource.Name.FamilyName
+		if user.DisplayName == "" {
+			user.DisplayName = resource.Name.Formatted
+		}
+	}
+
+	if user.DisplayName == "" {
+		user.DisplayName = strings.TrimSpace(user.GivenName + " " + user.FamilyName)
+	}
+
+	user.Active = resource.Active == nil || *resource.Active
+}
+
+func toUserResource(user User, groups []Member, location string) UserResource {
+	active := user.Active
+	resource := UserResource{
+		Schemas:     []string{SchemaUser},
+		ID:          user.UserID,
+		ExternalID:  user.ExternalID,
+		UserName:    user.UserName,
+		DisplayName: user.DisplayName,
+		Active:      &active,
+		Groups:      groups,
+		Meta:        &Meta{ResourceType: "User", Created: user.CreatedAt, LastModified: user.UpdatedAt, Location: location},
+	}
+
+	if user.GivenName != "" || user.FamilyName != "" {
+		resource.Name = &Name{GivenName: user.GivenName, FamilyName: user.FamilyName, Formatted: strings.TrimSpace(user.GivenName + " " + user.FamilyName)}
+	}
+
+	if user.Email != "" {
+		resource.Emails = []Email{{Value: user.Email, Type: "work", Primary: true}}
+	}
+	return resource
+}
+
+func toGroupResource(group Group, members []Member, location string) GroupResource {
+	return GroupResource{
+		Schemas:     []string{SchemaGroup},
+		ID:          group.GetID(),
+		ExternalID:  group.ExternalID,
+		DisplayName: group.DisplayName,
+		Members:     members,
+		Meta:        &Meta{ResourceType: "Group", Created: group.CreatedAt, LastModified: group.UpdatedAt, Location: location},
+	}
+}
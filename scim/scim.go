@@ -0,0 +1,930 @@
+// Package scim SCIM 2.0 provisioning endpoints, let enterprise customers' identity providers, like Okta, Azure AD, provision and deprovision accounts automatically,
+// supports Users, Groups resources, filtering and PATCH
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/qor/auth"
+	"github.com/qor/auth/claims"
+	"github.com/qor/qor/utils"
+)
+
+var (
+	// ErrUnauthorized request isn't authenticated with valid token
+	ErrUnauthorized = &Error{Status: http.StatusUnauthorized, Detail: "unauthorized"}
+	// ErrNotFound resource not found
+	ErrNotFound = &Error{Status: http.StatusNotFound, Detail: "resource not found"}
+	// ErrUniqueness userName, displayName is used by another resource
+	ErrUniqueness = &Error{Status: http.StatusConflict, ScimType: "uniqueness", Detail: "resource already exists"}
+	// ErrInvalidFilter filter couldn't be parsed, or filtered attribute isn't supported
+	ErrInvalidFilter = &Error{Status: http.StatusBadRequest, ScimType: "invalidFilter", Detail: "invalid filter"}
+	// ErrInvalidPath patch path couldn't be parsed
+	ErrInvalidPath = &Error{Status: http.StatusBadRequest, ScimType: "invalidPath", Detail: "invalid path"}
+	// ErrNoTarget patch operation has no target
+	ErrNoTarget = &Error{Status: http.StatusBadRequest, ScimType: "noTarget", Detail: "no target"}
+	// ErrInvalidValue required attributes are missing, or attributes have invalid values
+	ErrInvalidValue = &Error{Status: http.StatusBadRequest, ScimType: "invalidValue", Detail: "invalid value"}
+)
+
+// Error SCIM error, responded as `{"schemas": [SchemaError], "status": "404", "scimType": ScimType, "detail": Detail}`
+type Error struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+func (err *Error) Error() string {
+	return err.Detail
+}
+
+// Config SCIM provider config
+type Config struct {
+	// Token bearer token identity providers use to call SCIM endpoints, compared in constant time
+	Token string
+	// Authenticate authenticate requests with other methods, like API keys, overwrites Token
+	Authenticate func(context *auth.Context) bool
+	// IdentityProvider link provisioned users to auth identities of the provider, with userName as UID, like `saml`, `google`,
+	// so users logged in with the provider get their provisioned accounts, instead of new accounts created at first login
+	IdentityProvider string
+	// Roles grant groups' display names as roles to their members, with Auth's RoleStorer
+	Roles bool
+	// BaseURL used in resources' meta.location, like `https://example.com`, locations are omitted if blank
+	BaseURL string
+	// MaxResults max resources returned in a list response, default value is 100
+	MaxResults int
+}
+
+// New initialize SCIM provider
+func New(config *Config) *Provider {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if config.MaxResults == 0 {
+		config.MaxResults = 100
+	}
+
+	return &Provider{Config: config}
+}
+
+// Provider SCIM provider, register it to serve SCIM endpoints under `{Auth Prefix}/scim/v2`,
+// deactivated, deleted users' sessions are revoked, and they couldn't login anymore
+//
+//	GET                {Auth Prefix}/scim/v2/ServiceProviderConfig
+//	GET                {Auth Prefix}/scim/v2/ResourceTypes
+//	GET, POST          {Auth Prefix}/scim/v2/Users         list users with `filter`, `startIndex`, `count`, or create user
+//	GET, PUT, PATCH    {Auth Prefix}/scim/v2/Users/{id}    get, replace, patch user
+//	DELETE             {Auth Prefix}/scim/v2/Users/{id}    deprovision user
+//	GET, POST          {Auth Prefix}/scim/v2/Groups        list groups with `filter`, `startIndex`, `count`, or create group
+//	GET, PUT, PATCH    {Auth Prefix}/scim/v2/Groups/{id}   get, replace, patch group
+//	DELETE             {Auth Prefix}/scim/v2/Groups/{id}   delete group
+type Provider struct {
+	*Config
+	Auth *auth.Auth
+}
+
+// GetName return provider name
+func (Provider) GetName() string {
+	return "scim"
+}
+
+// ValidateAuth check Auth's config, provisioned users are saved with UserModel, deprovisioned users' sessions are revoked with tracked sessions, so it requires UserModel, TrackSessions
+func (provider *Provider) ValidateAuth(Auth *auth.Auth) error {
+	configErr := &auth.ConfigError{Name: "scim"}
+	if Auth.Config.UserModel == nil {
+		configErr.Add("Auth's UserModel must be specified to provision users")
+	}
+
+	if !Auth.Config.TrackSessions {
+		configErr.Add("Auth's TrackSessions must be enabled to revoke deprovisioned users' sessions")
+	}
+	return configErr.Err()
+}
+
+// ConfigAuth config auth
+func (provider *Provider) ConfigAuth(Auth *auth.Auth) {
+	provider.Auth = Auth
+
+	// deprovisioned users couldn't login anymore
+	Auth.RegisterHook(auth.BeforeLogin, func(context *auth.Context, user interface{}) error {
+		if context.Claims == nil || context.Claims.UserID == "" {
+			return nil
+		}
+
+		var provisioned User
+		if err := context.Auth.GetDB(context.Request).Unscoped().Where("user_id = ?", context.Claims.UserID).First(&provisioned).Error; err == nil {
+			if provisioned.DeletedAt != nil || !provisioned.Active {
+				return auth.ErrAccountLocked
+			}
+		}
+		return nil
+	})
+}
+
+// Login SCIM provider doesn't support login
+func (provider Provider) Login(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Logout SCIM provider doesn't support logout
+func (provider Provider) Logout(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Register SCIM provider doesn't support register
+func (provider Provider) Register(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Deregister SCIM provider doesn't support deregister
+func (provider Provider) Deregister(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Callback SCIM provider doesn't support callback
+func (provider Provider) Callback(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// CSRFExempt SCIM endpoints are called by identity providers, authenticated with bearer tokens
+func (provider Provider) CSRFExempt(req *http.Request) bool {
+	return true
+}
+
+// ServeHTTP serve SCIM endpoints
+func (provider Provider) ServeHTTP(context *auth.Context) {
+	var (
+		req     = context.Request
+		w       = context.Writer
+		reqPath = strings.TrimPrefix(req.URL.Path, context.Auth.URLPrefix)
+		paths   = strings.Split(strings.TrimSuffix(reqPath, "/"), "/")
+	)
+
+	if !provider.authenticate(context) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+		writeError(w, ErrUnauthorized)
+		return
+	}
+
+	if len(paths) < 3 || paths[1] != "v2" {
+		writeError(w, ErrNotFound)
+		return
+	}
+
+	var id string
+	if len(paths) > 3 {
+		id = paths[3]
+	}
+
+	switch {
+	case paths[2] == "ServiceProviderConfig" && req.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, provider.serviceProviderConfig())
+	case paths[2] == "ResourceTypes" && req.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, provider.resourceTypes())
+	case paths[2] == "Users":
+		provider.serveUsers(context, id)
+	case paths[2] == "Groups":
+		provider.serveGroups(context, id)
+	default:
+		writeError(w, ErrNotFound)
+	}
+}
+
+func (provider Provider) authenticate(context *auth.Context) bool {
+	if provider.Authenticate != nil {
+		return provider.Authenticate(context)
+	}
+
+	value := context.Request.Header.Get("Authorization")
+	if provider.Token == "" || len(value) <= 7 || !strings.EqualFold(value[:7], "Bearer ") {
+		return false
+	}
+	return auth.SecureCompare(strings.TrimSpace(value[7:]), provider.Token)
+}
+
+func (provider Provider) serveUsers(context *auth.Context, id string) {
+	var (
+		req = context.Request
+		w   = context.Writer
+	)
+
+	switch {
+	case id == "" && req.Method == http.MethodGet:
+		result, err := provider.ListUsers(context, req.URL.Query().Get("filter"), startIndex(req), provider.count(req))
+		respond(w, http.StatusOK, result, err)
+	case id == "" && req.Method == http.MethodPost:
+		var resource UserResource
+		if err := json.NewDecoder(req.Body).Decode(&resource); err != nil {
+			writeError(w, &Error{Status: http.StatusBadRequest, ScimType: "invalidSyntax", Detail: err.Error()})
+			return
+		}
+
+		result, err := provider.CreateUser(context, &resource)
+		respond(w, http.StatusCreated, result, err)
+	case id == "":
+		writeError(w, &Error{Status: http.StatusMethodNotAllowed, Detail: http.StatusText(http.StatusMethodNotAllowed)})
+	case req.Method == http.MethodGet:
+		result, err := provider.GetUser(context, id)
+		respond(w, http.StatusOK, result, err)
+	case req.Method == http.MethodPut:
+		var resource UserResource
+		if err := json.NewDecoder(req.Body).Decode(&resource); err != nil {
+			writeError(w, &Error{Status: http.StatusBadRequest, ScimType: "invalidSyntax", Detail: err.Error()})
+			return
+		}
+
+		result, err := provider.ReplaceUser(context, id, &resource)
+		respond(w, http.StatusOK, result, err)
+	case req.Method == http.MethodPatch:
+		var patch PatchRequest
+		if err := json.NewDecoder(req.Body).Decode(&patch); err != nil {
+			writeError(w, &Error{Status: http.StatusBadRequest, ScimType: "invalidSyntax", Detail: err.Error()})
+			return
+		}
+
+		result, err := provider.PatchUser(context, id, patch.Operations)
+		respond(w, http.StatusOK, result, err)
+	case req.Method == http.MethodDelete:
+		if err := provider.DeleteUser(context, id); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, &Error{Status: http.StatusMethodNotAllowed, Detail: http.StatusText(http.StatusMethodNotAllowed)})
+	}
+}
+
+func (provider Provider) serveGroups(context *auth.Context, id string) {
+	var (
+		req = context.Request
+		w   = context.Writer
+	)
+
+	switch {
+	case id == "" && req.Method == http.MethodGet:
+		result, err := provider.ListGroups(context, req.URL.Query().Get("filter"), startIndex(req), provider.count(req))
+		respond(w, http.StatusOK, result, err)
+	case id == "" && req.Method == http.MethodPost:
+		var resource GroupResource
+		if err := json.NewDecoder(req.Body).Decode(&resource); err != nil {
+			writeError(w, &Error{Status: http.StatusBadRequest, ScimType: "invalidSyntax", Detail: err.Error()})
+			return
+		}
+
+		result, err := provider.CreateGroup(context, &resource)
+		respond(w, http.StatusCreated, result, err)
+	case id == "":
+		writeError(w, &Error{Status: http.StatusMethodNotAllowed, Detail: http.StatusText(http.StatusMethodNotAllowed)})
+	case req.Method == http.MethodGet:
+		result, err := provider.GetGroup(context, id)
+		respond(w, http.StatusOK, result, err)
+	case req.Method == http.MethodPut:
+		var resource GroupResource
+		if err := json.NewDecoder(req.Body).Decode(&resource); err != nil {
+			writeError(w, &Error{Status: http.StatusBadRequest, ScimType: "invalidSyntax", Detail: err.Error()})
+			return
+		}
+
+		result, err := provider.ReplaceGroup(context, id, &resource)
+		respond(w, http.StatusOK, result, err)
+	case req.Method == http.MethodPatch:
+		var patch PatchRequest
+		if err := json.NewDecoder(req.Body).Decode(&patch); err != nil {
+			writeError(w, &Error{Status: http.StatusBadRequest, ScimType: "invalidSyntax", Detail: err.Error()})
+			return
+		}
+
+		result, err := provider.PatchGroup(context, id, patch.Operations)
+		respond(w, http.StatusOK, result, err)
+	case req.Method == http.MethodDelete:
+		if err := provider.DeleteGroup(context, id); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, &Error{Status: http.StatusMethodNotAllowed, Detail: http.StatusText(http.StatusMethodNotAllowed)})
+	}
+}
+
+// userColumns filterable attributes of users
+var userColumns = map[string]string{
+	"id":              "user_id",
+	"username":        "user_name",
+	"externalid":      "external_id",
+	"displayname":     "display_name",
+	"name.givenname":  "given_name",
+	"name.familyname": "family_name",
+	"emails":          "email",
+	"emails.value":    "email",
+	"active":          "active",
+	"meta.created":    "created_at",
+}
+
+// ListUsers list provisioned users matched filter, startIndex is 1-based
+func (provider Provider) ListUsers(context *auth.Context, filterValue string, startIndex, count int) (*ListResponse, error) {
+	tx := context.Auth.GetReadDB(context.Request).Model(&User{})
+	if filterValue != "" {
+		condition, err := parseFilter(filterValue, func(attribute string) (string, bool) {
+			column, ok := userColumns[attribute]
+			return column, ok
+		})
+		if err != nil {
+			return nil, err
+		}
+		tx = tx.Where(condition.sql, condition.args...)
+	}
+
+	var (
+		total int
+		users []User
+	)
+
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Order("id").Offset(startIndex - 1).Limit(count).Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	result := &ListResponse{Schemas: []string{SchemaListResponse}, TotalResults: total, StartIndex: startIndex, ItemsPerPage: len(users), Resources: []interface{}{}}
+	for _, user := range users {
+		resource, err := provider.userResource(context, user)
+		if err != nil {
+			return nil, err
+		}
+		result.Resources = append(result.Resources, resource)
+	}
+	return result, nil
+}
+
+// GetUser get provisioned user with ID
+func (provider Provider) GetUser(context *auth.Context, id string) (*UserResource, error) {
+	user, err := provider.findUser(context, id)
+	if err != nil {
+		return nil, err
+	}
+	return provider.userResource(context, *user)
+}
+
+// CreateUser provision user, create user of Auth's UserModel, and link it to an auth identity of IdentityProvider if configured,
+// existing users linked to the identity are reused, users deleted before are restored, records are saved in a transaction,
+// concurrent requests provisioning the same userName are rejected by the unique index, and get ErrUniqueness
+func (provider Provider) CreateUser(context *auth.Context, resource *UserResource) (*UserResource, error) {
+	if resource.UserName == "" {
+		return nil, &Error{Status: http.StatusBadRequest, ScimType: "invalidValue", Detail: "userName is required"}
+	}
+
+	var user User
+	if err := context.Auth.Transaction(context, func(context *auth.Context) (err error) {
+		user, err = provider.createUser(context, resource)
+		return err
+	}); err != nil {
+		if _, ok := err.(*Error); !ok && provider.userNameTaken(context, resource.UserName) {
+			return nil, ErrUniqueness
+		}
+		return nil, err
+	}
+
+	context.Auth.Publish(EventUserProvisioned, context, map[string]interface{}{"user_id": user.UserID, "user_name": user.UserName})
+	if !user.Active {
+		if err := provider.deprovision(context, user); err != nil {
+			return nil, err
+		}
+	}
+	return provider.userResource(context, user)
+}
+
+func (provider Provider) createUser(context *auth.Context, resource *UserResource) (user User, err error) {
+	tx := context.Auth.GetDB(context.Request)
+
+	if err = tx.Unscoped().Where("LOWER(user_name) = ?", strings.ToLower(resource.UserName)).First(&user).Error; err == nil {
+		if user.DeletedAt == nil {
+			return user, ErrUniqueness
+		}
+
+		// restore deleted user
+		resource.apply(&user)
+		user.DeletedAt = nil
+		return user, tx.Unscoped().Save(&user).Error
+	} else if !gorm.IsRecordNotFoundError(err) {
+		return user, err
+	}
+
+	resource.apply(&user)
+	if provider.IdentityProvider != "" {
+		if identity, err := context.Auth.IdentityStore.FindByProviderUID(context, provider.IdentityProvider, resource.UserName); err == nil {
+			if claimer, ok := identity.(claims.ClaimerInterface); ok {
+				user.UserID = claimer.ToClaims().UserID
+			}
+		} else if err != auth.ErrInvalidAccount {
+			return user, err
+		}
+	}
+
+	if user.UserID == "" {
+		schema := auth.Schema{Provider: provider.IdentityProvider, UID: resource.UserName, Name: user.DisplayName, Email: user.Email, FirstName: user.GivenName, LastName: user.FamilyName}
+		_, userID, err := context.Auth.UserStorer.Save(&schema, context)
+		if err != nil {
+			return user, err
+		}
+		user.UserID = userID
+
+		if provider.IdentityProvider != "" {
+			identity := context.Auth.NewAuthIdentity()
+			value := utils.Indirect(reflect.ValueOf(identity))
+			value.FieldByName("Provider").SetString(provider.IdentityProvider)
+			value.FieldByName("UID").SetString(resource.UserName)
+			value.FieldByName("UserID").SetString(userID)
+			if _, _, err := auth.FindOrCreateIdentity(context, identity); err != nil {
+				return user, err
+			}
+		}
+	}
+
+	return user, tx.Create(&user).Error
+}
+
+// userNameTaken check userName is used by another provisioned user, read from primary DB, as it is used after unique index conflicts
+func (provider Provider) userNameTaken(context *auth.Context, userName string) bool {
+	var count int
+	context.Auth.GetDB(auth.UsePrimaryDB(context.Request)).Unscoped().Model(&User{}).Where("LOWER(user_name) = ?", strings.ToLower(userName)).Count(&count)
+	return count > 0
+}
+
+// ReplaceUser replace provisioned user's attributes, sync them to user of Auth's UserModel, deactivated users are deprovisioned
+func (provider Provider) ReplaceUser(context *auth.Context, id string, resource *UserResource) (*UserResource, error) {
+	user, err := provider.findUser(context, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if resource.UserName == "" {
+		return nil, &Error{Status: http.StatusBadRequest, ScimType: "invalidValue", Detail: "userName is required"}
+	}
+
+	if !strings.EqualFold(resource.UserName, user.UserName) {
+		var count int
+		if context.Auth.GetDB(context.Request).Unscoped().Model(&User{}).Where("LOWER(user_name) = ? AND id <> ?", strings.ToLower(resource.UserName), user.ID).Count(&count); count > 0 {
+			return nil, ErrUniqueness
+		}
+	}
+
+	wasActive := user.Active
+	resource.apply(user)
+	if err := context.Auth.GetDB(context.Request).Save(user).Error; err != nil {
+		return nil, err
+	}
+
+	if err := provider.syncUser(context, *user); err != nil {
+		return nil, err
+	}
+
+	context.Auth.Publish(EventUserUpdated, context, map[string]interface{}{"user_id": user.UserID, "user_name": user.UserName})
+	if wasActive && !user.Active {
+		if err := provider.deprovision(context, *user); err != nil {
+			return nil, err
+		}
+	}
+	return provider.userResource(context, *user)
+}
+
+// PatchUser patch provisioned user with patch operations, like `{"op": "replace", "path": "active", "value": false}`
+func (provider Provider) PatchUser(context *auth.Context, id string, operations []PatchOperation) (*UserResource, error) {
+	resource, err := provider.GetUser(context, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyPatch(resource, operations); err != nil {
+		return nil, err
+	}
+	return provider.ReplaceUser(context, id, resource)
+}
+
+// DeleteUser deprovision user, the provisioned user is soft deleted, user's sessions are revoked, and it couldn't login anymore,
+// user of Auth's UserModel is kept, subscribe EventUserDeprovisioned to delete it if needed, records are changed in a transaction
+func (provider Provider) DeleteUser(context *auth.Context, id string) error {
+	user, err := provider.findUser(context, id)
+	if err != nil {
+		return err
+	}
+
+	if err := context.Auth.Transaction(context, func(context *auth.Context) error {
+		var (
+			tx      = context.Auth.GetDB(context.Request)
+			members []GroupMember
+		)
+
+		if err := tx.Where("user_id = ?", user.UserID).Find(&members).Error; err != nil {
+			return err
+		}
+
+		for _, member := range members {
+			var group Group
+			if provider.Roles && tx.First(&group, member.GroupID).Error == nil {
+				if err := context.Auth.RoleStorer.Remove(user.UserID, group.DisplayName, context); err != nil {
+					return err
+				}
+			}
+
+			if err := tx.Unscoped().Delete(&member).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(user).Error
+	}); err != nil {
+		return err
+	}
+	return provider.deprovision(context, *user)
+}
+
+// groupColumns filterable attributes of groups
+var groupColumns = map[string]string{
+	"id":           "id",
+	"displayname":  "display_name",
+	"externalid":   "external_id",
+	"meta.created": "created_at",
+}
+
+// ListGroups list provisioned groups matched filter, startIndex is 1-based
+func (provider Provider) ListGroups(context *auth.Context, filterValue string, startIndex, count int) (*ListResponse, error) {
+	tx := context.Auth.GetReadDB(context.Request).Model(&Group{})
+	if filterValue != "" {
+		condition, err := parseFilter(filterValue, func(attribute string) (string, bool) {
+			column, ok := groupColumns[attribute]
+			return column, ok
+		})
+		if err != nil {
+			return nil, err
+		}
+		tx = tx.Where(condition.sql, condition.args...)
+	}
+
+	var (
+		total  int
+		groups []Group
+	)
+
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Order("id").Offset(startIndex - 1).Limit(count).Find(&groups).Error; err != nil {
+		return nil, err
+	}
+
+	result := &ListResponse{Schemas: []string{SchemaListResponse}, TotalResults: total, StartIndex: startIndex, ItemsPerPage: len(groups), Resources: []interface{}{}}
+	for _, group := range groups {
+		resource, err := provider.groupResource(context, group)
+		if err != nil {
+			return nil, err
+		}
+		result.Resources = append(result.Resources, resource)
+	}
+	return result, nil
+}
+
+// GetGroup get provisioned group with ID
+func (provider Provider) GetGroup(context *auth.Context, id string) (*GroupResource, error) {
+	group, err := provider.findGroup(context, id)
+	if err != nil {
+		return nil, err
+	}
+	return provider.groupResource(context, *group)
+}
+
+// CreateGroup create group, and add its members, records are saved in a transaction, so invalid members won't leave a group without them
+func (provider Provider) CreateGroup(context *auth.Context, resource *GroupResource) (*GroupResource, error) {
+	if resource.DisplayName == "" {
+		return nil, &Error{Status: http.StatusBadRequest, ScimType: "invalidValue", Detail: "displayName is required"}
+	}
+
+	group := Group{DisplayName: resource.DisplayName, ExternalID: resource.ExternalID}
+	if err := context.Auth.Transaction(context, func(context *auth.Context) error {
+		var count int
+		tx := context.Auth.GetDB(context.Request)
+		if tx.Model(&Group{}).Where("LOWER(display_name) = ?", strings.ToLower(resource.DisplayName)).Count(&count); count > 0 {
+			return ErrUniqueness
+		}
+
+		if err := tx.Create(&group).Error; err != nil {
+			return err
+		}
+		return provider.setMembers(context, group, resource.Members)
+	}); err != nil {
+		return nil, err
+	}
+	return provider.groupResource(context, group)
+}
+
+// ReplaceGroup replace group's display name and members, records are saved in a transaction
+func (provider Provider) ReplaceGroup(context *auth.Context, id string, resource *GroupResource) (*GroupResource, error) {
+	group, err := provider.findGroup(context, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if resource.DisplayName == "" {
+		return nil, &Error{Status: http.StatusBadRequest, ScimType: "invalidValue", Detail: "displayName is required"}
+	}
+
+	if err := context.Auth.Transaction(context, func(context *auth.Context) error {
+		tx := context.Auth.GetDB(context.Request)
+		if !strings.EqualFold(resource.DisplayName, group.DisplayName) {
+			var count int
+			if tx.Model(&Group{}).Where("LOWER(display_name) = ? AND id <> ?", strings.ToLower(resource.DisplayName), group.ID).Count(&count); count > 0 {
+				return ErrUniqueness
+			}
+
+			// roles are named after group, move members to the new role
+			if err := provider.setMembers(context, *group, nil); err != nil {
+				return err
+			}
+		}
+
+		group.DisplayName, group.ExternalID = resource.DisplayName, resource.ExternalID
+		if err := tx.Save(group).Error; err != nil {
+			return err
+		}
+		return provider.setMembers(context, *group, resource.Members)
+	}); err != nil {
+		return nil, err
+	}
+	return provider.groupResource(context, *group)
+}
+
+// PatchGroup patch group with patch operations, like `{"op": "add", "path": "members", "value": [{"value": "2819c223"}]}`
+func (provider Provider) PatchGroup(context *auth.Context, id string, operations []PatchOperation) (*GroupResource, error) {
+	resource, err := provider.GetGroup(context, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyPatch(resource, operations); err != nil {
+		return nil, err
+	}
+	return provider.ReplaceGroup(context, id, resource)
+}
+
+// DeleteGroup delete group, and remove its members
+func (provider Provider) DeleteGroup(context *auth.Context, id string) error {
+	group, err := provider.findGroup(context, id)
+	if err != nil {
+		return err
+	}
+
+	return context.Auth.Transaction(context, func(context *auth.Context) error {
+		if err := provider.setMembers(context, *group, nil); err != nil {
+			return err
+		}
+		return context.Auth.GetDB(context.Request).Unscoped().Delete(group).Error
+	})
+}
+
+func (provider Provider) findUser(context *auth.Context, id string) (*User, error) {
+	var user User
+	if err := context.Auth.GetDB(context.Request).Where("user_id = ?", id).First(&user).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (provider Provider) findGroup(context *auth.Context, id string) (*Group, error) {
+	var group Group
+	if err := context.Auth.GetDB(context.Request).Where("id = ?", id).First(&group).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &group, nil
+}
+
+// setMembers save group's members, grant or revoke roles named after group if Roles enabled, in a transaction
+func (provider Provider) setMembers(context *auth.Context, group Group, members []Member) error {
+	return context.Auth.Transaction(context, func(context *auth.Context) error {
+		return provider.saveMembers(context, group, members)
+	})
+}
+
+func (provider Provider) saveMembers(context *auth.Context, group Group, members []Member) error {
+	var (
+		tx       = context.Auth.GetDB(context.Request)
+		existing []GroupMember
+		wanted   = map[string]bool{}
+	)
+
+	for _, member := range members {
+		if _, err := provider.findUser(context, member.Value); err != nil {
+			return &Error{Status: http.StatusBadRequest, ScimType: "invalidValue", Detail: fmt.Sprintf("member %v not found", member.Value)}
+		}
+		wanted[member.Value] = true
+	}
+
+	if err := tx.Where("group_id = ?", group.ID).Find(&existing).Error; err != nil {
+		return err
+	}
+
+	for _, member := range existing {
+		if wanted[member.UserID] {
+			delete(wanted, member.UserID)
+			continue
+		}
+
+		if err := tx.Unscoped().Delete(&member).Error; err != nil {
+			return err
+		}
+
+		if provider.Roles {
+			if err := context.Auth.RoleStorer.Remove(member.UserID, group.DisplayName, context); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, member := range members {
+		if !wanted[member.Value] {
+			continue
+		}
+		delete(wanted, member.Value)
+
+		if err := tx.Create(&GroupMember{GroupID: group.ID, UserID: member.Value}).Error; err != nil {
+			return err
+		}
+
+		if provider.Roles {
+			if err := context.Auth.RoleStorer.Add(member.Value, group.DisplayName, context); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// syncUser sync provisioned user's name, email to fields `Name`, `FirstName`, `LastName`, `Email` of user of Auth's UserModel
+func (provider Provider) syncUser(context *auth.Context, user User) error {
+	currentUser, err := context.Auth.UserStorer.Get(&claims.Claims{UserID: user.UserID}, context)
+	if err != nil {
+		return err
+	}
+
+	var (
+		value   = utils.Indirect(reflect.ValueOf(currentUser))
+		updates = map[string]interface{}{}
+	)
+
+	for field, fieldValue := range map[string]string{"Name": user.DisplayName, "FirstName": user.GivenName, "LastName": user.FamilyName, "Email": user.Email} {
+		if f := value.FieldByName(field); f.IsValid() && f.Kind() == reflect.String && f.String() != fieldValue {
+			updates[gorm.ToColumnName(field)] = fieldValue
+		}
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
+	return context.Auth.GetDB(context.Request).Model(currentUser).Updates(updates).Error
+}
+
+// deprovision revoke deprovisioned user's sessions
+func (provider Provider) deprovision(context *auth.Context, user User) error {
+	if err := context.Auth.RevokeSessions(context.Request, user.UserID); err != nil {
+		return err
+	}
+
+	context.Auth.Publish(EventUserDeprovisioned, context, map[string]interface{}{"user_id": user.UserID, "user_name": user.UserName})
+	return nil
+}
+
+func (provider Provider) userResource(context *auth.Context, user User) (*UserResource, error) {
+	var (
+		members []GroupMember
+		groups  []Member
+	)
+
+	tx := context.Auth.GetReadDB(context.Request)
+	if err := tx.Where("user_id = ?", user.UserID).Find(&members).Error; err != nil {
+		return nil, err
+	}
+
+	for _, member := range members {
+		var group Group
+		if err := tx.First(&group, member.GroupID).Error; err == nil {
+			groups = append(groups, Member{Value: group.GetID(), Display: group.DisplayName, Ref: provider.location("Groups", group.GetID())})
+		}
+	}
+
+	resource := toUserResource(user, groups, provider.location("Users", user.UserID))
+	return &resource, nil
+}
+
+func (provider Provider) groupResource(context *auth.Context, group Group) (*GroupResource, error) {
+	var (
+		members []GroupMember
+		results []Member
+	)
+
+	tx := context.Auth.GetReadDB(context.Request)
+	if err := tx.Where("group_id = ?", group.ID).Order("id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+
+	for _, member := range members {
+		var user User
+		if err := tx.Where("user_id = ?", member.UserID).First(&user).Error; err == nil {
+			results = append(results, Member{Value: user.UserID, Display: user.DisplayName, Ref: provider.location("Users", user.UserID)})
+		}
+	}
+
+	resource := toGroupResource(group, results, provider.location("Groups", group.GetID()))
+	return &resource, nil
+}
+
+func (provider Provider) location(resourceType, id string) string {
+	if provider.BaseURL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(provider.BaseURL, "/") + provider.Auth.AuthURL("scim/v2/"+resourceType+"/"+id)
+}
+
+func (provider Provider) resourceTypes() *ListResponse {
+	return &ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: 2,
+		StartIndex:   1,
+		ItemsPerPage: 2,
+		Resources: []interface{}{
+			map[string]interface{}{"schemas": []string{SchemaResourceType}, "id": "User", "name": "User", "endpoint": "/Users", "schema": SchemaUser},
+			map[string]interface{}{"schemas": []string{SchemaResourceType}, "id": "Group", "name": "Group", "endpoint": "/Groups", "schema": SchemaGroup},
+		},
+	}
+}
+
+func (provider Provider) serviceProviderConfig() map[string]interface{} {
+	supported := func(value bool) map[string]interface{} { return map[string]interface{}{"supported": value} }
+	return map[string]interface{}{
+		"schemas":        []string{SchemaServiceProviderConfig},
+		"patch":          supported(true),
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": provider.MaxResults},
+		"changePassword": supported(false),
+		"sort":           supported(false),
+		"etag":           supported(false),
+		"authenticationSchemes": []map[string]interface{}{
+			{"type": "oauthbearertoken", "name": "OAuth Bearer Token", "description": "Authentication scheme using the OAuth Bearer Token Standard"},
+		},
+	}
+}
+
+func startIndex(req *http.Request) int {
+	if index, err := strconv.Atoi(req.URL.Query().Get("startIndex")); err == nil && index > 0 {
+		return index
+	}
+	return 1
+}
+
+func (provider Provider) count(req *http.Request) int {
+	if count, err := strconv.Atoi(req.URL.Query().Get("count")); err == nil && count >= 0 && count < provider.MaxResults {
+		return count
+	}
+	return provider.MaxResults
+}
+
+func respond(w http.ResponseWriter, status int, result interface{}, err error) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, status, result)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	scimErr, ok := err.(*Error)
+	if !ok {
+		scimErr = &Error{Status: http.StatusInternalServerError, Detail: err.Error()}
+	}
+
+	result := map[string]interface{}{"schemas": []string{SchemaError}, "status": strconv.Itoa(scimErr.Status), "detail": scimErr.Detail}
+	if scimErr.ScimType != "" {
+		result["scimType"] = scimErr.ScimType
+	}
+	writeJSON(w, scimErr.Status, result)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/scim+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,28 @@
+package scim_test
+
+import (
+	"testing"
+
+	"github.com/qor/auth/scim"
+)
+
+func TestCreateGroupWithUnknownMemberIsRolledBack(t *testing.T) {
+	provider, context := setup(t)
+
+	member, err := provider.CreateUser(context, &scim.UserResource{UserName: "bjensen"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := provider.CreateGroup(context, &scim.GroupResource{DisplayName: "Admins", Members: []scim.Member{{Value: member.ID}, {Value: "unknown"}}}); err == nil {
+		t.Fatal("expect group with unknown member rejected")
+	}
+
+	if result, err := provider.ListGroups(context, "", 1, 100); err != nil || result.TotalResults != 0 {
+		t.Errorf("expect no group left after rejected, got %+v, %v", result, err)
+	}
+
+	if _, err := provider.CreateGroup(context, &scim.GroupResource{DisplayName: "Admins", Members: []scim.Member{{Value: member.ID}}}); err != nil {
+		t.Errorf("expect group created after rejected one rolled back, got %v", err)
+	}
+}
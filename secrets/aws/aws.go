@@ -0,0 +1,245 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qor/auth/secrets"
+)
+
+// Credentials AWS credentials used to sign requests
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// Config AWS Secrets Manager secret provider config
+type Config struct {
+	// Region AWS region, default value is env `AWS_REGION` or `AWS_DEFAULT_REGION`
+	Region string
+	// Credentials get credentials, like temporary credentials of IAM role, default value is env `AWS_ACCESS_KEY_ID`, `AWS_SECRET_ACCESS_KEY`, `AWS_SESSION_TOKEN`
+	Credentials func(ctx context.Context) (Credentials, error)
+	// Endpoint Secrets Manager's endpoint, default value is `https://secretsmanager.{Region}.amazonaws.com`
+	Endpoint string
+	// HTTPClient client used to request Secrets Manager, default value is a client with 10 seconds timeout
+	HTTPClient *http.Client
+	// Clock current time used to sign requests, default value is time.Now
+	Clock func() time.Time
+}
+
+// New initialize AWS Secrets Manager secret provider, requests are signed with Signature Version 4, no AWS SDK is required
+func New(config *Config) *SecretsManager {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if config.Region == "" {
+		if config.Region = os.Getenv("AWS_REGION"); config.Region == "" {
+			config.Region = os.Getenv("AWS_DEFAULT_REGION")
+		}
+	}
+
+	if config.Credentials == nil {
+		config.Credentials = func(context.Context) (Credentials, error) {
+			return Credentials{
+				AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+				SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+				SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			}, nil
+		}
+	}
+
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf("https://secretsmanager.%v.amazonaws.com", config.Region)
+	}
+
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+
+	return &SecretsManager{Config: config}
+}
+
+// SecretsManager resolve secrets from AWS Secrets Manager, names are secret IDs (name or ARN), with optional `#{key}` to get a key of JSON secret, like `prod/auth#signing_keys`
+type SecretsManager struct {
+	*Config
+}
+
+var _ secrets.Provider = &SecretsManager{}
+
+// GetSecret get current version of secret, JSON secret's non-string keys are returned as JSON
+func (manager *SecretsManager) GetSecret(ctx context.Context, name string) (string, error) {
+	secretID, key := name, ""
+	// ARNs have `:`, not `#`, so `#` always separates the key
+	if idx := strings.LastIndex(name, "#"); idx >= 0 {
+		secretID, key = name[:idx], name[idx+1:]
+	}
+
+	body, _ := json.Marshal(map[string]string{"SecretId": secretID})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, manager.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+
+	credentials, err := manager.Credentials(ctx)
+	if err != nil {
+		return "", err
+	}
+	manager.sign(req, body, credentials)
+
+	resp, err := manager.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		content, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		json.Unmarshal(content, &failure)
+		if strings.HasSuffix(failure.Type, "ResourceNotFoundException") {
+			return "", secrets.ErrNotFound
+		}
+		return "", fmt.Errorf("aws: unexpected status %v: %v %v", resp.StatusCode, failure.Type, failure.Message)
+	}
+
+	var result struct {
+		SecretString string
+		SecretBinary []byte
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	value := result.SecretString
+	if value == "" && len(result.SecretBinary) > 0 {
+		value = string(result.SecretBinary)
+	}
+
+	if key == "" {
+		return value, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("aws: secret %v isn't JSON: %w", secretID, err)
+	}
+
+	field, ok := fields[key]
+	if !ok {
+		return "", secrets.ErrNotFound
+	}
+
+	var str string
+	if err := json.Unmarshal(field, &str); err == nil {
+		return str, nil
+	}
+	return string(field), nil
+}
+
+// sign sign request with Signature Version 4
+func (manager *SecretsManager) sign(req *http.Request, body []byte, credentials Credentials) {
+	var (
+		now     = manager.Clock().UTC()
+		amzDate = now.Format("20060102T150405Z")
+		date    = now.Format("20060102")
+		scope   = strings.Join([]string{date, manager.Region, "secretsmanager", "aws4_request"}, "/")
+	)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalURI := req.URL.EscapedPath()
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hashHex(body),
+	}, "\n")
+
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hashHex([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + credentials.SecretAccessKey)
+	for _, part := range []string{date, manager.Region, "secretsmanager", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v",
+		credentials.AccessKeyID, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))))
+}
+
+func canonicalQuery(query url.Values) string {
+	var keys []string
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, url.QueryEscape(key)+"="+strings.ReplaceAll(url.QueryEscape(value), "+", "%20"))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func hashHex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, content string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(content))
+	return mac.Sum(nil)
+}
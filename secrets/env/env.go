@@ -0,0 +1,52 @@
+package env
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/qor/auth/secrets"
+)
+
+// New initialize env secret provider, names are converted to env vars' names with prefix, like `session_key` to `AUTH_SESSION_KEY` with prefix `AUTH_`
+func New(prefix string) *Env {
+	return &Env{Prefix: prefix}
+}
+
+// Env resolve secrets from env vars, if `{NAME}_FILE` is set, secret is read from the file, like Docker, Kubernetes mounted secrets
+type Env struct {
+	Prefix string
+}
+
+var _ secrets.Provider = &Env{}
+
+// GetSecret get secret from env var, or file of `{NAME}_FILE`, trailing new lines of files are trimmed
+func (env *Env) GetSecret(ctx context.Context, name string) (string, error) {
+	key := env.Key(name)
+	if file := os.Getenv(key + "_FILE"); file != "" {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	}
+
+	if value, ok := os.LookupEnv(key); ok {
+		return value, nil
+	}
+	return "", secrets.ErrNotFound
+}
+
+// Key env var's name of secret, name is upper cased, characters other than letters, digits are replaced with `_`
+func (env *Env) Key(name string) string {
+	return env.Prefix + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
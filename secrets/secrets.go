@@ -0,0 +1,139 @@
+// Package secrets resolve secrets, like client secrets, signing keys from secret managers, instead of plain strings in code or config files,
+// implementations are in subpackages, like env, vault, aws
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound secret not found error
+var ErrNotFound = errors.New("secrets: not found")
+
+// Provider secret provider interface, resolve secret's current value with name, the name's format depends on provider, like env var's name, Vault's path
+type Provider interface {
+	// GetSecret get secret's value with name, return ErrNotFound if not exists
+	GetSecret(ctx context.Context, name string) (string, error)
+}
+
+// ProviderFunc adapt function to Provider, like wrapping your secret manager's SDK client
+type ProviderFunc func(ctx context.Context, name string) (string, error)
+
+// GetSecret get secret's value with name
+func (fc ProviderFunc) GetSecret(ctx context.Context, name string) (string, error) {
+	return fc(ctx, name)
+}
+
+// Load resolve secrets with names at startup, returns error with the secret's name if any of them couldn't be resolved, so the application refuses to start
+func Load(ctx context.Context, provider Provider, names ...string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, name := range names {
+		value, err := provider.GetSecret(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("secrets: failed to resolve %v: %w", name, err)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// New initialize secret with name, resolved with provider, call Load at startup, then Watch to pick up rotated values
+func New(provider Provider, name string) *Secret {
+	return &Secret{Provider: provider, Name: name}
+}
+
+// Secret secret resolved with provider, cached value is safe for concurrent use, and refreshed by Watch, used like:
+//
+//	signingKeys := secrets.New(vaultProvider, "auth/session#keys")
+//	if err := signingKeys.Load(ctx); err != nil {
+//	  log.Fatal(err)
+//	}
+//	go signingKeys.Watch(ctx, time.Minute)
+//
+//	auth.SessionStorer{SigningKeys: signingKeys.Keys, ...}
+type Secret struct {
+	Provider Provider
+	Name     string
+	// OnChange called after refreshed value changed, like updating registered SSO apps' secrets
+	OnChange func(value string)
+	// OnError called if failed to refresh, the cached value is kept, default behaviour is printing the error
+	OnError func(err error)
+
+	mutex  sync.RWMutex
+	value  string
+	loaded bool
+}
+
+// Load resolve secret's value, returns error if it couldn't be resolved
+func (secret *Secret) Load(ctx context.Context) error {
+	value, err := secret.Provider.GetSecret(ctx, secret.Name)
+	if err != nil {
+		return fmt.Errorf("secrets: failed to resolve %v: %w", secret.Name, err)
+	}
+
+	secret.mutex.Lock()
+	changed := secret.loaded && value != secret.value
+	secret.value, secret.loaded = value, true
+	secret.mutex.Unlock()
+
+	if changed && secret.OnChange != nil {
+		secret.OnChange(value)
+	}
+	return nil
+}
+
+// Watch refresh secret's value with interval until ctx is done, run it in a goroutine
+func (secret *Secret) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := secret.Load(ctx); err != nil {
+				if secret.OnError != nil {
+					secret.OnError(err)
+				} else {
+					fmt.Println(err)
+				}
+			}
+		}
+	}
+}
+
+// Value cached secret's value, blank string if not loaded
+func (secret *Secret) Value() string {
+	secret.mutex.RLock()
+	defer secret.mutex.RUnlock()
+	return secret.value
+}
+
+// Keys cached secret's value as keys, refer SplitKeys, used like `auth.SessionStorer{SigningKeys: secret.Keys}`
+func (secret *Secret) Keys() []string {
+	return SplitKeys(secret.Value())
+}
+
+// SplitKeys split secret's value into keys, value could be JSON array like `["new", "old"]`, or keys separated by new line or comma, blank keys are removed,
+// store rotated keys in one secret, with the newest first
+func SplitKeys(value string) []string {
+	var keys []string
+	if strings.HasPrefix(strings.TrimSpace(value), "[") {
+		if err := json.Unmarshal([]byte(value), &keys); err == nil {
+			return keys
+		}
+	}
+
+	for _, key := range strings.FieldsFunc(value, func(r rune) bool { return r == '\n' || r == ',' }) {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,150 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/qor/auth/secrets"
+)
+
+// Config Vault secret provider config
+type Config struct {
+	// Address Vault's address, like `https://vault.example.com:8200`, default value is env `VAULT_ADDR`
+	Address string
+	// Token Vault token, default value is env `VAULT_TOKEN`
+	Token string
+	// Namespace Vault Enterprise namespace, default value is env `VAULT_NAMESPACE`
+	Namespace string
+	// Mount mount path of KV secrets engine, default value is `secret`
+	Mount string
+	// KVVersion version of KV secrets engine, 1 or 2, default value is 2
+	KVVersion int
+	// HTTPClient client used to request Vault, default value is a client with 10 seconds timeout
+	HTTPClient *http.Client
+}
+
+// New initialize Vault secret provider, it reads secrets from KV secrets engine
+func New(config *Config) *Vault {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if config.Address == "" {
+		config.Address = os.Getenv("VAULT_ADDR")
+	}
+
+	if config.Token == "" {
+		config.Token = os.Getenv("VAULT_TOKEN")
+	}
+
+	if config.Namespace == "" {
+		config.Namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+
+	if config.Mount == "" {
+		config.Mount = "secret"
+	}
+
+	if config.KVVersion == 0 {
+		config.KVVersion = 2
+	}
+
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Vault{Config: config}
+}
+
+// Vault resolve secrets from Vault's KV secrets engine, names are `{path}#{field}`, like `auth/session#signing_keys`, field's default value is `value`
+type Vault struct {
+	*Config
+}
+
+var _ secrets.Provider = &Vault{}
+
+// GetSecret get field of secret at path, non-string fields are returned as JSON
+func (vault *Vault) GetSecret(ctx context.Context, name string) (string, error) {
+	pth, field := name, "value"
+	if idx := strings.LastIndex(name, "#"); idx >= 0 {
+		pth, field = name[:idx], name[idx+1:]
+	}
+
+	endpoint := strings.TrimRight(vault.Address, "/") + "/v1/" + strings.Trim(vault.Mount, "/") + "/"
+	if vault.KVVersion == 2 {
+		endpoint += "data/"
+	}
+	endpoint += escapePath(strings.Trim(pth, "/"))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", vault.Token)
+	if vault.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", vault.Namespace)
+	}
+
+	resp, err := vault.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", secrets.ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("vault: unexpected status %v: %v", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	data := result.Data
+	if vault.KVVersion == 2 {
+		var versioned struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(data, &versioned); err != nil {
+			return "", err
+		}
+		data = versioned.Data
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", err
+	}
+
+	value, ok := fields[field]
+	if !ok {
+		return "", secrets.ErrNotFound
+	}
+
+	var str string
+	if err := json.Unmarshal(value, &str); err == nil {
+		return str, nil
+	}
+	return string(value), nil
+}
+
+func escapePath(pth string) string {
+	segments := strings.Split(pth, "/")
+	for idx, segment := range segments {
+		segments[idx] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// SecurityHeaders security headers sent with responses of auth routes, blank headers are not sent
+type SecurityHeaders struct {
+	// ContentSecurityPolicy header `Content-Security-Policy`, its `frame-ancestors` directive defines who could embed auth pages
+	ContentSecurityPolicy string
+	// FrameOptions header `X-Frame-Options`, for browsers don't support `frame-ancestors`
+	FrameOptions string
+	// ReferrerPolicy header `Referrer-Policy`
+	ReferrerPolicy string
+	// ContentTypeOptions header `X-Content-Type-Options`
+	ContentTypeOptions string
+	// Nonce generate a nonce for each request, add it to `script-src`, `style-src` of ContentSecurityPolicy, views get it with func `csp_nonce`,
+	// so inline scripts, styles like `<style nonce="{{csp_nonce}}">` are allowed without `'unsafe-inline'`
+	Nonce bool
+}
+
+// DefaultSecurityHeaders default security headers, auth pages couldn't be embedded into frames, copy and change it to customize them
+var DefaultSecurityHeaders = SecurityHeaders{
+	ContentSecurityPolicy: "default-src 'self'; img-src 'self' data: https:; style-src 'self' 'unsafe-inline'; object-src 'none'; base-uri 'self'; frame-ancestors 'none'",
+	FrameOptions:          "DENY",
+	ReferrerPolicy:        "no-referrer",
+	ContentTypeOptions:    "nosniff",
+}
+
+// Apply set security headers to response, handlers could overwrite them before writing response, like relaxing CSP for auto-submitted forms
+func (headers SecurityHeaders) Apply(w http.ResponseWriter) {
+	for name, value := range map[string]string{
+		"Content-Security-Policy": headers.ContentSecurityPolicy,
+		"X-Frame-Options":         headers.FrameOptions,
+		"Referrer-Policy":         headers.ReferrerPolicy,
+		"X-Content-Type-Options":  headers.ContentTypeOptions,
+	} {
+		if value != "" {
+			w.Header().Set(name, value)
+		}
+	}
+}
+
+// PolicyWithNonce ContentSecurityPolicy with nonce source added to `script-src`, `style-src`,
+// if they don't exist, they are added with `default-src`'s sources, so the nonce doesn't loosen other sources
+func (headers SecurityHeaders) PolicyWithNonce(nonce string) string {
+	var (
+		directives []string
+		defaultSrc []string
+		found      = map[string]bool{}
+		source     = "'nonce-" + nonce + "'"
+	)
+
+	for _, directive := range strings.Split(headers.ContentSecurityPolicy, ";") {
+		fields := strings.Fields(directive)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch name := strings.ToLower(fields[0]); name {
+		case "default-src":
+			defaultSrc = fields[1:]
+		case "script-src", "style-src":
+			found[name] = true
+			fields = append([]string{fields[0]}, withSource(fields[1:], source)...)
+		}
+		directives = append(directives, strings.Join(fields, " "))
+	}
+
+	if defaultSrc != nil {
+		for _, name := range []string{"script-src", "style-src"} {
+			if !found[name] {
+				directives = append(directives, strings.Join(append([]string{name}, withSource(defaultSrc, source)...), " "))
+			}
+		}
+	}
+	return strings.Join(directives, "; ")
+}
+
+// withSource add source to sources, `'none'` is replaced as it couldn't be combined with other sources
+func withSource(sources []string, source string) []string {
+	results := []string{}
+	for _, s := range sources {
+		if strings.ToLower(s) != "'none'" {
+			results = append(results, s)
+		}
+	}
+	return append(results, source)
+}
+
+// CSPNonce get CSP nonce of request, generated once for each request, used in views like `<script nonce="{{csp_nonce}}">`,
+// returns blank string if failed to generate it, so inline scripts are blocked
+func (context *Context) CSPNonce() string {
+	if context.cspNonce == "" {
+		context.cspNonce, _ = context.Auth.GenerateToken()
+	}
+	return context.cspNonce
+}
@@ -36,6 +36,13 @@ type SessionStorer struct {
 	SigningMethod  jose.SignatureAlgorithm
 	SignedString   string
 	SessionManager session.ManagerInterface
+	// SignedStrings rotated secrets, the first one is used to sign tokens instead of SignedString, all of them and SignedString are accepted when validating tokens,
+	// to rotate the secret, prepend the new one, and remove old ones after issued tokens expired
+	SignedStrings []string
+	// SigningKeys get secrets dynamically, like resolved from secret managers with secrets.Secret's Keys, overwrites SignedStrings, so rotated secrets are used without restarting
+	SigningKeys func() []string
+	// Clock current time used to validate claims' expiry, default value is time.Now
+	Clock func() time.Time
 }
 
 // Get get claims from request
@@ -79,7 +86,7 @@ func (sessionStorer *SessionStorer) Flashes(w http.ResponseWriter, req *http.Req
 func (sessionStorer *SessionStorer) SignedToken(claims *claims.Claims) (string, error) {
 	signer, err := jose.NewSigner(jose.SigningKey{
 		Algorithm: sessionStorer.SigningMethod,
-		Key:       []byte(sessionStorer.SignedString),
+		Key:       []byte(sessionStorer.signingKey()),
 	}, nil)
 	if err != nil {
 		return "", err
@@ -96,10 +103,49 @@ func (sessionStorer *SessionStorer) ValidateClaims(tokenString string) (*claims.
 	}
 
 	var claims claims.Claims
-	err = token.Claims([]byte(sessionStorer.SignedString), &claims)
+	for _, key := range sessionStorer.verificationKeys() {
+		if err = token.Claims([]byte(key), &claims); err == nil {
+			break
+		}
+	}
+
 	if err != nil {
 		return nil, err
 	}
 
-	return &claims, claims.Validate(jwt.Expected{Time: time.Now()})
+	now := time.Now
+	if sessionStorer.Clock != nil {
+		now = sessionStorer.Clock
+	}
+	return &claims, claims.Validate(jwt.Expected{Time: now()})
+}
+
+// signingKey secret used to sign tokens, the first of SigningKeys, SignedStrings, or SignedString
+func (sessionStorer *SessionStorer) signingKey() string {
+	if signedStrings := sessionStorer.signedStrings(); len(signedStrings) > 0 && signedStrings[0] != "" {
+		return signedStrings[0]
+	}
+	return sessionStorer.SignedString
+}
+
+// verificationKeys secrets accepted when validating tokens, SigningKeys or SignedStrings, and SignedString, blank ones are skipped
+func (sessionStorer *SessionStorer) verificationKeys() []string {
+	var keys []string
+	for _, key := range append(append([]string{}, sessionStorer.signedStrings()...), sessionStorer.SignedString) {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return []string{""}
+	}
+	return keys
+}
+
+func (sessionStorer *SessionStorer) signedStrings() []string {
+	if sessionStorer.SigningKeys != nil {
+		return sessionStorer.SigningKeys()
+	}
+	return sessionStorer.SignedStrings
 }
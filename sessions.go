@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/qor/auth/auth_session"
+	"github.com/qor/auth/claims"
+)
+
+// DeviceCookieName name of cookie used to recognize devices when tracking sessions
+var DeviceCookieName = "_auth_device"
+
+// GetSession get session's metadata with session ID, sessions are saved only if Config.TrackSessions is enabled
+func (auth *Auth) GetSession(req *http.Request, sessionID string) (*auth_session.AuthSession, error) {
+	var session auth_session.AuthSession
+	if sessionID == "" {
+		return nil, ErrInvalidAccount
+	}
+
+	err := auth.GetReadDB(req).Where("session_id = ?", sessionID).First(&session).Error
+	return &session, err
+}
+
+// GetSessions get user's sessions, latest first
+func (auth *Auth) GetSessions(req *http.Request, userID string) (sessions []auth_session.AuthSession, err error) {
+	err = auth.GetReadDB(req).Where("user_id = ?", userID).Order("id DESC").Find(&sessions).Error
+	return
+}
+
+// createSession save session's metadata into database, and set session ID into claims
+func (auth *Auth) createSession(w http.ResponseWriter, req *http.Request, claims *claims.Claims) error {
+	sessionID, err := auth.GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	deviceID, err := auth.getDeviceID(w, req)
+	if err != nil {
+		return err
+	}
+
+	now := auth.Now()
+	session := auth_session.AuthSession{
+		SessionID:    sessionID,
+		UserID:       claims.GetUserID(),
+		Provider:     claims.Provider,
+		DeviceID:     deviceID,
+		IPAddress:    auth.GetClientIP(req).String(),
+		UserAgent:    req.UserAgent(),
+		LastActiveAt: &now,
+	}
+
+	if location := auth.GetLocation(req); location != nil {
+		session.Country = location.Country
+		session.City = location.City
+	}
+
+	if err := auth.GetDB(req).Create(&session).Error; err != nil {
+		return err
+	}
+
+	claims.SessionID = session.SessionID
+	return nil
+}
+
+// RevokeSessions revoke all sessions of user, revoked sessions are rejected by GetCurrentUser if Config.TrackSessions is enabled
+func (auth *Auth) RevokeSessions(req *http.Request, userID string) error {
+	return auth.GetDB(req).Model(&auth_session.AuthSession{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", auth.Now()).Error
+}
+
+func (auth *Auth) revokeSession(req *http.Request, sessionID string) error {
+	if sessionID == "" {
+		return nil
+	}
+	return auth.GetDB(req).Model(&auth_session.AuthSession{}).Where("session_id = ? AND revoked_at IS NULL", sessionID).Update("revoked_at", auth.Now()).Error
+}
+
+// getDeviceID get device ID from cookie, generate one if not exists
+func (auth *Auth) getDeviceID(w http.ResponseWriter, req *http.Request) (string, error) {
+	if cookie, err := auth.GetCookie(req, DeviceCookieName); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+
+	deviceID, err := auth.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+
+	auth.SetCookie(w, req, &http.Cookie{
+		Name:     DeviceCookieName,
+		Value:    deviceID,
+		Path:     "/",
+		Expires:  auth.Now().AddDate(10, 0, 0),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return deviceID, nil
+}
@@ -0,0 +1,130 @@
+package sso_hub
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// ErrInvalidAssertion assertion, logout token is invalid, expired or issued to another app
+var ErrInvalidAssertion = errors.New("invalid assertion")
+
+// LogoutToken claims of logout tokens posted to apps' LogoutURL
+type LogoutToken struct {
+	jwt.Claims
+	SessionID string                 `json:"sid,omitempty"`
+	Events    map[string]interface{} `json:"events"`
+}
+
+// Client used by apps to login users through the hub, verify assertions and logout tokens
+//
+//	Hub := &sso_hub.Client{HubURL: "https://accounts.example.com/auth/sso", Issuer: "https://accounts.example.com", AppID: "wiki", Secret: os.Getenv("SSO_SECRET")}
+//	http.Redirect(w, req, Hub.LoginURL("https://wiki.example.com/sso/callback", state), http.StatusFound)
+//
+//	// in callback
+//	claims, err := Hub.VerifyAssertion(req.URL.Query().Get("assertion"))
+type Client struct {
+	// HubURL URL of hub's SSO endpoints, like `https://accounts.example.com/auth/sso`
+	HubURL string
+	Issuer string
+	AppID  string
+	Secret string
+	// Leeway allowed clock skew, default value is 1 minute
+	Leeway time.Duration
+}
+
+// VerifiedAssertion verified assertion, Extra has all claims, including extra claims like `email`, `name`, `roles`
+type VerifiedAssertion struct {
+	Assertion
+	Extra map[string]interface{}
+}
+
+// LoginURL URL redirecting users to login through the hub, the hub redirects back to redirectURI with `assertion`, `state`
+func (client *Client) LoginURL(redirectURI string, state string) string {
+	return appendQuery(strings.TrimSuffix(client.HubURL, "/")+"/login", url.Values{"app": []string{client.AppID}, "redirect_uri": []string{redirectURI}, "state": []string{state}})
+}
+
+// LogoutURL URL logging users out from the hub and all apps, the hub redirects back to redirectURI
+func (client *Client) LogoutURL(redirectURI string) string {
+	return appendQuery(strings.TrimSuffix(client.HubURL, "/")+"/logout", url.Values{"app": []string{client.AppID}, "redirect_uri": []string{redirectURI}})
+}
+
+// VerifyAssertion verify assertion's signature, issuer, audience, expiry, logout tokens are rejected
+func (client *Client) VerifyAssertion(token string) (*VerifiedAssertion, error) {
+	var result VerifiedAssertion
+	if err := client.verify(token, AssertionType, &result.Assertion, &result.Extra); err != nil {
+		return nil, err
+	}
+
+	if _, ok := result.Extra["events"]; ok {
+		return nil, ErrInvalidAssertion
+	}
+	return &result, nil
+}
+
+// VerifyLogoutToken verify logout token's signature, issuer, audience, expiry and event
+func (client *Client) VerifyLogoutToken(token string) (*LogoutToken, error) {
+	var result LogoutToken
+	if err := client.verify(token, LogoutTokenType, &result); err != nil {
+		return nil, err
+	}
+
+	if _, ok := result.Events[BackChannelLogoutEvent]; !ok {
+		return nil, ErrInvalidAssertion
+	}
+	return &result, nil
+}
+
+// LogoutHandler back-channel logout endpoint, mount it at app's LogoutURL, logout is called with verified logout tokens, destroy app sessions of `Subject`, `SessionID` in it
+func (client *Client) LogoutHandler(logout func(token *LogoutToken) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		token, err := client.VerifyLogoutToken(req.FormValue("logout_token"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := logout(token); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func (client *Client) verify(token string, typ string, dest ...interface{}) error {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil || len(parsed.Headers) != 1 || parsed.Headers[0].Algorithm != "HS256" {
+		return ErrInvalidAssertion
+	}
+
+	if value, _ := parsed.Headers[0].ExtraHeaders[jose.HeaderType].(string); value != typ {
+		return ErrInvalidAssertion
+	}
+
+	var registered jwt.Claims
+	if err := parsed.Claims([]byte(client.Secret), append([]interface{}{&registered}, dest...)...); err != nil {
+		return ErrInvalidAssertion
+	}
+
+	leeway := client.Leeway
+	if leeway == 0 {
+		leeway = jwt.DefaultLeeway
+	}
+
+	if err := registered.ValidateWithLeeway(jwt.Expected{Issuer: client.Issuer, Audience: jwt.Audience{client.AppID}, Time: time.Now()}, leeway); err != nil || registered.Expiry == nil {
+		return ErrInvalidAssertion
+	}
+	return nil
+}
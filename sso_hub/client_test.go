@@ -0,0 +1,78 @@
+package sso_hub_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/qor/auth"
+	"github.com/qor/auth/claims"
+	"github.com/qor/auth/sso_hub"
+)
+
+type redirector struct{}
+
+func (redirector) Redirect(w http.ResponseWriter, req *http.Request, action string) {}
+
+func TestAssertionsAndLogoutTokensAreNotInterchangeable(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	logoutTokens := make(chan string, 1)
+	appServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		logoutTokens <- req.FormValue("logout_token")
+	}))
+	t.Cleanup(appServer.Close)
+
+	app := sso_hub.App{ID: "wiki", Secret: "wiki-secret-at-least-thirty-two-bytes", RedirectURIs: []string{"https://wiki.example.com/sso/callback"}, LogoutURL: appServer.URL}
+	Auth := auth.New(&auth.Config{DB: db, Redirector: redirector{}})
+	provider := sso_hub.New(&sso_hub.Config{
+		Issuer: "https://accounts.example.com",
+		Apps:   []sso_hub.App{app},
+		Claims: func(*auth.Context, string, sso_hub.App) (map[string]interface{}, error) {
+			return map[string]interface{}{}, nil
+		},
+	})
+	Auth.RegisterProvider(provider)
+	if err := auth.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		client        = &sso_hub.Client{Issuer: "https://accounts.example.com", AppID: app.ID, Secret: app.Secret}
+		context       = &auth.Context{Auth: Auth, Request: httptest.NewRequest("GET", "/", nil)}
+		currentClaims = &claims.Claims{UserID: "1", SessionID: "session"}
+	)
+
+	assertion, err := provider.IssueAssertion(context, app, currentClaims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := provider.SingleLogout(context, currentClaims); err != nil {
+		t.Fatal(err)
+	}
+	logoutToken := <-logoutTokens
+
+	if _, err := client.VerifyAssertion(assertion); err != nil {
+		t.Errorf("expect assertion verified, got %v", err)
+	}
+
+	if _, err := client.VerifyLogoutToken(logoutToken); err != nil {
+		t.Errorf("expect logout token verified, got %v", err)
+	}
+
+	if _, err := client.VerifyAssertion(logoutToken); err != sso_hub.ErrInvalidAssertion {
+		t.Errorf("expect logout token rejected as assertion, got %v", err)
+	}
+
+	if _, err := client.VerifyLogoutToken(assertion); err != sso_hub.ErrInvalidAssertion {
+		t.Errorf("expect assertion rejected as logout token, got %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package sso_hub
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/qor/auth"
+)
+
+func init() {
+	auth.RegisterTables("sso_hub_sessions")
+	auth.RegisterMigration(auth.Migration{ID: "sso_hub/001_create_sso_hub_sessions", Migrate: func(db *gorm.DB) error {
+		return db.AutoMigrate(&Session{}).Error
+	}})
+}
+
+// Session app user logged in through the hub, apps of the hub session are notified when user logs out
+type Session struct {
+	gorm.Model
+	// SessionID hub's session ID, user's ID is used if sessions aren't tracked
+	SessionID string `gorm:"index"`
+	UserID    string `gorm:"index"`
+	AppID     string
+}
+
+// TableName table name of hub sessions
+func (Session) TableName() string {
+	return auth.TableName("sso_hub_sessions")
+}
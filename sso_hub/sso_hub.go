@@ -0,0 +1,509 @@
+// Package sso_hub cross-application SSO hub, let one auth deployment act as the central login service of several first-party apps,
+// apps redirect users to the hub, the hub issues per-app signed assertions, and logging out propagates to all apps user logged in through the hub
+package sso_hub
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qor/auth"
+	"github.com/qor/auth/claims"
+	"github.com/qor/qor/utils"
+	"github.com/qor/session"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+const (
+	// EventAssertionIssued assertion issued to an app
+	EventAssertionIssued = "sso_hub.assertion_issued"
+	// EventSingleLogout user logged out from the hub, apps are notified, failed apps are saved in event's data with key `errors`
+	EventSingleLogout = "sso_hub.single_logout"
+)
+
+// BackChannelLogoutEvent event claim of logout tokens, same as OpenID Connect back-channel logout
+const BackChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+const (
+	// AssertionType `typ` header of assertions
+	AssertionType = "sso-assertion+jwt"
+	// LogoutTokenType `typ` header of logout tokens, same as OpenID Connect back-channel logout, so logout tokens can't be used as assertions
+	LogoutTokenType = "logout+jwt"
+)
+
+var (
+	// ErrUnknownApp app isn't registered
+	ErrUnknownApp = errors.New("unknown app")
+	// ErrInvalidRedirectURI redirect URI isn't registered for app
+	ErrInvalidRedirectURI = errors.New("invalid redirect_uri")
+)
+
+// App first-party app logging in users through the hub
+type App struct {
+	ID   string
+	Name string
+	// Secret shared secret signing assertions, logout tokens, at least 32 bytes
+	Secret string
+	// RedirectURIs assertions are sent to them, requested redirect_uri must match one of them exactly
+	RedirectURIs []string
+	// LogoutURL back-channel logout endpoint of app, the hub posts a signed `logout_token` to it when user logs out
+	LogoutURL string
+}
+
+// AllowRedirectURI check redirect URI is registered
+func (app App) AllowRedirectURI(redirectURI string) bool {
+	for _, uri := range app.RedirectURIs {
+		if uri == redirectURI {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate check app's ID, secret, redirect URIs
+func (app App) Validate() error {
+	switch {
+	case app.ID == "":
+		return errors.New("ID must be specified")
+	case len(app.Secret) < 32:
+		return fmt.Errorf("secret of app %v must be at least 32 bytes", app.ID)
+	case len(app.RedirectURIs) == 0:
+		return fmt.Errorf("app %v should have redirect URIs", app.ID)
+	}
+	return nil
+}
+
+// Config SSO hub config
+type Config struct {
+	// Issuer issuer of assertions, like `https://accounts.example.com`, apps verify it
+	Issuer string
+	// Apps registered apps, more could be added with AddApp
+	Apps []App
+	// LoginURL users haven't logged in are redirected to it with query `return_to`, default value is `{Auth Prefix}/login`
+	LoginURL string
+	// AssertionExpiry assertions should be exchanged for app sessions right away, default value is 1 minute
+	AssertionExpiry time.Duration
+	// Claims extra claims of assertions, default claims are `email`, `name` read from user model, `roles`
+	Claims func(context *auth.Context, userID string, app App) (map[string]interface{}, error)
+	// HTTPClient client posting logout tokens to apps, default timeout is 5 seconds
+	HTTPClient *http.Client
+}
+
+// New initialize SSO hub, panics if config is invalid, use NewWithError to handle the error
+func New(config *Config) *Provider {
+	provider, err := NewWithError(config)
+	if err != nil {
+		panic(err)
+	}
+	return provider
+}
+
+// NewWithError initialize SSO hub, returns a *auth.ConfigError lists all problems if config is invalid
+func NewWithError(config *Config) (*Provider, error) {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if config.AssertionExpiry == 0 {
+		config.AssertionExpiry = time.Minute
+	}
+
+	if config.Claims == nil {
+		config.Claims = DefaultClaims
+	}
+
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 5 * time.Second}
+	}
+
+	provider := &Provider{Config: config, apps: map[string]App{}}
+	for _, app := range config.Apps {
+		if err := provider.AddApp(app); err != nil {
+			return nil, err
+		}
+	}
+	return provider, nil
+}
+
+// Validate check config, returns a *auth.ConfigError lists all problems, or nil if config is valid
+func (config *Config) Validate() error {
+	var (
+		configErr = &auth.ConfigError{Name: "sso_hub"}
+		appIDs    = map[string]bool{}
+	)
+
+	if config.Issuer == "" {
+		configErr.Add("Issuer must be specified")
+	}
+
+	for idx, app := range config.Apps {
+		if err := app.Validate(); err != nil {
+			configErr.Add("Apps[%v]: %v", idx, err)
+		}
+
+		if appIDs[app.ID] {
+			configErr.Add("Apps[%v]: app %v registered more than once", idx, app.ID)
+		}
+		appIDs[app.ID] = true
+	}
+	return configErr.Err()
+}
+
+// Provider SSO hub, register it as a provider
+//
+//	GET {Auth Prefix}/sso/login?app={app ID}&redirect_uri=...&state=...  redirect to redirect_uri with `assertion`, `state`, users haven't logged in are redirected to login page first
+//	GET {Auth Prefix}/sso/logout?app={app ID}&redirect_uri=...           log out from the hub and all apps, then redirect to redirect_uri
+type Provider struct {
+	*Config
+	Auth *auth.Auth
+
+	mutex sync.RWMutex
+	apps  map[string]App
+}
+
+// GetName return provider name
+func (*Provider) GetName() string {
+	return "sso"
+}
+
+// ConfigAuth config auth
+func (provider *Provider) ConfigAuth(Auth *auth.Auth) {
+	provider.Auth = Auth
+
+	if provider.Config.LoginURL == "" {
+		provider.Config.LoginURL = Auth.AuthURL("login")
+	}
+
+	// logging out from the hub, with `{Auth Prefix}/logout` or the hub's logout endpoint, logs out from all apps
+	Auth.Subscribe(auth.EventLogout, func(event *auth.Event) {
+		if event.Context != nil && event.Claims != nil {
+			provider.SingleLogout(event.Context, event.Claims)
+		}
+	})
+}
+
+// AddApp register app, returns error if app is invalid
+func (provider *Provider) AddApp(app App) error {
+	if err := app.Validate(); err != nil {
+		return err
+	}
+
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	provider.apps[app.ID] = app
+	return nil
+}
+
+// RemoveApp remove app with ID
+func (provider *Provider) RemoveApp(id string) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	delete(provider.apps, id)
+}
+
+// GetApp get app with ID
+func (provider *Provider) GetApp(id string) (App, error) {
+	provider.mutex.RLock()
+	defer provider.mutex.RUnlock()
+
+	if app, ok := provider.apps[id]; ok {
+		return app, nil
+	}
+	return App{}, ErrUnknownApp
+}
+
+// Login issue assertion to app, users haven't logged in are redirected to login page first
+func (provider *Provider) Login(context *auth.Context) {
+	provider.ServeHTTP(context)
+}
+
+// Logout log out from the hub and all apps
+func (provider *Provider) Logout(context *auth.Context) {
+	provider.ServeHTTP(context)
+}
+
+// Register SSO hub doesn't support register
+func (provider *Provider) Register(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Deregister SSO hub doesn't support deregister
+func (provider *Provider) Deregister(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// Callback SSO hub doesn't support callback
+func (provider *Provider) Callback(context *auth.Context) {
+	http.NotFound(context.Writer, context.Request)
+}
+
+// ServeHTTP serve SSO hub's login, logout endpoints
+func (provider *Provider) ServeHTTP(context *auth.Context) {
+	var (
+		req     = context.Request
+		w       = context.Writer
+		reqPath = strings.TrimPrefix(req.URL.Path, context.Auth.URLPrefix)
+		paths   = strings.Split(reqPath, "/")
+	)
+
+	if len(paths) < 2 || (paths[1] != "login" && paths[1] != "logout") {
+		http.NotFound(w, req)
+		return
+	}
+
+	req.ParseForm()
+	app, err := provider.GetApp(context.FormValue("app"))
+	if err != nil {
+		http.Error(w, context.TranslateError(err), http.StatusBadRequest)
+		return
+	}
+
+	// never redirect to unregistered redirect URIs
+	redirectURI := context.FormValue("redirect_uri")
+	if !app.AllowRedirectURI(redirectURI) {
+		http.Error(w, context.TranslateError(ErrInvalidRedirectURI), http.StatusBadRequest)
+		return
+	}
+
+	currentClaims, claimsErr := context.Auth.SessionStorer.Get(req)
+
+	switch paths[1] {
+	case "login":
+		if claimsErr != nil {
+			loginURL := url.URL{Path: provider.LoginURL, RawQuery: url.Values{"return_to": []string{req.URL.RequestURI()}}.Encode()}
+			http.Redirect(w, req, loginURL.String(), http.StatusSeeOther)
+			return
+		}
+		context.Claims = currentClaims
+
+		assertion, err := provider.IssueAssertion(context, app, currentClaims)
+		if err != nil {
+			http.Error(w, context.TranslateError(err), http.StatusInternalServerError)
+			return
+		}
+
+		params := url.Values{"assertion": []string{assertion}}
+		if state := context.FormValue("state"); state != "" {
+			params.Set("state", state)
+		}
+		http.Redirect(w, req, appendQuery(redirectURI, params), http.StatusFound)
+	case "logout":
+		if claimsErr == nil {
+			context.Claims = currentClaims
+			context.Auth.Logout(w, req)
+
+			user, _ := context.Auth.UserStorer.Get(currentClaims, context)
+			if err := context.Auth.RunHooks(auth.AfterLogout, context, user); err != nil {
+				context.SessionStorer.Flash(w, req, session.Message{Message: template.HTML(context.TranslateError(err)), Type: "error"})
+			}
+			context.Auth.Publish(auth.EventLogout, context, nil)
+		}
+		http.Redirect(w, req, redirectURI, http.StatusFound)
+	}
+}
+
+// Assertion registered claims of assertions issued to apps, extra claims like `email`, `name`, `roles` are added by Config.Claims
+type Assertion struct {
+	jwt.Claims
+	SessionID string `json:"sid,omitempty"`
+}
+
+// IssueAssertion issue signed assertion of user to app, and remember the app, so it could be notified when user logs out
+func (provider *Provider) IssueAssertion(context *auth.Context, app App, currentClaims *claims.Claims) (string, error) {
+	var (
+		now       = context.Auth.Now()
+		userID    = currentClaims.GetUserID()
+		sessionID = hubSessionID(currentClaims)
+	)
+
+	extra, err := provider.Claims(context, userID, app)
+	if err != nil {
+		return "", err
+	}
+
+	signer, err := newSigner(app.Secret, AssertionType)
+	if err != nil {
+		return "", err
+	}
+
+	assertionID, err := context.Auth.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+
+	assertion := Assertion{
+		Claims: jwt.Claims{
+			Issuer:    provider.Issuer,
+			Subject:   userID,
+			Audience:  jwt.Audience{app.ID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Expiry:    jwt.NewNumericDate(now.Add(provider.AssertionExpiry)),
+			ID:        assertionID,
+		},
+		SessionID: sessionID,
+	}
+
+	token, err := jwt.Signed(signer).Claims(extra).Claims(assertion).CompactSerialize()
+	if err != nil {
+		return "", err
+	}
+
+	session := Session{SessionID: sessionID, UserID: userID, AppID: app.ID}
+	if err := context.Auth.GetDB(context.Request).Where(session).FirstOrCreate(&Session{}).Error; err != nil {
+		return "", err
+	}
+
+	context.Auth.Publish(EventAssertionIssued, context, map[string]interface{}{"app": app.ID, "user_id": userID})
+	return token, nil
+}
+
+// SingleLogout notify apps user logged in through the hub session, by posting signed logout tokens to their LogoutURL
+func (provider *Provider) SingleLogout(context *auth.Context, currentClaims *claims.Claims) error {
+	var (
+		tx        = context.Auth.GetDB(context.Request)
+		sessionID = hubSessionID(currentClaims)
+		sessions  []Session
+	)
+
+	if err := tx.Where("session_id = ?", sessionID).Find(&sessions).Error; err != nil {
+		return err
+	}
+
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mutex  sync.Mutex
+		failed = map[string]string{}
+	)
+
+	for _, session := range sessions {
+		app, err := provider.GetApp(session.AppID)
+		if err != nil || app.LogoutURL == "" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(app App, session Session) {
+			defer wg.Done()
+			if err := provider.notifyLogout(context, app, session); err != nil {
+				mutex.Lock()
+				failed[app.ID] = err.Error()
+				mutex.Unlock()
+			}
+		}(app, session)
+	}
+	wg.Wait()
+
+	if err := tx.Unscoped().Where("session_id = ?", sessionID).Delete(&Session{}).Error; err != nil {
+		return err
+	}
+
+	context.Auth.Publish(EventSingleLogout, context, map[string]interface{}{"user_id": currentClaims.GetUserID(), "errors": failed})
+	return nil
+}
+
+func (provider *Provider) notifyLogout(context *auth.Context, app App, session Session) error {
+	signer, err := newSigner(app.Secret, LogoutTokenType)
+	if err != nil {
+		return err
+	}
+
+	tokenID, err := context.Auth.GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	now := context.Auth.Now()
+	logoutToken, err := jwt.Signed(signer).Claims(LogoutToken{
+		Claims: jwt.Claims{
+			Issuer:   provider.Issuer,
+			Subject:  session.UserID,
+			Audience: jwt.Audience{app.ID},
+			IssuedAt: jwt.NewNumericDate(now),
+			Expiry:   jwt.NewNumericDate(now.Add(provider.AssertionExpiry)),
+			ID:       tokenID,
+		},
+		SessionID: session.SessionID,
+		Events:    map[string]interface{}{BackChannelLogoutEvent: map[string]interface{}{}},
+	}).CompactSerialize()
+	if err != nil {
+		return err
+	}
+
+	resp, err := provider.HTTPClient.PostForm(app.LogoutURL, url.Values{"logout_token": []string{logoutToken}})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("logout endpoint responded %v", resp.Status)
+	}
+	return nil
+}
+
+// DefaultClaims default claims of assertions, `email`, `name` read from user model's fields `Email`, `Name`, and `roles`
+func DefaultClaims(context *auth.Context, userID string, app App) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+
+	if user, err := context.Auth.UserStorer.Get(&claims.Claims{UserID: userID}, context); err == nil {
+		if value := utils.Indirect(reflect.ValueOf(user)); value.Kind() == reflect.Struct {
+			for claim, field := range map[string]string{"email": "Email", "name": "Name"} {
+				if f := value.FieldByName(field); f.IsValid() && f.Kind() == reflect.String && f.String() != "" {
+					result[claim] = f.String()
+				}
+			}
+		}
+	}
+
+	roles, err := context.Auth.RoleStorer.Get(userID, context)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(roles) > 0 {
+		result["roles"] = roles
+	}
+	return result, nil
+}
+
+// hubSessionID get hub's session ID of claims, user's ID is used if sessions aren't tracked
+func hubSessionID(currentClaims *claims.Claims) string {
+	if currentClaims.SessionID != "" {
+		return currentClaims.SessionID
+	}
+	return "user:" + currentClaims.GetUserID()
+}
+
+func newSigner(secret string, typ jose.ContentType) (jose.Signer, error) {
+	return jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte(secret)}, (&jose.SignerOptions{}).WithType(typ))
+}
+
+func appendQuery(rawURL string, params url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"sync"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	tablesMutex sync.RWMutex
+	tables      = map[string]bool{"auth_identities": true, "auth_sessions": true, "user_roles": true, "api_keys": true, "auth_migrations": true, "auth_bootstraps": true}
+)
+
+// RegisterTables register default names of auth's tables, Config's TablePrefix, TableSchema will be applied to them, packages like organization register their tables in `init`
+func RegisterTables(names ...string) {
+	tablesMutex.Lock()
+	defer tablesMutex.Unlock()
+
+	for _, name := range names {
+		tables[name] = true
+	}
+}
+
+// TableName get table name of auth's table with configured TableNames, TablePrefix, TableSchema, returns false if the table isn't auth's table and not configured in TableNames
+func (config *Config) TableName(defaultTableName string) (string, bool) {
+	name, ok := config.TableNames[defaultTableName]
+	if !ok {
+		tablesMutex.RLock()
+		ok = tables[defaultTableName]
+		tablesMutex.RUnlock()
+
+		if !ok {
+			return defaultTableName, false
+		}
+		name = config.TablePrefix + defaultTableName
+	}
+
+	if config.TableSchema != "" {
+		name = config.TableSchema + "." + name
+	}
+	return name, true
+}
+
+var (
+	tableNameHandlerOnce sync.Once
+	tableNameConfig      *Config
+)
+
+// TableName get name of auth's table with TableNames, TablePrefix, TableSchema of the Auth configured them, used in models' `TableName` methods,
+// as gorm doesn't apply DefaultTableNameHandler to tables named by models' `TableName` methods
+func TableName(defaultTableName string) string {
+	tablesMutex.RLock()
+	config := tableNameConfig
+	tablesMutex.RUnlock()
+
+	if config != nil {
+		if name, ok := config.TableName(defaultTableName); ok {
+			return name
+		}
+	}
+	return defaultTableName
+}
+
+// registerTableNameHandler rename auth's tables with gorm's DefaultTableNameHandler, which is global, so tables are renamed for all DB instances,
+// the handler is only registered once, tables are named with the config of the last initialized Auth
+func (config *Config) registerTableNameHandler() {
+	if config.TablePrefix == "" && config.TableSchema == "" && len(config.TableNames) == 0 {
+		return
+	}
+
+	tablesMutex.Lock()
+	tableNameConfig = config
+	tablesMutex.Unlock()
+
+	tableNameHandlerOnce.Do(func() {
+		previous := gorm.DefaultTableNameHandler
+		gorm.DefaultTableNameHandler = func(db *gorm.DB, defaultTableName string) string {
+			tablesMutex.RLock()
+			config := tableNameConfig
+			tablesMutex.RUnlock()
+
+			if name, ok := config.TableName(defaultTableName); ok {
+				return name
+			}
+			return previous(db, defaultTableName)
+		}
+	})
+}
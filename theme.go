@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/qor/assetfs"
+	"github.com/qor/auth/views"
+)
+
+// Theme auth theme, provides templates and static assets overwrite default views
+type Theme struct {
+	Name string
+	// Views theme's templates and static assets, templates like `auth/login.tmpl`, static assets served under `{Auth Prefix}/assets/` like `auth/assets/style.css`, views missing in theme fallback to default views
+	Views fs.FS
+}
+
+const (
+	// ThemeDark built-in dark theme of default views
+	ThemeDark = "dark"
+	// ThemeAuto built-in theme of default views, dark if user's system prefers dark color scheme, otherwise light
+	ThemeAuto = "auto"
+)
+
+var (
+	themesMutex sync.RWMutex
+	themes      = map[string]*Theme{}
+)
+
+func init() {
+	for _, name := range []string{ThemeDark, ThemeAuto} {
+		if themeViews, err := fs.Sub(views.Themes, path.Join("themes", name)); err == nil {
+			RegisterTheme(&Theme{Name: name, Views: themeViews})
+		}
+	}
+}
+
+// RegisterTheme register a named theme, select it with Config's Theme
+func RegisterTheme(theme *Theme) {
+	themesMutex.Lock()
+	defer themesMutex.Unlock()
+	themes[theme.Name] = theme
+}
+
+// GetTheme get registered theme with name
+func GetTheme(name string) *Theme {
+	themesMutex.RLock()
+	defer themesMutex.RUnlock()
+	return themes[name]
+}
+
+// registerViews layer auth's views, from high priority to low: TemplateOverrides, view paths, selected theme, embedded default views
+func (config *Config) registerViews(defaultViews fs.FS) {
+	assetFS := config.Render.AssetFileSystem
+
+	if config.Theme != "" {
+		theme := GetTheme(config.Theme)
+		if theme == nil {
+			panic(fmt.Sprintf("auth theme %v not registered", config.Theme))
+		}
+		assetFS = NewEmbeddedAssetFS(assetFS, theme.Views)
+	}
+
+	assetFS = NewEmbeddedAssetFS(assetFS, defaultViews)
+
+	if len(config.TemplateOverrides) > 0 {
+		assetFS = &templateOverridesFS{Interface: assetFS, templates: config.TemplateOverrides}
+	}
+
+	config.Render.SetAssetFS(assetFS)
+}
+
+// templateOverridesFS assetfs returns templates in overrides first
+type templateOverridesFS struct {
+	assetfs.Interface
+	templates map[string]string
+}
+
+func (overridesFS *templateOverridesFS) Asset(name string) ([]byte, error) {
+	if content, ok := overridesFS.templates[strings.TrimSuffix(strings.TrimPrefix(name, "/"), ".tmpl")]; ok {
+		return []byte(content), nil
+	}
+	return overridesFS.Interface.Asset(name)
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/jinzhu/gorm"
+	"github.com/qor/qor/utils"
+)
+
+type transactionKey struct{}
+
+// transaction callbacks of the outermost transaction, run after it is committed
+type transaction struct {
+	mutex       sync.Mutex
+	afterCommit []func()
+}
+
+// Transaction run fc in a database transaction, context's Request is replaced with one carries the transaction during fc, so UserStorer, IdentityStore... save records with it,
+// the transaction is rolled back if fc returns an error or panics, nested calls reuse the outer transaction
+func (auth *Auth) Transaction(context *Context, fc func(context *Context) error) (err error) {
+	req := context.Request
+	if req.Context().Value(transactionKey{}) != nil {
+		return fc(context)
+	}
+
+	tx := auth.GetDB(req).Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	current := &transaction{}
+	context.Request = req.WithContext(withTransaction(req.Context(), tx, current))
+	defer func() {
+		context.Request = req
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err = fc(context); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.Commit().Error; err != nil {
+		return err
+	}
+
+	current.mutex.Lock()
+	callbacks := current.afterCommit
+	current.mutex.Unlock()
+	for _, callback := range callbacks {
+		callback()
+	}
+	return nil
+}
+
+// AfterCommit run fc after request's transaction is committed, it is dropped if the transaction is rolled back, fc runs right away if request isn't in a transaction,
+// use it for side effects shouldn't be seen before changes are visible to others, like invalidating caches
+func AfterCommit(request *http.Request, fc func()) {
+	current, ok := request.Context().Value(transactionKey{}).(*transaction)
+	if !ok {
+		fc()
+		return
+	}
+
+	current.mutex.Lock()
+	current.afterCommit = append(current.afterCommit, fc)
+	current.mutex.Unlock()
+}
+
+func withTransaction(ctx context.Context, tx *gorm.DB, current *transaction) context.Context {
+	return context.WithValue(context.WithValue(ctx, utils.ContextDBName, tx), transactionKey{}, current)
+}
+
+// savepoint run fc in a savepoint if it is in a transaction, so failed statements, like unique index conflicts, won't abort the whole transaction in databases like postgres
+func (auth *Auth) savepoint(context *Context, name string, fc func() error) error {
+	if context.Request.Context().Value(transactionKey{}) == nil {
+		return fc()
+	}
+
+	tx := auth.GetDB(context.Request)
+	if err := tx.Exec(fmt.Sprintf("SAVEPOINT %v", name)).Error; err != nil {
+		return err
+	}
+
+	if err := fc(); err != nil {
+		tx.Exec(fmt.Sprintf("ROLLBACK TO SAVEPOINT %v", name))
+		return err
+	}
+	return tx.Exec(fmt.Sprintf("RELEASE SAVEPOINT %v", name)).Error
+}
@@ -0,0 +1,10 @@
+package user_role
+
+import "github.com/jinzhu/gorm"
+
+// UserRole role granted to an user
+type UserRole struct {
+	gorm.Model
+	UserID string `gorm:"index"`
+	Role   string
+}
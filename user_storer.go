@@ -3,9 +3,10 @@ package auth
 import (
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/jinzhu/copier"
-	"github.com/qor/auth/auth_identity"
+	"github.com/qor/auth/cache"
 	"github.com/qor/auth/claims"
 	"github.com/qor/qor/utils"
 )
@@ -25,7 +26,7 @@ var _ UserStorerInterface = UserStorer{}
 
 // Get defined how to get user with user id
 func (UserStorer) Get(Claims *claims.Claims, context *Context) (user interface{}, err error) {
-	var tx = context.Auth.GetDB(context.Request)
+	var tx = context.Auth.GetReadDB(context.Request)
 
 	if context.Auth.Config.UserModel != nil {
 		if Claims.UserID != "" {
@@ -37,31 +38,24 @@ func (UserStorer) Get(Claims *claims.Claims, context *Context) (user interface{}
 		}
 	}
 
-	var (
-		authIdentity = reflect.New(utils.ModelType(context.Auth.Config.AuthIdentityModel)).Interface()
-		authInfo     = auth_identity.Basic{
-			Provider: Claims.Provider,
-			UID:      Claims.ID,
-		}
-	)
-
-	if !tx.Where(authInfo).First(authIdentity).RecordNotFound() {
-		if context.Auth.Config.UserModel != nil {
-			if authBasicInfo, ok := authIdentity.(interface {
-				ToClaims() *claims.Claims
-			}); ok {
-				currentUser := reflect.New(utils.ModelType(context.Auth.Config.UserModel)).Interface()
-				if err = tx.First(currentUser, authBasicInfo.ToClaims().UserID).Error; err == nil {
-					return currentUser, nil
-				}
-				return nil, ErrInvalidAccount
+	authIdentity, err := context.Auth.IdentityStore.FindByProviderUID(context, Claims.Provider, Claims.ID)
+	if err != nil {
+		return nil, ErrInvalidAccount
+	}
+
+	if context.Auth.Config.UserModel != nil {
+		if authBasicInfo, ok := authIdentity.(interface {
+			ToClaims() *claims.Claims
+		}); ok {
+			currentUser := reflect.New(utils.ModelType(context.Auth.Config.UserModel)).Interface()
+			if err = tx.First(currentUser, authBasicInfo.ToClaims().UserID).Error; err == nil {
+				return currentUser, nil
 			}
+			return nil, ErrInvalidAccount
 		}
-
-		return authIdentity, nil
 	}
 
-	return nil, ErrInvalidAccount
+	return authIdentity, nil
 }
 
 // Save defined how to save user
@@ -81,3 +75,60 @@ func (UserStorer) Save(schema *Schema, context *Context) (user interface{}, user
 func (UserStorer) Update(schema *Schema, context *Context) (err error) {
 	return nil
 }
+
+// NewCachedUserStorer initialize a user storer that caches users get from storer, keyed by claims' subject, users are cached for ttl, a zero ttl means never expire until invalidated
+func NewCachedUserStorer(storer UserStorerInterface, cacheStore cache.Interface, ttl time.Duration) *CachedUserStorer {
+	return &CachedUserStorer{UserStorerInterface: storer, Cache: cacheStore, TTL: ttl}
+}
+
+// CachedUserStorer user storer caches users, so current user won't be loaded from database for every request, users are cached as JSON, fields ignored by JSON won't be cached,
+// cache is invalidated when user updated with it, for multiple instances, use a shared cache store like redis
+type CachedUserStorer struct {
+	UserStorerInterface
+	Cache cache.Interface
+	TTL   time.Duration
+}
+
+var _ UserStorerInterface = &CachedUserStorer{}
+
+// Get get user from cache, load it from storer if not cached
+func (storer *CachedUserStorer) Get(Claims *claims.Claims, context *Context) (user interface{}, err error) {
+	model := context.Auth.Config.UserModel
+	if model == nil {
+		model = context.Auth.Config.AuthIdentityModel
+	}
+
+	cached := reflect.New(utils.ModelType(model)).Interface()
+	if err = storer.Cache.Get(userCacheKey(Claims), cached); err == nil {
+		return cached, nil
+	}
+
+	if user, err = storer.UserStorerInterface.Get(Claims, context); err == nil {
+		storer.Cache.Set(userCacheKey(Claims), user, storer.TTL)
+	}
+	return user, err
+}
+
+// Update update user, and invalidate its cached user
+func (storer *CachedUserStorer) Update(schema *Schema, context *Context) error {
+	if context.Claims != nil {
+		defer storer.Invalidate(context.Claims)
+	}
+	return storer.UserStorerInterface.Update(schema, context)
+}
+
+// Invalidate invalidate cached users, call it if users' profiles changed without the storer, e.g: `storer.Invalidate(&claims.Claims{UserID: userID})`
+func (storer *CachedUserStorer) Invalidate(claims ...*claims.Claims) error {
+	keys := make([]string, len(claims))
+	for idx, c := range claims {
+		keys[idx] = userCacheKey(c)
+	}
+	return storer.Cache.Delete(keys...)
+}
+
+func userCacheKey(claims *claims.Claims) string {
+	if claims.UserID != "" {
+		return "auth:user:" + claims.UserID
+	}
+	return "auth:identity:" + claims.Provider + ":" + claims.ID
+}
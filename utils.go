@@ -1,7 +1,15 @@
 package auth
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"io"
 	"net/http"
+	"reflect"
+	"strings"
 	"time"
 
 	"github.com/jinzhu/gorm"
@@ -20,6 +28,12 @@ func (auth *Auth) GetCurrentUser(req *http.Request) interface{} {
 
 	claims, err := auth.SessionStorer.Get(req)
 	if err == nil {
+		if auth.Config.TrackSessions && claims.SessionID != "" {
+			if session, err := auth.GetSession(req, claims.SessionID); err == nil && session.IsRevoked() {
+				return nil
+			}
+		}
+
 		context := &Context{Auth: auth, Claims: claims, Request: req}
 		if user, err := auth.UserStorer.Get(claims, context); err == nil {
 			return user
@@ -38,16 +52,93 @@ func (auth *Auth) GetDB(request *http.Request) *gorm.DB {
 	return auth.Config.DB
 }
 
+type primaryDBKey struct{}
+
+// GetReadDB get db for read-only lookups from request, ReplicaDB is used if configured, except the request is in a transaction or marked with UsePrimaryDB
+func (auth *Auth) GetReadDB(request *http.Request) *gorm.DB {
+	if auth.Config.ReplicaDB != nil && request.Context().Value(transactionKey{}) == nil && request.Context().Value(primaryDBKey{}) == nil {
+		return auth.Config.ReplicaDB
+	}
+	return auth.GetDB(request)
+}
+
+// UsePrimaryDB mark request to read from primary DB, for lookups of records just written, which may not be replicated yet
+func UsePrimaryDB(request *http.Request) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), primaryDBKey{}, true))
+}
+
 // Login sign user in
 func (auth *Auth) Login(w http.ResponseWriter, req *http.Request, claimer claims.ClaimerInterface) error {
-	claims := claimer.ToClaims()
-	now := time.Now()
+	_, err := auth.login(w, req, claimer)
+	return err
+}
+
+// login sign user in, return issued claims, claimer's claims are copied, not changed, so claims shared across requests are safe to be passed
+func (auth *Auth) login(w http.ResponseWriter, req *http.Request, claimer claims.ClaimerInterface) (*claims.Claims, error) {
+	claims := *claimer.ToClaims()
+	now := auth.Now()
 	claims.LastLoginAt = &now
 
-	return auth.SessionStorer.Update(w, req, claims)
+	if auth.Config.TrackSessions {
+		if err := auth.createSession(w, req, &claims); err != nil {
+			return nil, err
+		}
+	}
+
+	return &claims, auth.SessionStorer.Update(w, req, &claims)
 }
 
 // Logout sign current user out
 func (auth *Auth) Logout(w http.ResponseWriter, req *http.Request) {
+	if auth.Config.TrackSessions {
+		if claims, err := auth.SessionStorer.Get(req); err == nil {
+			auth.revokeSession(req, claims.SessionID)
+		}
+	}
+
 	auth.SessionStorer.Delete(w, req)
 }
+
+// GetEmail get email from user's `Email` field, or auth identity's UID if it is an email
+func (auth *Auth) GetEmail(context *Context, claims *claims.Claims) string {
+	if user, err := auth.UserStorer.Get(claims, context); err == nil && user != nil {
+		if value := utils.Indirect(reflect.ValueOf(user)); value.Kind() == reflect.Struct {
+			if field := value.FieldByName("Email"); field.IsValid() && field.Kind() == reflect.String && field.String() != "" {
+				return field.String()
+			}
+		}
+	}
+
+	if strings.Contains(claims.ID, "@") {
+		return claims.ID
+	}
+	return ""
+}
+
+// SecureCompare compare secrets in constant time, they are hashed first, so the time doesn't leak their lengths either
+func SecureCompare(given, expected string) bool {
+	givenSum, expectedSum := sha256.Sum256([]byte(given)), sha256.Sum256([]byte(expected))
+	return subtle.ConstantTimeCompare(givenSum[:], expectedSum[:]) == 1
+}
+
+// GenerateToken generate a random token with Config.Random, used for session IDs, device IDs and other secrets, returns an error if failed to read random bytes
+func (auth *Auth) GenerateToken() (string, error) {
+	random := auth.Config.Random
+	if random == nil {
+		random = rand.Reader
+	}
+
+	token := make([]byte, 32)
+	if _, err := io.ReadFull(random, token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// Now get current time with Config.Clock
+func (auth *Auth) Now() time.Time {
+	if auth.Config.Clock == nil {
+		return time.Now()
+	}
+	return auth.Config.Clock()
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ConfigError configuration problems found when validating config, all problems are reported together
+type ConfigError struct {
+	// Name name of validated config, like `auth`, `saml_idp`
+	Name     string
+	Problems []string
+}
+
+// Error error message, like `auth: invalid config: Redirector must be specified; theme dark2 not registered`
+func (err *ConfigError) Error() string {
+	return fmt.Sprintf("%v: invalid config: %v", err.Name, strings.Join(err.Problems, "; "))
+}
+
+// Add add a problem, args are formatted with fmt.Sprintf
+func (err *ConfigError) Add(format string, args ...interface{}) {
+	err.Problems = append(err.Problems, fmt.Sprintf(format, args...))
+}
+
+// Err return the error if it has problems, otherwise nil
+func (err *ConfigError) Err() error {
+	if len(err.Problems) == 0 {
+		return nil
+	}
+	return err
+}
+
+// Validate check config, returns a *ConfigError lists all problems, or nil if config is valid, New panics with the error, NewWithError returns it
+func (config *Config) Validate() error {
+	configErr := &ConfigError{Name: "auth"}
+
+	if config.Redirector == nil {
+		configErr.Add("Redirector must be specified")
+	}
+
+	if config.ReplicaDB != nil && config.DB == nil {
+		configErr.Add("DB must be specified if ReplicaDB is specified")
+	}
+
+	if config.Theme != "" && GetTheme(config.Theme) == nil {
+		configErr.Add("theme %v not registered", config.Theme)
+	}
+
+	if _, err := parseCIDRs(config.TrustedProxies); err != nil {
+		configErr.Add("TrustedProxies has %v", err)
+	}
+
+	validateIPRules := func(name string, rules *IPRules) {
+		if rules == nil {
+			return
+		}
+
+		if _, err := parseCIDRs(rules.Allow); err != nil {
+			configErr.Add("%v.Allow has %v", name, err)
+		}
+
+		if _, err := parseCIDRs(rules.Deny); err != nil {
+			configErr.Add("%v.Deny has %v", name, err)
+		}
+	}
+
+	validateIPRules("IPRules", config.IPRules)
+	for _, name := range sortedKeys(config.ProviderIPRules) {
+		validateIPRules(fmt.Sprintf("ProviderIPRules[%v]", name), config.ProviderIPRules[name])
+	}
+	for _, name := range sortedKeys(config.RoleIPRules) {
+		validateIPRules(fmt.Sprintf("RoleIPRules[%v]", name), config.RoleIPRules[name])
+	}
+
+	for _, host := range config.ReturnToHosts {
+		if host == "" || strings.ContainsAny(host, "/:@") {
+			configErr.Add("ReturnToHosts %q should be a host name, like app.example.com or *.example.com", host)
+		}
+	}
+
+	for _, code := range sortedKeys(config.Failures) {
+		failure := config.Failures[code]
+		if failure == nil {
+			continue
+		}
+
+		var responses int
+		for _, set := range []bool{failure.Redirect != "", failure.Template != "", failure.JSON} {
+			if set {
+				responses++
+			}
+		}
+
+		if responses > 1 {
+			configErr.Add("Failures[%v] should only set one of Redirect, Template, JSON", code)
+		}
+
+		if failure.Redirect != "" {
+			if _, err := url.Parse(failure.Redirect); err != nil {
+				configErr.Add("Failures[%v] has invalid Redirect: %v", code, err)
+			}
+		}
+	}
+
+	if config.CookiePolicy != nil {
+		config.CookiePolicy.validate(configErr)
+		if !config.CookiePolicy.Development && !isHardenedSessionStorer(config.SessionStorer) {
+			configErr.Add("SessionStorer should sign tokens with a key, and use a session manager with its own keys, like CookiePolicy.SessionManager, in strict cookie mode")
+		}
+	}
+
+	if config.Bootstrap != nil && !config.Bootstrap.FirstUserIsAdmin && len(config.Bootstrap.InitialAdminEmails) == 0 {
+		configErr.Add("Bootstrap should set FirstUserIsAdmin or InitialAdminEmails")
+	}
+
+	return configErr.Err()
+}
+
+// sortedKeys sorted keys of map, so problems are reported in stable order
+func sortedKeys(value interface{}) []string {
+	var keys []string
+	for _, key := range reflect.ValueOf(value).MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"html/template"
+
+	"github.com/qor/auth/otpauth"
+)
+
+// Execute render auth view with context as data, using Config's FuncMap, func `view_data` returns data from Config's ViewData, `branding`, `csrf_token`, `csrf_field`, `csp_nonce`, and otpauth's QR code helpers, functions in funcMaps overwrite them
+func (context *Context) Execute(name string, funcMaps ...template.FuncMap) error {
+	funcMap := template.FuncMap{}
+	for key, fc := range otpauth.FuncMap {
+		funcMap[key] = fc
+	}
+
+	for key, fc := range context.Auth.Config.FuncMap {
+		funcMap[key] = fc
+	}
+
+	funcMap["view_data"] = context.ViewData
+	funcMap["branding"] = context.Auth.branding
+	funcMap["csrf_token"] = context.CSRFToken
+	funcMap["csrf_field"] = context.CSRFField
+	funcMap["csp_nonce"] = context.CSPNonce
+
+	for _, fm := range funcMaps {
+		for key, fc := range fm {
+			funcMap[key] = fc
+		}
+	}
+
+	return context.Auth.Config.Render.Funcs(funcMap).Execute(name, context, context.Request, context.Writer)
+}
+
+// ViewData get per-request data for views from Config's ViewData, like CSRF token, branding, feature flags, used in views like `{{(view_data).csrf_token}}`
+func (context *Context) ViewData() map[string]interface{} {
+	if context.Auth.Config.ViewData == nil {
+		return map[string]interface{}{}
+	}
+	return context.Auth.Config.ViewData(context)
+}
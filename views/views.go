@@ -0,0 +1,14 @@
+// Package views default views of auth, embedded into the binary, so they could be rendered without the package's source directory
+package views
+
+import "embed"
+
+// FS embedded default views, templates are under `auth`, mail templates are under `mailers`
+//
+//go:embed auth mailers
+var FS embed.FS
+
+// Themes embedded built-in themes, like `dark`, `auto`
+//
+//go:embed themes
+var Themes embed.FS
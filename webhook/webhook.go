@@ -0,0 +1,309 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/qor/auth"
+)
+
+const (
+	// HeaderEvent header of event name
+	HeaderEvent = "X-Auth-Event"
+	// HeaderDelivery header of delivery ID, it is the same for retries
+	HeaderDelivery = "X-Auth-Delivery"
+	// HeaderTimestamp header of unix timestamp when payload signed
+	HeaderTimestamp = "X-Auth-Timestamp"
+	// HeaderSignature header of payload's signature, in format `sha256=<hex encoded HMAC-SHA256 of "{timestamp}.{body}">`
+	HeaderSignature = "X-Auth-Signature"
+)
+
+// ErrQueueFull delivery queue is full, the payload is dropped, passed to ErrorHandler
+var ErrQueueFull = errors.New("webhook delivery queue is full")
+
+// Config webhook config
+type Config struct {
+	Auth      *auth.Auth
+	Endpoints []Endpoint
+	// MaxRetries max retry times after failed to deliver, default value is 3
+	MaxRetries int
+	// RetryBackoff wait time before first retry, doubled for every retry, default value is 1 second
+	RetryBackoff time.Duration
+	// HTTPClient client used to deliver webhooks, default value is a client with 10 seconds timeout
+	HTTPClient *http.Client
+	// ErrorHandler called after all retries failed, or the payload is dropped as the queue is full, default is printing the error
+	ErrorHandler func(endpoint Endpoint, payload *Payload, err error)
+	// Workers number of goroutines delivering webhooks, default value is 4
+	Workers int
+	// QueueSize max deliveries waiting for workers, payloads are dropped with ErrQueueFull when the queue is full, so slow endpoints won't pile up goroutines, default value is 1000
+	QueueSize int
+}
+
+// Endpoint webhook endpoint
+type Endpoint struct {
+	URL string
+	// Secret used to sign payloads with HMAC-SHA256
+	Secret string
+	// Events names of events sent to this endpoint, like `user.registered`, blank means all events
+	Events []string
+}
+
+// Payload webhook payload
+type Payload struct {
+	ID        string                 `json:"id"`
+	Event     string                 `json:"event"`
+	Provider  string                 `json:"provider,omitempty"`
+	UserID    string                 `json:"user_id,omitempty"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	Location  *auth.GeoLocation      `json:"location,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// New initialize webhook, subscribe to auth's events and deliver them to endpoints, panics if config is invalid, use NewWithError to handle the error
+func New(config *Config) *Webhook {
+	webhook, err := NewWithError(config)
+	if err != nil {
+		panic(err)
+	}
+	return webhook
+}
+
+// NewWithError initialize webhook, returns a *auth.ConfigError lists all problems if config is invalid
+func NewWithError(config *Config) (*Webhook, error) {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if config.MaxRetries == 0 {
+		config.MaxRetries = 3
+	}
+
+	if config.RetryBackoff == 0 {
+		config.RetryBackoff = time.Second
+	}
+
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = func(endpoint Endpoint, payload *Payload, err error) {
+			fmt.Printf("failed to deliver webhook %v (%v) to %v: %v\n", payload.Event, payload.ID, endpoint.URL, err)
+		}
+	}
+
+	if config.Workers == 0 {
+		config.Workers = 4
+	}
+
+	if config.QueueSize == 0 {
+		config.QueueSize = 1000
+	}
+
+	webhook := &Webhook{Config: config, queue: make(chan delivery, config.QueueSize)}
+	for i := 0; i < config.Workers; i++ {
+		webhook.workers.Add(1)
+		go webhook.work()
+	}
+	config.Auth.Subscribe(auth.EventAll, webhook.handleEvent)
+	return webhook, nil
+}
+
+// Validate validate config
+func (config *Config) Validate() error {
+	configErr := &auth.ConfigError{Name: "webhook"}
+	if config.Auth == nil {
+		configErr.Add("Auth must be specified")
+	}
+	return configErr.Err()
+}
+
+// Webhook webhook struct, deliver auth events to configured endpoints with a pool of Workers
+type Webhook struct {
+	*Config
+	queue   chan delivery
+	mutex   sync.RWMutex
+	closed  bool
+	workers sync.WaitGroup
+}
+
+type delivery struct {
+	endpoint Endpoint
+	payload  *Payload
+}
+
+// Close stop accepting events, wait for queued deliveries finished
+func (webhook *Webhook) Close() {
+	webhook.mutex.Lock()
+	if !webhook.closed {
+		webhook.closed = true
+		close(webhook.queue)
+	}
+	webhook.mutex.Unlock()
+	webhook.workers.Wait()
+}
+
+func (webhook *Webhook) work() {
+	defer webhook.workers.Done()
+	for delivery := range webhook.queue {
+		if err := webhook.DeliverWithRetry(delivery.endpoint, delivery.payload); err != nil {
+			webhook.ErrorHandler(delivery.endpoint, delivery.payload, err)
+		}
+	}
+}
+
+func (webhook *Webhook) handleEvent(event *auth.Event) {
+	var payload *Payload
+
+	webhook.mutex.RLock()
+	defer webhook.mutex.RUnlock()
+	if webhook.closed {
+		return
+	}
+
+	for _, endpoint := range webhook.Endpoints {
+		if !endpoint.subscribed(event.Name) {
+			continue
+		}
+
+		if payload == nil {
+			var err error
+			if payload, err = webhook.NewPayload(event); err != nil {
+				fmt.Printf("failed to generate webhook payload of %v: %v\n", event.Name, err)
+				return
+			}
+		}
+
+		select {
+		case webhook.queue <- delivery{endpoint: endpoint, payload: payload}:
+		default:
+			webhook.ErrorHandler(endpoint, payload, ErrQueueFull)
+		}
+	}
+}
+
+// DeliverWithRetry deliver payload to endpoint, retry with exponential backoff if failed
+func (webhook *Webhook) DeliverWithRetry(endpoint Endpoint, payload *Payload) (err error) {
+	backoff := webhook.RetryBackoff
+	for retries := 0; ; retries++ {
+		if err = webhook.Deliver(endpoint, payload); err == nil || retries >= webhook.MaxRetries {
+			return err
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Deliver deliver payload to endpoint once, returns error if failed to request or got a non 2xx response
+func (webhook *Webhook) Deliver(endpoint Endpoint, payload *Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(webhook.Auth.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, payload.Event)
+	req.Header.Set(HeaderDelivery, payload.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if endpoint.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(endpoint.Secret, timestamp, body))
+	}
+
+	resp, err := webhook.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("got response status %v", resp.Status)
+	}
+	return nil
+}
+
+// NewPayload generate payload from event, its ID is generated with Auth.GenerateToken
+func (webhook *Webhook) NewPayload(event *auth.Event) (*Payload, error) {
+	id, err := webhook.Auth.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	payload := &Payload{
+		ID:        id,
+		Event:     event.Name,
+		Provider:  event.ProviderName(),
+		Data:      map[string]interface{}{},
+		Location:  event.Location,
+		CreatedAt: event.CreatedAt,
+	}
+
+	if event.Claims != nil {
+		payload.UserID = event.Claims.GetUserID()
+	}
+
+	for key, value := range event.Data {
+		if err, ok := value.(error); ok {
+			payload.Data[key] = err.Error()
+		} else if _, err := json.Marshal(value); err == nil {
+			payload.Data[key] = value
+		}
+	}
+	return payload, nil
+}
+
+// Sign sign payload's body with secret
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify verify webhook request's signature, could be used by receivers, reject requests whose timestamp differs from current time more than tolerance, in either direction, to prevent replay
+func Verify(secret string, req *http.Request, body []byte, tolerance time.Duration) bool {
+	timestamp := req.Header.Get(HeaderTimestamp)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	if skew := time.Since(time.Unix(unix, 0)); tolerance > 0 && (skew > tolerance || skew < -tolerance) {
+		return false
+	}
+
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(req.Header.Get(HeaderSignature)))
+}
+
+func (endpoint Endpoint) subscribed(name string) bool {
+	if len(endpoint.Events) == 0 {
+		return true
+	}
+
+	for _, event := range endpoint.Events {
+		if event == name || event == auth.EventAll {
+			return true
+		}
+	}
+	return false
+}
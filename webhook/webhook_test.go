@@ -0,0 +1,65 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/qor/auth"
+	"github.com/qor/auth/authtest"
+	"github.com/qor/auth/webhook"
+)
+
+type redirector struct{}
+
+func (redirector) Redirect(w http.ResponseWriter, req *http.Request, action string) {}
+
+func TestDeliverWithInjectedRandomAndClock(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	deliveries := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		deliveries <- req
+	}))
+	t.Cleanup(server.Close)
+
+	var (
+		now  = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		Auth = auth.New(&auth.Config{DB: db, Redirector: redirector{}, Random: authtest.NewRandom(1), Clock: authtest.NewClock(now).Now})
+		hook = webhook.New(&webhook.Config{Auth: Auth, Endpoints: []webhook.Endpoint{{URL: server.URL, Secret: "secret"}}})
+	)
+	t.Cleanup(hook.Close)
+
+	payload, err := hook.NewPayload(&auth.Event{Name: auth.EventLogin, CreatedAt: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedID, _ := auth.New(&auth.Config{DB: db, Redirector: redirector{}, Random: authtest.NewRandom(1)}).GenerateToken()
+	if payload.ID != expectedID {
+		t.Errorf("expect payload ID %v generated from injected random source, got %v", expectedID, payload.ID)
+	}
+
+	if err := hook.Deliver(hook.Endpoints[0], payload); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-deliveries
+	if timestamp := req.Header.Get(webhook.HeaderTimestamp); timestamp != strconv.FormatInt(now.Unix(), 10) {
+		t.Errorf("expect timestamp of injected clock, got %v", timestamp)
+	}
+
+	body, _ := json.Marshal(payload)
+	if req.Header.Get(webhook.HeaderSignature) != webhook.Sign("secret", strconv.FormatInt(now.Unix(), 10), body) {
+		t.Errorf("expect payload signed with timestamp of injected clock")
+	}
+}